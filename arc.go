@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sebcat/warc"
+)
+
+// arcReader reads records from a decompressed ARC file (version 1 or 2)
+// and returns them as WARC records, so that the rest of the pipeline
+// does not have to know about ARC.
+type arcReader struct {
+	r       io.Reader
+	br      *bufio.Reader
+	nfields int // fields in a URL record header line, by version
+	nrecs   int
+}
+
+func newARCReader(r io.Reader) *arcReader {
+	return &arcReader{r: r, br: bufio.NewReader(r), nfields: 5}
+}
+
+func (r *arcReader) NextRaw() ([]byte, error) {
+	var line string
+	for len(line) == 0 {
+		var err error
+		line, err = r.br.ReadString('\n')
+		if err == io.EOF && len(strings.TrimSpace(line)) == 0 {
+			return nil, io.EOF
+		} else if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimSpace(line)
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return nil, warc.ErrMalformedRecord
+	}
+
+	length, err := strconv.ParseInt(fields[len(fields)-1], 10, 64)
+	if err != nil || length < 0 {
+		return nil, warc.ErrMalformedRecord
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r.br, content); err != nil {
+		return nil, warc.ErrMalformedRecord
+	}
+
+	r.nrecs++
+	if strings.HasPrefix(fields[0], "filedesc:") {
+		// the version block's first line is "<major> <minor> <origin>"
+		if bytes.HasPrefix(content, []byte("2 ")) {
+			r.nfields = 10
+		}
+
+		return r.toWARC(line, "warcinfo", "", fields[2], "", "text/plain", content), nil
+	}
+
+	// URLs with spaces in them are broken, but they do occur
+	if extra := len(fields) - r.nfields; extra > 0 {
+		fields = append([]string{strings.Join(fields[:extra+1], "%20")}, fields[extra+1:]...)
+	}
+
+	mime := fields[3]
+	if strings.HasPrefix(fields[0], "http:") || strings.HasPrefix(fields[0], "https:") {
+		mime = "application/http; msgtype=response"
+	}
+
+	return r.toWARC(line, "response", fields[0], fields[2], fields[1], mime, content), nil
+}
+
+func (r *arcReader) toWARC(header, typ, uri, date, ip, mime string, content []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("WARC/1.0\r\n")
+	buf.WriteString("WARC-Type: " + typ + "\r\n")
+	sum := sha1.Sum([]byte(fmt.Sprintf("%v %v", r.nrecs, header)))
+	fmt.Fprintf(&buf, "WARC-Record-ID: <urn:uuid:%x-%x-%x-%x-%x>\r\n",
+		sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+	if t, err := time.Parse("20060102150405", date); err == nil {
+		buf.WriteString("WARC-Date: " + t.Format(time.RFC3339) + "\r\n")
+	}
+
+	if len(uri) > 0 {
+		buf.WriteString("WARC-Target-URI: " + uri + "\r\n")
+	}
+
+	if len(ip) > 0 && ip != "0.0.0.0" {
+		buf.WriteString("WARC-IP-Address: " + ip + "\r\n")
+	}
+
+	buf.WriteString("Content-Type: " + mime + "\r\n")
+	fmt.Fprintf(&buf, "Content-Length: %v\r\n\r\n", len(content))
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+func (r *arcReader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
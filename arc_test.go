@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/sebcat/warc"
+)
+
+func arcRecord(header, content string) string {
+	return fmt.Sprintf("%v %v\n%v\n", header, len(content), content)
+}
+
+func TestARCReader(t *testing.T) {
+	v1desc := "1 0 InternetArchive\nURL IP-address Archive-date Content-type Archive-length\n"
+	v2desc := "2 0 Alexa\nURL IP-address Archive-date Content-type Result-code Checksum " +
+		"Location Offset Filename Archive-length\n"
+	body := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html></html>"
+
+	tests := []struct {
+		name   string
+		arc    string
+		uris   []string
+		ips    []string
+		dates  []string
+		blocks []string
+	}{
+		{
+			"v1",
+			arcRecord("filedesc://a.arc 0.0.0.0 20050614070159 text/plain", v1desc) +
+				arcRecord("http://www.archive.org/ 207.241.224.11 20050614070200 text/html", body),
+			[]string{"", "http://www.archive.org/"},
+			[]string{"", "207.241.224.11"},
+			[]string{"2005-06-14T07:01:59Z", "2005-06-14T07:02:00Z"},
+			[]string{v1desc, body},
+		},
+		{
+			"v2",
+			arcRecord("filedesc://b.arc 0.0.0.0 20050614070159 text/plain 200 - - 0 b.arc", v2desc) +
+				arcRecord("http://example.com/ 192.0.2.1 20050614070300 text/html 200 "+
+					"XYZ - 1234 b.arc", body),
+			[]string{"", "http://example.com/"},
+			[]string{"", "192.0.2.1"},
+			[]string{"2005-06-14T07:01:59Z", "2005-06-14T07:03:00Z"},
+			[]string{v2desc, body},
+		},
+		{
+			"v1 URL with spaces",
+			arcRecord("filedesc://a.arc 0.0.0.0 20050614070159 text/plain", v1desc) +
+				arcRecord("http://example.com/a b c.html 192.0.2.1 20050614070200 text/html", body),
+			[]string{"", "http://example.com/a%20b%20c.html"},
+			[]string{"", "192.0.2.1"},
+			[]string{"2005-06-14T07:01:59Z", "2005-06-14T07:02:00Z"},
+			[]string{v1desc, body},
+		},
+		{
+			"v2 URL with spaces",
+			arcRecord("filedesc://b.arc 0.0.0.0 20050614070159 text/plain 200 - - 0 b.arc", v2desc) +
+				arcRecord("http://example.com/a b 192.0.2.1 20050614070300 text/html 200 "+
+					"XYZ - 1234 b.arc", body),
+			[]string{"", "http://example.com/a%20b"},
+			[]string{"", "192.0.2.1"},
+			[]string{"2005-06-14T07:01:59Z", "2005-06-14T07:03:00Z"},
+			[]string{v2desc, body},
+		},
+	}
+
+	for _, tt := range tests {
+		r := newARCReader(strings.NewReader(tt.arc))
+		for i := range tt.uris {
+			raw, err := r.NextRaw()
+			if err != nil {
+				t.Fatalf("%v: record %v: %v", tt.name, i, err)
+			}
+
+			var rec warc.Record
+			if err := rec.FromBytes(raw); err != nil {
+				t.Fatalf("%v: record %v: %v", tt.name, i, err)
+			}
+
+			wantType := "response"
+			if i == 0 {
+				wantType = "warcinfo"
+			}
+
+			for _, c := range []struct{ field, want string }{
+				{"WARC-Type", wantType},
+				{"WARC-Target-URI", tt.uris[i]},
+				{"WARC-IP-Address", tt.ips[i]},
+				{"WARC-Date", tt.dates[i]},
+			} {
+				if got := rec.Fields.Value(c.field); got != c.want {
+					t.Errorf("%v: record %v: %v is %q, want %q", tt.name, i, c.field, got, c.want)
+				}
+			}
+
+			if got := string(recordBlock(raw)); got != tt.blocks[i] {
+				t.Errorf("%v: record %v: block %q, want %q", tt.name, i, got, tt.blocks[i])
+			}
+		}
+
+		if _, err := r.NextRaw(); err != io.EOF {
+			t.Errorf("%v: got %v at end, want EOF", tt.name, err)
+		}
+	}
+}
+
+func TestARCReaderMalformed(t *testing.T) {
+	arc := "garbage line\n" +
+		arcRecord("http://example.com/ 192.0.2.1 20050614070200 text/html", "x")
+	r := newARCReader(strings.NewReader(arc))
+	if _, err := r.NextRaw(); err != warc.ErrMalformedRecord {
+		t.Fatalf("got %v, want ErrMalformedRecord", err)
+	}
+
+	// the next header line is found again
+	if _, err := r.NextRaw(); err != nil {
+		t.Fatal(err)
+	}
+}
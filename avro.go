@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// avroSchema is the schema of -format avro. Fields are only ever added to
+// it, with defaults, so that readers of earlier files keep working.
+const avroSchema = `{"type":"record","name":"URL","namespace":"warc_urls","fields":[` +
+	`{"name":"url","type":"string"},` +
+	`{"name":"host","type":"string"},` +
+	`{"name":"timestamp","type":["null",{"type":"long","logicalType":"timestamp-millis"}],"default":null},` +
+	`{"name":"mime","type":["null","string"],"default":null},` +
+	`{"name":"status","type":["null","int"],"default":null},` +
+	`{"name":"digest","type":["null","string"],"default":null}]}`
+
+const avroMagic = "Obj\x01"
+
+// a block of the container file is written once it has avroBlockCount
+// records or avroBlockSize bytes of them, or its first record is
+// avroBlockAge old, so that a slow stream is still read promptly
+const (
+	avroBlockCount = 1000
+	avroBlockSize  = 1 << 20
+	avroBlockAge   = time.Second
+)
+
+// avroWriter writes an Avro object container file of deflated blocks.
+// Like those of a stream, the blocks can be read once written.
+type avroWriter struct {
+	w      io.Writer
+	sync   [16]byte
+	header bool
+	block  bytes.Buffer // the records of the block being written
+	count  int
+	since  time.Time // when the first record of the block was written
+	buf    bytes.Buffer // the deflated block
+	fw     *flate.Writer
+}
+
+func newAvroWriter(w io.Writer) *avroWriter {
+	aw := &avroWriter{w: w}
+	rand.Read(aw.sync[:])
+	aw.fw, _ = flate.NewWriter(&aw.buf, flate.DefaultCompression)
+	return aw
+}
+
+func appendAvroLong(b []byte, n int64) []byte {
+	return binary.AppendUvarint(b, uint64(n<<1)^uint64(n>>63))
+}
+
+func appendAvroString(b []byte, s string) []byte {
+	return append(appendAvroLong(b, int64(len(s))), s...)
+}
+
+// appendAvroOptional appends the ["null","string"] union of s, null if empty
+func appendAvroOptional(b []byte, s string) []byte {
+	if len(s) == 0 {
+		return appendAvroLong(b, 0)
+	}
+
+	return appendAvroString(appendAvroLong(b, 1), s)
+}
+
+func (w *avroWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+
+	w.header = true
+	b := []byte(avroMagic)
+	b = appendAvroLong(b, 2)
+	b = appendAvroString(b, "avro.schema")
+	b = appendAvroString(b, avroSchema)
+	b = appendAvroString(b, "avro.codec")
+	b = appendAvroString(b, "deflate")
+	b = appendAvroLong(b, 0)
+	b = append(b, w.sync[:]...)
+	_, err := w.w.Write(b)
+	return err
+}
+
+func appendAvroEntry(b []byte, e *entry) []byte {
+	b = appendAvroString(b, e.URL)
+	b = appendAvroString(b, e.host())
+	if date, err := time.Parse(time.RFC3339Nano, e.Date); err == nil {
+		b = appendAvroLong(appendAvroLong(b, 1), date.UnixMilli())
+	} else {
+		b = appendAvroLong(b, 0)
+	}
+
+	b = appendAvroOptional(b, e.MIME)
+	if e.Status > 0 {
+		b = appendAvroLong(appendAvroLong(b, 1), int64(e.Status))
+	} else {
+		b = appendAvroLong(b, 0)
+	}
+
+	return appendAvroOptional(b, e.Digest)
+}
+
+func (w *avroWriter) Write(e *entry) error {
+	if w.count == 0 {
+		w.since = time.Now()
+	}
+
+	w.block.Write(appendAvroEntry(nil, e))
+	w.count++
+	if w.count >= avroBlockCount || w.block.Len() >= avroBlockSize {
+		return w.flush()
+	}
+
+	return w.flushStale()
+}
+
+// flushStale writes the block if its first record is avroBlockAge old
+func (w *avroWriter) flushStale() error {
+	if w.count > 0 && time.Since(w.since) >= avroBlockAge {
+		return w.flush()
+	}
+
+	return nil
+}
+
+func (w *avroWriter) flush() error {
+	if err := w.writeHeader(); err != nil || w.count == 0 {
+		return err
+	}
+
+	w.buf.Reset()
+	w.fw.Reset(&w.buf)
+	w.fw.Write(w.block.Bytes())
+	if err := w.fw.Close(); err != nil {
+		return err
+	}
+
+	b := appendAvroLong(nil, int64(w.count))
+	b = appendAvroLong(b, int64(w.buf.Len()))
+	w.block.Reset()
+	w.count = 0
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	} else if _, err := w.w.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := w.w.Write(w.sync[:])
+	return err
+}
+
+func (w *avroWriter) Close() error {
+	return w.flush()
+}
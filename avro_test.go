@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type avroRecord struct {
+	URL, Host         string
+	Timestamp, Status *int64
+	MIME, Digest      *string
+}
+
+// readAvro decodes an avroWriter file, checking its header and syncs
+func readAvro(t *testing.T, data []byte) []avroRecord {
+	r := bufio.NewReader(bytes.NewReader(data))
+	long := func() int64 {
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	str := func() string {
+		b := make([]byte, long())
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+
+		return string(b)
+	}
+
+	magic := make([]byte, 4)
+	io.ReadFull(r, magic)
+	if string(magic) != avroMagic {
+		t.Fatalf("got magic %q", magic)
+	}
+
+	meta := make(map[string]string)
+	for n := long(); n != 0; n = long() {
+		for i := int64(0); i < n; i++ {
+			k := str()
+			meta[k] = str()
+		}
+	}
+
+	var schema interface{}
+	if err := json.Unmarshal([]byte(meta["avro.schema"]), &schema); err != nil {
+		t.Fatal(err)
+	} else if meta["avro.codec"] != "deflate" {
+		t.Fatalf("got codec %q", meta["avro.codec"])
+	}
+
+	sync := make([]byte, 16)
+	io.ReadFull(r, sync)
+	var recs []avroRecord
+	for {
+		if _, err := r.Peek(1); err == io.EOF {
+			return recs
+		}
+
+		count := long()
+		block := make([]byte, long())
+		io.ReadFull(r, block)
+		end := make([]byte, 16)
+		if io.ReadFull(r, end); !bytes.Equal(end, sync) {
+			t.Fatal("invalid sync marker")
+		}
+
+		inflated, err := io.ReadAll(flate.NewReader(bytes.NewReader(block)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		br := r
+		r = bufio.NewReader(bytes.NewReader(inflated))
+		for i := int64(0); i < count; i++ {
+			rec := avroRecord{URL: str(), Host: str()}
+			if long() == 1 {
+				n := long()
+				rec.Timestamp = &n
+			}
+
+			if long() == 1 {
+				s := str()
+				rec.MIME = &s
+			}
+
+			if long() == 1 {
+				n := long()
+				rec.Status = &n
+			}
+
+			if long() == 1 {
+				s := str()
+				rec.Digest = &s
+			}
+
+			recs = append(recs, rec)
+		}
+
+		if _, err := r.ReadByte(); err != io.EOF {
+			t.Fatal("trailing data in block")
+		}
+
+		r = br
+	}
+}
+
+func TestAvroOutput(t *testing.T) {
+	var buf bytes.Buffer
+	w := newEntryWriter("avro", &buf)
+	var want []avroRecord
+	ms, status, mime, digest := int64(1704164645000), int64(200), "text/html", "sha1:AAAA"
+	for i := 0; i < 2500; i++ {
+		e := &entry{URL: "http://Host.example/" + string(rune('a'+i%26)), Date: "2024-01-02T03:04:05Z",
+			MIME: mime, Status: 200, Digest: digest}
+		rec := avroRecord{URL: e.URL, Host: "host.example", Timestamp: &ms, MIME: &mime, Status: &status, Digest: &digest}
+		if i == 5 {
+			e.Date, e.Status, e.MIME, e.Digest = "yesterday", 0, "", ""
+			rec.Timestamp, rec.Status, rec.MIME, rec.Digest = nil, nil, nil, nil
+		}
+
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+
+		want = append(want, rec)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readAvro(t, buf.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v records, want %v", len(got), len(want))
+	}
+
+	// an empty file is a header
+	buf.Reset()
+	if err := newAvroWriter(&buf).Close(); err != nil {
+		t.Fatal(err)
+	} else if got := readAvro(t, buf.Bytes()); len(got) > 0 {
+		t.Errorf("got %v records", len(got))
+	}
+}
+
+func TestAvroBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	w := newAvroWriter(&buf)
+	w.Write(&entry{URL: "http://a.example/"})
+	if err := w.flushStale(); err != nil {
+		t.Fatal(err)
+	} else if buf.Len() > 0 {
+		t.Fatal("wrote a new block")
+	}
+
+	// a block is written once its first record is old, or it's large
+	w.since = time.Now().Add(-avroBlockAge)
+	if err := w.flushStale(); err != nil {
+		t.Fatal(err)
+	} else if got := readAvro(t, buf.Bytes()); len(got) != 1 {
+		t.Fatalf("got %v records after flushStale", len(got))
+	}
+
+	long := "http://a.example/" + strings.Repeat("x", avroBlockSize/2)
+	w.Write(&entry{URL: long})
+	w.Write(&entry{URL: long})
+	if got := readAvro(t, buf.Bytes()); len(got) != 3 {
+		t.Fatalf("got %v records after a large block", len(got))
+	}
+}
+
+// lockedBuffer is a bytes.Buffer written to and read by different
+// goroutines
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) bytes() []byte {
+	b.Lock()
+	defer b.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
+
+func TestAvroIdle(t *testing.T) {
+	var buf lockedBuffer
+	aw := newAvroWriter(&buf)
+	w := newResultWriter(aw)
+	results, done := make(chan *result), make(chan struct{})
+	go writeResults(w, results, done)
+	results <- newResult(&rawRecord{in: &inputFile{}, data: []byte(targetRecord("http://a.example/")), offset: -1})
+
+	// the block is written while no more results arrive, after the
+	// header, which also ends with the sync marker
+	deadline := time.Now().Add(5 * avroBlockAge)
+	for bytes.Count(buf.bytes(), aw.sync[:]) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("the block wasn't written")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(results)
+	<-done
+	if got := readAvro(t, buf.bytes()); len(got) != 1 || got[0].URL != "http://a.example/" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+var (
+	bloomIn       = flag.String("bloom-in", "", "skip URLs in this Bloom filter, of a previous -bloom-out")
+	bloomOut      = flag.String("bloom-out", "", "write a Bloom filter of the URLs seen, including those of -bloom-in, to this file")
+	bloomCapacity = flag.String("bloom-capacity", "10M", "URLs a new -bloom-out filter is sized for")
+	bloomFP       = flag.Float64("bloom-fp", 0.01, "false positive rate of a new -bloom-out filter at -bloom-capacity")
+)
+
+// set from -bloom-capacity
+var bloomSize int64 = 10000000
+
+// bloomFilter is a set of URLs with false positives, for remembering
+// which were seen across runs in a fraction of their size. Its k bit
+// positions are from the two halves of a 128-bit FNV-1a hash.
+type bloomFilter struct {
+	k    uint32
+	bits []uint64
+}
+
+const bloomMagic = "WUBF\x00\x00\x00\x01"
+
+func newBloomFilter(n int64, fp float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	words := (m + 63) / 64
+	k := uint32(math.Round(float64(words*64) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &bloomFilter{k: k, bits: make([]uint64, words)}
+}
+
+func (f *bloomFilter) hashes(url string) (uint64, uint64) {
+	h := fnv.New128a()
+	io.WriteString(h, url)
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
+
+func (f *bloomFilter) add(url string) {
+	h1, h2 := f.hashes(url)
+	m := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *bloomFilter) has(url string) bool {
+	h1, h2 := f.hashes(url)
+	m := uint64(len(f.bits)) * 64
+	for i := uint64(0); i < uint64(f.k); i++ {
+		bit := (h1 + i*h2) % m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WriteTo writes f as its magic, k, its number of 64-bit words and the
+// words, in little-endian order
+func (f *bloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(bloomMagic)
+	binary.Write(bw, binary.LittleEndian, f.k)
+	binary.Write(bw, binary.LittleEndian, uint64(len(f.bits)))
+	if err := binary.Write(bw, binary.LittleEndian, f.bits); err != nil {
+		return 0, err
+	}
+
+	return int64(len(bloomMagic) + 12 + 8*len(f.bits)), bw.Flush()
+}
+
+func readBloomFilter(r io.Reader) (*bloomFilter, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(bloomMagic))
+	var k uint32
+	var words uint64
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	} else if string(magic) != bloomMagic {
+		return nil, errors.New("not a -bloom-out filter")
+	} else if err := binary.Read(br, binary.LittleEndian, &k); err != nil {
+		return nil, err
+	} else if err := binary.Read(br, binary.LittleEndian, &words); err != nil {
+		return nil, err
+	} else if k == 0 || words == 0 || words > 1<<30 {
+		return nil, fmt.Errorf("invalid filter of %v words, k %v", words, k)
+	}
+
+	f := &bloomFilter{k: k, bits: make([]uint64, words)}
+	if err := binary.Read(br, binary.LittleEndian, f.bits); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func loadBloomFilter(path string) (*bloomFilter, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer fp.Close()
+	f, err := readBloomFilter(fp)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	return f, nil
+}
+
+func saveBloomFilter(path string, f *bloomFilter) error {
+	af, err := createAtomic(path)
+	if err != nil {
+		return err
+	} else if _, err := f.WriteTo(af); err != nil {
+		af.Abort()
+		return err
+	}
+
+	return af.Commit()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	f := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		f.add(fmt.Sprintf("http://example.com/%d", i))
+	}
+
+	var fps int
+	for i := 0; i < 10000; i++ {
+		if !f.has(fmt.Sprintf("http://example.com/%d", i)) {
+			t.Fatalf("%v missing", i)
+		} else if f.has(fmt.Sprintf("http://example.org/%d", i)) {
+			fps++
+		}
+	}
+
+	if fps > 200 {
+		t.Errorf("%v false positives of 10000", fps)
+	}
+
+	var buf bytes.Buffer
+	if _, err := f.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := readBloomFilter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if !reflect.DeepEqual(read, f) {
+		t.Error("read a different filter")
+	}
+
+	if _, err := readBloomFilter(bytes.NewReader([]byte("WUBF\x00\x00\x00\x02"))); err == nil {
+		t.Error("read another version")
+	}
+}
+
+func TestBloomIn(t *testing.T) {
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	w.bloom = newBloomFilter(100, 0.01)
+	w.bloom.add("http://a/")
+	w.bloomIn = true
+	for i, url := range []string{"http://a/", "http://b/", "http://a/", "http://c/"} {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{{URL: url}}})
+	}
+
+	if want := "http://b/\nhttp://c/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	} else if !w.bloom.has("http://b/") || !w.bloom.has("http://c/") {
+		t.Error("URLs written aren't added")
+	}
+}
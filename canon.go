@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// sessionParams match the query arguments of session IDs dropped by
+// canonicalURL, by their lowercased name and value, as of the
+// canonicalizer of OpenWayback and pywb
+var sessionParams = []struct {
+	name  *regexp.Regexp
+	value *regexp.Regexp
+}{
+	{regexp.MustCompile(`^jsessionid$`), regexp.MustCompile(``)},
+	{regexp.MustCompile(`^phpsessid$`), regexp.MustCompile(`^[0-9a-z]{32}$`)},
+	{regexp.MustCompile(`^sid$`), regexp.MustCompile(`^[0-9a-z]{32}$`)},
+	{regexp.MustCompile(`^aspsessionid[a-z]{8}$`), regexp.MustCompile(`^[a-z]{24}$`)},
+	{regexp.MustCompile(`^(cfid|cftoken)$`), regexp.MustCompile(``)},
+}
+
+// the session IDs of paths, e.g. /a;jsessionid=x or the ASP.NET
+// /(S(x))/a
+var sessionPath = regexp.MustCompile(`(?i);jsessionid=[^?/]*|/\((?:[a-z]\()?[0-9a-z]{24}\)\)?`)
+
+// canonicalURL returns the key by which wayback replay systems tell URLs
+// apart: the surt of the URL, i.e. lowercased, without scheme, www,
+// default port and fragment, with its query arguments sorted, and with
+// its session IDs dropped
+func canonicalURL(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "#")
+	s = sessionPath.ReplaceAllString(s, "")
+	base, query, hasQuery := strings.Cut(s, "?")
+	if !hasQuery {
+		return surt(s)
+	}
+
+	var args []string
+	for _, arg := range strings.Split(query, "&") {
+		if len(arg) > 0 && !isSessionArg(strings.ToLower(arg)) {
+			args = append(args, arg)
+		}
+	}
+
+	s = base
+	if len(args) > 0 {
+		s += "?" + strings.Join(args, "&")
+	}
+
+	return surt(s)
+}
+
+func isSessionArg(arg string) bool {
+	name, value, _ := strings.Cut(arg, "=")
+	for _, p := range sessionParams {
+		if p.name.MatchString(name) && p.value.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
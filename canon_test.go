@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestCanonicalURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://www.example.com/a?b=2&a=1", "com,example)/a?a=1&b=2"},
+		{"https://Example.com:443/A#frag", "com,example)/a"},
+		{"http://example.com/a?jsessionid=1234&b=1", "com,example)/a?b=1"},
+		{"http://example.com/a;jsessionid=ABC123?b=1", "com,example)/a?b=1"},
+		{"http://example.com/a?PHPSESSID=0123456789abcdef0123456789abcdef", "com,example)/a"},
+		{"http://example.com/a?phpsessid=short", "com,example)/a?phpsessid=short"},
+		{"http://example.com/a?sid=0123456789abcdef0123456789abcdef&x=1", "com,example)/a?x=1"},
+		{"http://example.com/a?ASPSESSIONIDQQGGGNCU=ABCDEFGHIJKLMNOPQRSTUVWX", "com,example)/a"},
+		{"http://example.com/a?CFID=123&CFTOKEN=456&x=1", "com,example)/a?x=1"},
+		{"http://example.com/(S(0123456789abcdefghijklmn))/a.aspx", "com,example)/a.aspx"},
+		{"http://example.com/a?", "com,example)/a"},
+		{"dns:example.com", "dns:example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalURL(tt.in); got != tt.want {
+			t.Errorf("canonicalURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDedupKeyCanonical(t *testing.T) {
+	old := *dedupBy
+	defer func() { *dedupBy = old }()
+	*dedupBy = "canonical"
+
+	a := dedupKey(&entry{URL: "http://www.example.com/a?b=2&a=1&jsessionid=x"})
+	b := dedupKey(&entry{URL: "https://example.com/a?a=1&b=2"})
+	if a != b {
+		t.Errorf("dedupKey: %q != %q", a, b)
+	}
+}
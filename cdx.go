@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// cdx and cdxj index the captures of the inputs rather than list their
+// URLs, so each record is written, also when its URL was seen before
+func isCDXFormat(format string) bool {
+	return format == "cdx" || format == "cdxj"
+}
+
+// isCapture tells whether e is a record that's indexed: a response,
+// revisit or resource by its Target-URI
+func isCapture(e *entry) bool {
+	return len(e.From) == 0 &&
+		(e.Type == "response" || e.Type == "revisit" || e.Type == "resource")
+}
+
+// cdxTimestamp turns a WARC-Date into the 14 digits of a CDX timestamp
+func cdxTimestamp(date string) string {
+	var ts []byte
+	for i := 0; i < len(date) && len(ts) < 14; i++ {
+		if date[i] >= '0' && date[i] <= '9' {
+			ts = append(ts, date[i])
+		}
+	}
+
+	return string(ts)
+}
+
+// cdxKey is the sort key of e in an index, its SURT and timestamp
+func cdxKey(e *entry) string {
+	return surt(e.URL) + " " + cdxTimestamp(e.Date)
+}
+
+// cdxFields returns the CDX values of e, "-" for what isn't known
+func cdxFields(e *entry) (mime, status, digest, length, offset string) {
+	mime, status, digest, length, offset = "-", "-", "-", "-", "-"
+	if e.Type == "revisit" {
+		mime = "warc/revisit"
+	} else if len(e.MIME) > 0 {
+		mime = e.MIME
+	}
+
+	if e.Status > 0 {
+		status = strconv.Itoa(e.Status)
+	}
+
+	if len(e.Digest) > 0 {
+		digest = strings.TrimPrefix(e.Digest, "sha1:")
+	}
+
+	if e.CompressedLength >= 0 {
+		length = strconv.FormatInt(e.CompressedLength, 10)
+	}
+
+	if e.Offset >= 0 {
+		offset = strconv.FormatInt(e.Offset, 10)
+	}
+
+	return
+}
+
+// cdxjWriter writes a SURT key, a timestamp and a JSON object per capture
+type cdxjWriter struct {
+	w io.Writer
+}
+
+type cdxjFields struct {
+	URL      string `json:"url"`
+	MIME     string `json:"mime,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	Length   string `json:"length,omitempty"`
+	Offset   string `json:"offset,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+func (w *cdxjWriter) Write(e *entry) error {
+	if !isCapture(e) {
+		return nil
+	}
+
+	f := cdxjFields{URL: e.URL, Filename: e.File}
+	f.MIME, f.Status, f.Digest, f.Length, f.Offset = cdxFields(e)
+	for _, v := range []*string{&f.MIME, &f.Status, &f.Digest, &f.Length, &f.Offset} {
+		if *v == "-" {
+			*v = ""
+		}
+	}
+
+	data, err := json.Marshal(&f)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w.w, "%v %s\n", cdxKey(e), data)
+	return err
+}
+
+func (w *cdxjWriter) Close() error {
+	return nil
+}
+
+// cdxWriter writes the classic 11 field CDX format
+type cdxWriter struct {
+	w      io.Writer
+	header bool
+}
+
+const cdxHeader = " CDX N b a m s k r M S V g\n"
+
+func (w *cdxWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+
+	w.header = true
+	_, err := io.WriteString(w.w, cdxHeader)
+	return err
+}
+
+func (w *cdxWriter) Write(e *entry) error {
+	if !isCapture(e) {
+		return nil
+	} else if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	filename := "-"
+	if len(e.File) > 0 {
+		filename = e.File
+	}
+
+	ts := cdxTimestamp(e.Date)
+	if len(ts) == 0 {
+		ts = "-"
+	}
+
+	mime, status, digest, length, offset := cdxFields(e)
+	_, err := fmt.Fprintf(w.w, "%v %v %v %v %v %v - - %v %v %v\n", surt(e.URL), ts,
+		strings.ReplaceAll(e.URL, " ", "%20"), mime, status, digest, length, offset, filename)
+	return err
+}
+
+func (w *cdxWriter) skipHeader() {
+	w.header = true
+}
+
+func (w *cdxWriter) Close() error {
+	return w.writeHeader()
+}
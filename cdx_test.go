@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCDXJ(t *testing.T) {
+	http := "Content-Type: application/http; msgtype=response\r\n"
+	resp := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n<html>"
+	recs := []string{
+		warcRecord("WARC-Type: warcinfo\r\n", "software: test"),
+		warcRecord("WARC-Type: request\r\nWARC-Target-URI: http://www.example.com/b\r\n", "GET /b HTTP/1.1\r\n\r\n"),
+		warcRecord("WARC-Type: response\r\nWARC-Target-URI: http://www.example.com/b\r\n"+
+			"WARC-Date: 2024-01-02T03:04:05Z\r\nWARC-Payload-Digest: sha1:AAAA\r\n"+http, resp),
+		warcRecord("WARC-Type: revisit\r\nWARC-Target-URI: http://www.example.com/b\r\n"+
+			"WARC-Date: 2024-02-02T03:04:05Z\r\nWARC-Payload-Digest: sha1:AAAA\r\n"+http, "HTTP/1.1 200 OK\r\n\r\n"),
+		warcRecord("WARC-Type: resource\r\nWARC-Target-URI: http://a.example.org/x.png\r\n"+
+			"WARC-Date: 2024-03-02T03:04:05Z\r\nContent-Type: image/png\r\n", "\x89PNG"),
+	}
+
+	var blobs [][]byte
+	for _, rec := range recs {
+		blobs = append(blobs, []byte(rec))
+	}
+
+	dir := t.TempDir()
+	comp, plain := gzipMembers(t, blobs, gzip.DefaultCompression)
+	gz := filepath.Join(dir, "a.warc.gz")
+	os.WriteFile(gz, comp, 0644)
+	// an uncompressed file, whose records are found by offset too
+	pl := filepath.Join(dir, "a.warc")
+	os.WriteFile(pl, plain, 0644)
+
+	defer func(o, l bool) { trackOffsets, trackLengths = o, l }(trackOffsets, trackLengths)
+	trackOffsets, trackLengths = true, true
+	for _, path := range []string{gz, pl} {
+		var out bytes.Buffer
+		w := newResultWriter(&cdxjWriter{&out})
+		w.existing = nil
+		runInputs(w, []*inputFile{{path: path}})
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		keys := []string{
+			"com,example)/b 20240102030405",
+			"com,example)/b 20240202030405",
+			"org,example,a)/x.png 20240302030405",
+		}
+
+		if len(lines) != len(keys) {
+			t.Fatalf("%v: got %q", path, lines)
+		}
+
+		for i, line := range lines {
+			if !strings.HasPrefix(line, keys[i]+" {") {
+				t.Fatalf("%v: got %q, want key %q", path, line, keys[i])
+			}
+
+			var f struct {
+				URL, MIME, Status, Digest, Filename string
+				Offset, Length                      json.Number
+			}
+
+			if err := json.Unmarshal([]byte(line[len(keys[i])+1:]), &f); err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{"text/html 200 AAAA", "warc/revisit 200 AAAA", "image/png  "}[i]
+			if got := f.MIME + " " + f.Status + " " + f.Digest; got != want || f.Filename != path {
+				t.Errorf("%v: got %q in %v, want %q", path, got, f.Filename, want)
+			}
+
+			// the offset and length are those of the record
+			offset, _ := f.Offset.Int64()
+			length, _ := f.Length.Int64()
+			r, err := openRange(path, offset, length)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, _ := io.ReadAll(r)
+			r.Close()
+			if path == gz {
+				zr, err := gzip.NewReader(bytes.NewReader(data))
+				if err != nil {
+					t.Fatalf("%v %v: %v", path, i, err)
+				}
+
+				data, _ = io.ReadAll(zr)
+			}
+
+			if want := recs[i+2]; string(data) != want {
+				t.Errorf("%v: record %v at %v+%v is %q, want %q", path, i, offset, length, data, want)
+			}
+		}
+	}
+}
+
+func TestCDX(t *testing.T) {
+	var out bytes.Buffer
+	w := &cdxWriter{w: &out}
+	w.Write(&entry{URL: "http://example.com/a b", Type: "response", Date: "2024-01-02T03:04:05Z",
+		MIME: "text/html", Status: 200, Digest: "sha1:AAAA", File: "a.warc.gz", Offset: 10, CompressedLength: 20})
+	w.Write(&entry{URL: "http://example.com/", Type: "request"})
+	w.Write(&entry{URL: "http://example.com/c", Type: "response", Offset: -1, CompressedLength: -1})
+	w.Close()
+	want := cdxHeader +
+		"com,example)/a%20b 20240102030405 http://example.com/a%20b text/html 200 AAAA - - 20 10 a.warc.gz\n" +
+		"com,example)/c - http://example.com/c - - - - - - - -\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+var charsets = flag.String("charset", "", "only output the URLs of responses in these character encodings, by their Content-Type or HTML meta tags, comma separated, e.g. utf-8,iso-8859-1")
+
+// the most of an HTML body looked at for a meta charset, as by HTML
+const charsetSniffSize = 1024
+
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_.:-]+)`)
+
+// charsetAliases are the keys of other names of some charsets
+var charsetAliases = map[string]string{
+	"latin1":  "iso88591",
+	"l1":      "iso88591",
+	"ascii":   "usascii",
+	"cp1252":  "windows1252",
+	"sjis":    "shiftjis",
+	"xsjis":   "shiftjis",
+	"gb2312":  "gbk",
+	"ksc5601": "euckr",
+}
+
+// charsetKey returns the name of a charset without case, punctuation and
+// aliases, so that e.g. UTF8 and utf-8 compare equal
+func charsetKey(name string) string {
+	key := strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			return r
+		} else if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+
+		return -1
+	}, name)
+
+	if alias, ok := charsetAliases[key]; ok {
+		return alias
+	}
+
+	return key
+}
+
+// responseCharset returns the charset of the HTTP response of block, by
+// its Content-Type or, of HTML, its meta tags, or an empty string
+func responseCharset(block []byte, mediaType string) string {
+	for _, v := range headerValues(httpHeader(block), "Content-Type") {
+		if _, params, err := mime.ParseMediaType(v); err == nil && len(params["charset"]) > 0 {
+			return strings.ToLower(strings.TrimSpace(params["charset"]))
+		}
+	}
+
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return ""
+	}
+
+	_, body, err := httpBody(block, charsetSniffSize)
+	if err != nil {
+		return ""
+	} else if m := metaCharset.FindSubmatch(body); m != nil {
+		return string(bytes.ToLower(m[1]))
+	}
+
+	return ""
+}
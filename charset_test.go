@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestCharsetKey(t *testing.T) {
+	for _, names := range [][]string{
+		{"utf-8", "UTF8", "utf_8"},
+		{"iso-8859-1", "ISO_8859-1", "latin1", "iso8859-1"},
+		{"Shift_JIS", "sjis"},
+	} {
+		for _, name := range names[1:] {
+			if charsetKey(name) != charsetKey(names[0]) {
+				t.Errorf("%v: got %v, want %v", name, charsetKey(name), charsetKey(names[0]))
+			}
+		}
+	}
+}
+
+func TestResponseCharset(t *testing.T) {
+	for _, tt := range []struct {
+		header, body, mime, want string
+	}{
+		{"Content-Type: text/html; charset=UTF-8\r\n", `<meta charset="iso-8859-1">`, "text/html", "utf-8"},
+		{"Content-Type: text/html\r\n", `<head><meta charset="Windows-1252"></head>`, "text/html", "windows-1252"},
+		{"Content-Type: text/html\r\n", `<meta http-equiv="Content-Type" content="text/html; charset=euc-jp">`, "text/html", "euc-jp"},
+		{"Content-Type: text/plain\r\n", `<meta charset="utf-8">`, "text/plain", ""},
+		{"Content-Type: text/html\r\n", "<p>no charset</p>", "text/html", ""},
+	} {
+		block := "HTTP/1.1 200 OK\r\n" + tt.header + "\r\n" + tt.body
+		if got := responseCharset([]byte(block), tt.mime); got != tt.want {
+			t.Errorf("%q %q: got %q, want %q", tt.header, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestCharsetFilter(t *testing.T) {
+	withFilters(t, func() { *charsets = "utf8,latin1" })
+	var entries []*entry
+	for i, http := range []string{
+		"Content-Type: text/html; charset=utf-8\r\n\r\n",
+		"Content-Type: text/html\r\n\r\n<meta charset=ISO-8859-1>",
+		"Content-Type: text/html; charset=windows-1252\r\n\r\n",
+		"Content-Type: image/png\r\n\r\n",
+	} {
+		rec := warcRecord(fmt.Sprintf("WARC-Type: response\r\nWARC-Target-URI: http://a/%v\r\n", i)+
+			"Content-Type: application/http; msgtype=response\r\n", "HTTP/1.1 200 OK\r\n"+http)
+		res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+		entries = append(entries, res.entries...)
+	}
+
+	if got := entryURLs(entries); !reflect.DeepEqual(got, []string{"http://a/0", "http://a/1"}) {
+		t.Errorf("got %v", got)
+	}
+}
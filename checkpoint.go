@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	checkpointFile  = flag.String("checkpoint", "", "periodically save progress and seen URLs to this file")
+	checkpointEvery = flag.Duration("checkpoint-interval", time.Minute, "how often to save -checkpoint")
+	resumeFile      = flag.String("resume", "", "continue the run that saved this checkpoint (with the same inputs)")
+)
+
+// progress is how far the results of an input have been written. An
+// interrupted input is resumed by reading it from Offset and skipping
+// the first Skip records. Offset is the compressed offset of the last
+// written record whose offset is known, or where reading started.
+type progress struct {
+	Index  int    `json:"index"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Skip   int    `json:"skip"`
+	Done   bool   `json:"done,omitempty"`
+
+	next    int
+	pending map[int]*result
+}
+
+// checkpoint is the state of a run. Part is the last -o part written by
+// a rotated output, which a resumed run continues after.
+type checkpoint struct {
+	Records  int         `json:"records"`
+	Inputs   []*progress `json:"inputs"`
+	URLs     []string    `json:"urls"`
+	Segments []string    `json:"segments,omitempty"`
+	Part     int         `json:"part,omitempty"`
+}
+
+// checkpointer saves the state of a resultWriter every interval
+type checkpointer struct {
+	path     string
+	interval time.Duration
+	last     time.Time
+}
+
+func (c *checkpointer) maybeSave(w *resultWriter) {
+	if time.Since(c.last) >= c.interval {
+		c.save(w)
+	}
+}
+
+// flusher is an output that must be flushed for what's been written to
+// it to be kept, e.g. a database transaction
+type flusher interface {
+	Flush() error
+}
+
+func (c *checkpointer) save(w *resultWriter) {
+	c.last = time.Now()
+	if f, ok := w.out.(flusher); ok && w.err == nil {
+		// the checkpoint must not be ahead of the output
+		if w.err = f.Flush(); w.err != nil {
+			return
+		}
+	}
+
+	if err := saveCheckpoint(c.path, w); err != nil {
+		slog.Error("saveCheckpoint", "path", c.path, "err", err)
+	}
+}
+
+// saveCheckpoint writes the state of w to a temporary file which is then
+// renamed over path, so that an interrupted save leaves the previous
+// checkpoint in place
+func saveCheckpoint(path string, w *resultWriter) error {
+	ck := checkpoint{Records: w.nrecords, URLs: make([]string, 0, len(w.existing))}
+	for _, p := range w.inputs {
+		ck.Inputs = append(ck.Inputs, p)
+	}
+
+	sort.Slice(ck.Inputs, func(i, j int) bool { return ck.Inputs[i].Index < ck.Inputs[j].Index })
+	for url := range w.existing {
+		ck.URLs = append(ck.URLs, url)
+	}
+
+	for id := range w.segments {
+		ck.Segments = append(ck.Segments, id)
+	}
+
+	if rw, ok := w.out.(*rotatingWriter); ok {
+		ck.Part = rw.part
+	}
+
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(f).Encode(&ck); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	var ck checkpoint
+	if err := json.NewDecoder(f).Decode(&ck); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	return &ck, nil
+}
+
+// resume restores the state of w from ck, and returns the inputs of
+// paths that remain to be read. The inputs must be the same as those of
+// the run that saved ck.
+func resume(ck *checkpoint, paths []string, w *resultWriter) ([]*inputFile, error) {
+	w.nrecords = ck.Records
+	for _, url := range ck.URLs {
+		if w.bloom != nil {
+			w.bloom.add(url)
+		}
+
+		if w.existing == nil {
+			break
+		}
+
+		var x struct{}
+		w.existing[url] = x
+	}
+
+	for _, id := range ck.Segments {
+		var x struct{}
+		w.segments[id] = x
+	}
+
+	for _, p := range ck.Inputs {
+		if p.Index >= len(paths) || paths[p.Index] != p.Path {
+			return nil, fmt.Errorf("checkpoint input %v (%v) doesn't match the inputs given",
+				p.Index, p.Path)
+		}
+
+		w.inputs[p.Index] = p
+	}
+
+	var inputs []*inputFile
+	for i, path := range paths {
+		in := &inputFile{index: i, path: path, offset: *startOffset}
+		if p, ok := w.inputs[i]; ok {
+			if p.Done {
+				continue
+			}
+
+			in.offset, in.skip = p.Offset, p.Skip
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	return inputs, nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func targetRecord(url string) string {
+	return warcRecord("WARC-Type: response\r\nWARC-Target-URI: "+url+"\r\n", "x")
+}
+
+func TestMemberReaderOffsets(t *testing.T) {
+	blobs := [][]byte{
+		[]byte(targetRecord("http://a/")),
+		[]byte(targetRecord("http://b/") + targetRecord("http://c/")),
+		[]byte(targetRecord("http://d/")),
+	}
+
+	var comp bytes.Buffer
+	var starts []int64
+	for _, blob := range blobs {
+		starts = append(starts, int64(comp.Len()))
+		zw := gzip.NewWriter(&comp)
+		zw.Write(blob)
+		zw.Close()
+	}
+
+	r := newMemberReader(bufio.NewReader(bytes.NewReader(comp.Bytes())))
+	var got []int64
+	for {
+		_, err := r.NextRaw()
+		if err != nil {
+			break
+		}
+
+		got = append(got, r.Offset())
+	}
+
+	want := []int64{starts[0], starts[1], -1, starts[2]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got offsets %v, want %v", got, want)
+	}
+}
+
+// runInputs reads inputs through the pipeline into w
+func runInputs(w *resultWriter, inputs []*inputFile) {
+	recs := make(chan *rawRecord)
+	results := make(chan *result)
+	done := make(chan struct{})
+	go readRecords(inputs, recs, nil, nil)
+	processRecords(recs, results, 3)
+	writeResults(w, results, done)
+	<-done
+}
+
+// feed writes the first n records of an input to w, as if the run was
+// interrupted there, or all of them if n is negative
+func feed(t *testing.T, w *resultWriter, in *inputFile, n int) {
+	recs := make(chan *rawRecord, 100)
+	f, err := openInput(in.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if err := readInput(in, f, recs, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	close(recs)
+	for rec := range recs {
+		if n == 0 {
+			return
+		}
+
+		n--
+		w.add(newResult(rec))
+	}
+
+	w.add(&result{rec: &rawRecord{in: in, seq: in.nrecs, last: true}})
+}
+
+func TestCheckpointResume(t *testing.T) {
+	var recs []string
+	for i := 0; i < 20; i++ {
+		// some URLs repeat, to check that the seen URLs are restored
+		recs = append(recs, targetRecord(fmt.Sprintf("http://example.com/%v", i%7)))
+	}
+
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "a.warc")
+	os.WriteFile(plain, []byte(strings.Join(recs, "")), 0644)
+	var blobs [][]byte
+	for _, rec := range recs {
+		blobs = append(blobs, []byte(rec))
+	}
+
+	comp, _ := gzipMembers(t, blobs, gzip.DefaultCompression)
+	gz := filepath.Join(dir, "b.warc.gz")
+	os.WriteFile(gz, comp, 0644)
+
+	defer func(track bool) { trackOffsets = track }(trackOffsets)
+	paths := []string{plain, gz, plain}
+	for _, track := range []bool{false, true} {
+		trackOffsets = track
+		var full bytes.Buffer
+		var inputs []*inputFile
+		for i, path := range paths {
+			inputs = append(inputs, &inputFile{index: i, path: path})
+		}
+
+		runInputs(newResultWriter(newEntryWriter("text", &full)), inputs)
+		for _, stop := range []struct{ file, n int }{{0, 0}, {0, 5}, {1, 9}, {2, 19}} {
+			var out bytes.Buffer
+			ckpath := filepath.Join(dir, "ckpt")
+			w := newResultWriter(newEntryWriter("text", &out))
+			w.ckpt = &checkpointer{path: ckpath, interval: time.Hour}
+			for i := 0; i <= stop.file; i++ {
+				n := -1
+				if i == stop.file {
+					n = stop.n
+				}
+
+				feed(t, w, &inputFile{index: i, path: paths[i]}, n)
+			}
+
+			if err := saveCheckpoint(ckpath, w); err != nil {
+				t.Fatal(err)
+			}
+
+			ck, err := loadCheckpoint(ckpath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w = newResultWriter(newEntryWriter("text", &out))
+			inputs, err := resume(ck, paths, w)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runInputs(w, inputs)
+			if out.String() != full.String() {
+				t.Errorf("track=%v stop=%v: got %q, want %q", track, stop, out.String(), full.String())
+			} else if w.nrecords != 3*len(recs) {
+				t.Errorf("track=%v stop=%v: %v records", track, stop, w.nrecords)
+			}
+		}
+	}
+}
+
+func TestResumeMismatch(t *testing.T) {
+	ck := &checkpoint{Inputs: []*progress{{Index: 1, Path: "b.warc"}}}
+	if _, err := resume(ck, []string{"a.warc", "c.warc"}, newResultWriter(nil)); err == nil {
+		t.Fatal("resumed with different inputs")
+	}
+}
+
+func TestCheckpointRotatedResume(t *testing.T) {
+	var recs []string
+	for i := 0; i < 20; i++ {
+		recs = append(recs, targetRecord(fmt.Sprintf("http://example.com/%v", i)))
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.warc")
+	os.WriteFile(input, []byte(strings.Join(recs, "")), 0644)
+	paths := []string{input, input}
+	out := filepath.Join(dir, "out", "urls.txt")
+	os.Mkdir(filepath.Dir(out), 0755)
+	readParts := func() string {
+		var all string
+		entries, _ := os.ReadDir(filepath.Dir(out))
+		for i, de := range entries {
+			if want := filepath.Base(partName(out, i+1)); de.Name() != want {
+				t.Fatalf("got part %v, want %v", de.Name(), want)
+			}
+
+			data, _ := os.ReadFile(filepath.Join(filepath.Dir(out), de.Name()))
+			all += string(data)
+		}
+
+		return all
+	}
+
+	// saved after 7 URLs, completing part 3, then interrupted after 4 more
+	// parts, which the resumed run replaces
+	ckpath := filepath.Join(dir, "ckpt")
+	rw := &rotatingWriter{path: out, maxCount: 3}
+	w := newResultWriter(rw)
+	w.ckpt = &checkpointer{path: ckpath, interval: time.Hour}
+	feed(t, w, &inputFile{index: 0, path: input}, 7)
+	w.ckpt.save(w)
+	feed(t, w, &inputFile{index: 0, path: input}, -1)
+	rw.Abort()
+	if entries, _ := os.ReadDir(filepath.Dir(out)); len(entries) != 7 {
+		t.Fatalf("interrupted with %v parts, want 7", len(entries))
+	}
+
+	ck, err := loadCheckpoint(ckpath)
+	if err != nil {
+		t.Fatal(err)
+	} else if ck.Part != 3 {
+		t.Fatalf("checkpoint at part %v, want 3", ck.Part)
+	}
+
+	rw = &rotatingWriter{path: out, maxCount: 100}
+	w = newResultWriter(rw)
+	inputs, err := resume(ck, paths, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rw.resume(ck.Part)
+	runInputs(w, inputs)
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var want string
+	for i := 0; i < 20; i++ {
+		want += fmt.Sprintf("http://example.com/%v\n", i)
+	}
+
+	if got := readParts(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
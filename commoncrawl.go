@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// replaceable in tests
+var commonCrawlBase = "https://data.commoncrawl.org/"
+
+// commonCrawlPaths returns the WARC URLs of a crawl, e.g. CC-MAIN-2024-10,
+// limited to the first nsegments segments of the listing if nsegments > 0.
+func commonCrawlPaths(crawl string, nsegments int) ([]string, error) {
+	if !strings.HasPrefix(crawl, "CC-") || strings.ContainsAny(crawl, "/?#") {
+		return nil, fmt.Errorf("%v: not a Common Crawl crawl id", crawl)
+	}
+
+	paths, err := readPathsFile(commonCrawlBase+"crawl-data/"+crawl+"/warc.paths.gz",
+		commonCrawlBase)
+	if err != nil {
+		return nil, err
+	}
+
+	return limitSegments(paths, nsegments), nil
+}
+
+// limitSegments returns the paths of the first nsegments segments, of
+// those listed in segment order, or all of them if nsegments <= 0
+func limitSegments(paths []string, nsegments int) []string {
+	if nsegments <= 0 {
+		return paths
+	}
+
+	var last string
+	var nseen int
+	for i, path := range paths {
+		if segment := commonCrawlSegment(path); segment != last {
+			last = segment
+			if nseen++; nseen > nsegments {
+				return paths[:i]
+			}
+		}
+	}
+
+	return paths
+}
+
+// .../segments/<segment>/warc/<file>.warc.gz
+func commonCrawlSegment(path string) string {
+	_, rest, ok := strings.Cut(path, "/segments/")
+	if !ok {
+		return ""
+	}
+
+	segment, _, _ := strings.Cut(rest, "/")
+	return segment
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testSegment = "crawl-data/CC-MAIN-2024-10/segments/"
+
+var testPaths = []string{
+	testSegment + "1.1/warc/a.warc.gz",
+	testSegment + "1.1/warc/b.warc.gz",
+	testSegment + "2.2/warc/c.warc.gz",
+	testSegment + "3.3/warc/d.warc.gz",
+	testSegment + "3.3/warc/e.warc.gz",
+}
+
+func TestLimitSegments(t *testing.T) {
+	tests := []struct {
+		nsegments int
+		want      []string
+	}{
+		{-1, testPaths},
+		{0, testPaths},
+		{1, testPaths[:2]},
+		{2, testPaths[:3]},
+		{3, testPaths},
+		{10, testPaths},
+	}
+
+	for _, tt := range tests {
+		if got := limitSegments(testPaths, tt.nsegments); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v segments: got %v, want %v", tt.nsegments, got, tt.want)
+		}
+	}
+
+	if got := limitSegments(nil, 1); len(got) != 0 {
+		t.Errorf("no paths: got %v", got)
+	}
+}
+
+func TestCommonCrawlSegment(t *testing.T) {
+	tests := map[string]string{
+		testPaths[0]: "1.1",
+		"crawl-data/CC-MAIN-2024-10/segments/1707947473347.0/warc/x.warc.gz": "1707947473347.0",
+		"crawl-data/CC-MAIN-2024-10/warc.paths.gz":                           "",
+	}
+
+	for path, want := range tests {
+		if got := commonCrawlSegment(path); got != want {
+			t.Errorf("%v: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestCommonCrawlPaths(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/crawl-data/CC-MAIN-2024-10/warc.paths.gz" {
+			http.NotFound(w, r)
+			return
+		}
+
+		zw := gzip.NewWriter(w)
+		for _, path := range testPaths {
+			zw.Write([]byte(path + "\n"))
+		}
+
+		zw.Close()
+	}))
+	defer srv.Close()
+	defer func(base string) { commonCrawlBase = base }(commonCrawlBase)
+	commonCrawlBase = srv.URL + "/"
+
+	paths, err := commonCrawlPaths("CC-MAIN-2024-10", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{srv.URL + "/" + testPaths[0], srv.URL + "/" + testPaths[1], srv.URL + "/" + testPaths[2]}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+
+	for _, crawl := range []string{"MAIN-2024-10", "CC-MAIN-2024-10/../x", "CC-MAIN?x"} {
+		if _, err := commonCrawlPaths(crawl, 0); err == nil {
+			t.Errorf("%v: no error", crawl)
+		}
+	}
+
+	if _, err := commonCrawlPaths("CC-MAIN-1999-01", 0); err == nil {
+		t.Error("listed a missing crawl")
+	}
+}
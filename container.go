@@ -0,0 +1,196 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+)
+
+var memberGlob = flag.String("member-glob", "", "only read tar and ZIP members matching this glob (default: by WARC extension)")
+
+// isMember tells whether an archive member should be read. The glob is
+// matched against both the full member name and its base name.
+func isMember(name string) bool {
+	if len(*memberGlob) == 0 {
+		return hasWARCExt(name)
+	}
+
+	if ok, _ := path.Match(*memberGlob, name); ok {
+		return true
+	}
+
+	ok, _ := path.Match(*memberGlob, path.Base(name))
+	return ok
+}
+
+func isTarName(name string) bool {
+	return strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") ||
+		strings.HasSuffix(name, ".tgz")
+}
+
+// The ustar magic is at offset 257 of the first header block. Of a stream,
+// only what has been read already is looked at, as a small first record
+// may be all there is for a while, while a tar header is written at once.
+func isTarMagic(br *bufio.Reader, stream bool) bool {
+	n := 263
+	if stream && br.Buffered() < n {
+		return false
+	}
+
+	head, _ := br.Peek(n)
+	return len(head) == 263 && bytes.HasPrefix(head[257:], []byte("ustar"))
+}
+
+// isStream tells whether f is a pipe, socket or device rather than a file
+func isStream(f io.Reader) bool {
+	st, ok := f.(interface{ Stat() (os.FileInfo, error) })
+	if !ok {
+		return false
+	}
+
+	fi, err := st.Stat()
+	return err == nil && !fi.Mode().IsRegular() && !fi.IsDir()
+}
+
+func isZipName(name string) bool {
+	return strings.HasSuffix(name, ".zip")
+}
+
+func isZipMagic(br *bufio.Reader) bool {
+	magic, _ := br.Peek(4)
+	return bytes.Equal(magic, []byte("PK\x03\x04"))
+}
+
+// readInput reads the records of an opened input. Containers, tar and
+// ZIP archives, are traversed and each WARC member read in turn.
+func readInput(in *inputFile, f io.Reader, recs chan *rawRecord, nrecords *int) error {
+	path := in.path
+	in.stream = isStream(f)
+	br := bufio.NewReaderSize(f, 64*1024)
+	offsets := true
+	if isAgeMagic(br) {
+		if len(*decryptIdentity) == 0 {
+			return errors.New("encrypted with age, but no -decrypt-identity given")
+		}
+
+		plain, err := decryptAge(br)
+		if err != nil {
+			return err
+		}
+
+		// offsets in the plaintext aren't offsets in the input
+		f, br, offsets = plain, bufio.NewReaderSize(plain, 64*1024), false
+		path = strings.TrimSuffix(path, ".age")
+	}
+
+	format := detectFormat(br)
+	if isZipMagic(br) || (isZipName(path) && format == "plain") {
+		return readZip(in, f, br, recs, nrecords)
+	} else if format == "plain" && isTarMagic(br, in.stream) {
+		return readTar(in, br, recs, nrecords)
+	} else if isTarName(path) && format == "gzip" {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+
+		defer zr.Close()
+		return readTar(in, zr, recs, nrecords)
+	}
+
+	return readFile(in, path, br, offsets, recs, nrecords)
+}
+
+// readTar reads the WARC members of a tar archive. Members are named
+// path!member in logs. A broken member is logged and skipped, but a
+// broken archive ends the traversal.
+func readTar(in *inputFile, r io.Reader, recs chan *rawRecord, nrecords *int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !isMember(hdr.Name) {
+			continue
+		}
+
+		name := in.path + "!" + hdr.Name
+		if err := readFile(in, name, tr, false, recs, nrecords); err != nil {
+			slog.Error("readTar", "file", name, "err", err)
+		}
+	}
+}
+
+// readZip reads the WARC members of a ZIP archive. The central directory
+// is at the end, so inputs that can't be read at random (stdin, remote
+// files) are spooled to a temporary file first. br must wrap f.
+func readZip(in *inputFile, f io.Reader, br *bufio.Reader, recs chan *rawRecord,
+	nrecords *int) error {
+	ra, size, tmp, err := zipSource(f, br)
+	if err != nil {
+		return err
+	} else if tmp != nil {
+		defer tmp.Close()
+	}
+
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || !isMember(zf.Name) {
+			continue
+		}
+
+		name := in.path + "!" + zf.Name
+		rc, err := zf.Open()
+		if err == nil {
+			err = readFile(in, name, rc, false, recs, nrecords)
+			rc.Close()
+		}
+
+		if err != nil {
+			slog.Error("readZip", "file", name, "err", err)
+		}
+	}
+
+	return nil
+}
+
+// zipSource returns f if it can be read at random, otherwise the
+// temporary file it was spooled to, which the caller closes
+func zipSource(f io.Reader, br *bufio.Reader) (io.ReaderAt, int64, *os.File, error) {
+	if file, ok := f.(*os.File); ok {
+		if fi, err := file.Stat(); err == nil && fi.Mode().IsRegular() {
+			return file, fi.Size(), nil, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "warc-urls-*.zip")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, br)
+	if err != nil {
+		tmp.Close()
+		return nil, 0, nil, err
+	}
+
+	return tmp, size, tmp, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// gzipped concatenates s as a single gzip member
+func gzipped(t *testing.T, s string) []byte {
+	comp, _ := gzipMembers(t, [][]byte{[]byte(s)}, gzip.DefaultCompression)
+	return comp
+}
+
+func tarball(t *testing.T, files map[string][]byte, names ...string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		data := files[name]
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		tw.Write(data)
+	}
+
+	tw.Close()
+	return buf.Bytes()
+}
+
+// collect reads the records of in through readInput and returns their blocks
+func collect(t *testing.T, path string, in []byte) ([]string, error) {
+	recs := make(chan *rawRecord)
+	var got []string
+	done := make(chan struct{})
+	go func() {
+		for rec := range recs {
+			if rec.data != nil {
+				got = append(got, string(recordBlock(rec.data)))
+			}
+		}
+
+		close(done)
+	}()
+
+	var nrecords int
+	err := readInput(&inputFile{path: path}, bytes.NewReader(in), recs, &nrecords)
+	close(recs)
+	<-done
+	if nrecords != len(got) {
+		t.Errorf("%v: counted %v records, got %v", path, nrecords, len(got))
+	}
+
+	return got, err
+}
+
+func TestReadTar(t *testing.T) {
+	files := map[string][]byte{
+		"a.warc.gz":      gzipped(t, warcRecord("", "one")+warcRecord("", "two")),
+		"README":         []byte("not a WARC"),
+		"dir/b.warc":     []byte(warcRecord("", "three")),
+		"broken.warc.gz": []byte("\x1f\x8b garbage"),
+	}
+
+	tb := tarball(t, files, "a.warc.gz", "README", "broken.warc.gz", "dir/b.warc")
+	want := []string{"one", "two", "three"}
+	tests := []struct {
+		path string
+		in   []byte
+	}{
+		{"crawl.tar", tb},
+		{"-", tb},
+		{"crawl.tar.gz", gzipped(t, string(tb))},
+		{"crawl.tgz", gzipped(t, string(tb))},
+	}
+
+	for _, tt := range tests {
+		got, err := collect(t, tt.path, tt.in)
+		if err != nil {
+			t.Errorf("%v: %v", tt.path, err)
+		} else if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %q, want %q", tt.path, got, want)
+		}
+	}
+}
+
+func TestReadInputNotTar(t *testing.T) {
+	got, err := collect(t, "x.warc.gz", gzipped(t, warcRecord("", "one")))
+	if err != nil || !reflect.DeepEqual(got, []string{"one"}) {
+		t.Fatalf("got %q, %v", got, err)
+	}
+}
+
+func zipArchive(t *testing.T, files map[string][]byte, names ...string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(files[name])
+	}
+
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReadZip(t *testing.T) {
+	files := map[string][]byte{
+		"warcs/a.warc.gz": gzipped(t, warcRecord("", "one")+warcRecord("", "two")),
+		"warcs/b.warc":    []byte(warcRecord("", "three")),
+		"urls.txt":        []byte("http://example.com/"),
+		"other/c.warc":    []byte(warcRecord("", "four")),
+	}
+
+	zb := zipArchive(t, files, "warcs/a.warc.gz", "urls.txt", "warcs/b.warc", "other/c.warc")
+	tests := []struct {
+		path string
+		glob string
+		want []string
+	}{
+		{"bundle.zip", "", []string{"one", "two", "three", "four"}},
+		{"-", "", []string{"one", "two", "three", "four"}},
+		{"bundle.zip", "warcs/*", []string{"one", "two", "three"}},
+		{"bundle.zip", "*.warc", []string{"three", "four"}},
+		{"bundle.zip", "*.txt", nil},
+	}
+
+	defer func(glob string) { *memberGlob = glob }(*memberGlob)
+	for _, tt := range tests {
+		*memberGlob = tt.glob
+		got, err := collect(t, tt.path, zb)
+		if err != nil {
+			t.Errorf("%v/%v: %v", tt.path, tt.glob, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v/%v: got %q, want %q", tt.path, tt.glob, got, tt.want)
+		}
+	}
+}
+
+func TestReadZipFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "bundle.zip")
+	zb := zipArchive(t, map[string][]byte{"a.warc": []byte(warcRecord("", "one"))}, "a.warc")
+	if err := os.WriteFile(name, zb, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	recs := make(chan *rawRecord, 10)
+	if err := readInput(&inputFile{path: name}, f, recs, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	close(recs)
+	if rec := <-recs; string(recordBlock(rec.data)) != "one" {
+		t.Fatalf("got %q", rec.data)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"sync"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+var decryptIdentity = flag.String("decrypt-identity", "", "age identity file to decrypt age-encrypted inputs with")
+
+var (
+	ageOnce       sync.Once
+	ageIdentities []age.Identity
+	ageErr        error
+)
+
+const ageMagic = "age-encryption.org/v1\n"
+
+func isAgeMagic(br *bufio.Reader) bool {
+	head, _ := br.Peek(len(armor.Header))
+	return bytes.HasPrefix(head, []byte(ageMagic)) || bytes.Equal(head, []byte(armor.Header))
+}
+
+func getAgeIdentities() ([]age.Identity, error) {
+	ageOnce.Do(func() {
+		f, err := os.Open(*decryptIdentity)
+		if err != nil {
+			ageErr = err
+			return
+		}
+
+		defer f.Close()
+		ageIdentities, ageErr = age.ParseIdentities(f)
+	})
+
+	return ageIdentities, ageErr
+}
+
+// decryptAge returns the plaintext of an age-encrypted input, binary or
+// armored, read from br. The plaintext is decrypted as it's read and is
+// never written out.
+func decryptAge(br *bufio.Reader) (io.Reader, error) {
+	ids, err := getAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = br
+	if head, _ := br.Peek(len(armor.Header)); bytes.Equal(head, []byte(armor.Header)) {
+		r = armor.NewReader(br)
+	}
+
+	return age.Decrypt(r, ids...)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+func TestDecryptAge(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyfile := filepath.Join(t.TempDir(), "key.txt")
+	os.WriteFile(keyfile, []byte("# created: now\n"+id.String()+"\n"), 0600)
+	defer func(name string) {
+		*decryptIdentity = name
+		ageOnce, ageIdentities, ageErr = sync.Once{}, nil, nil
+	}(*decryptIdentity)
+	*decryptIdentity = keyfile
+
+	encrypt := func(plain []byte, armored bool) []byte {
+		var buf bytes.Buffer
+		var dst io.WriteCloser = nopWriteCloser{&buf}
+		if armored {
+			dst = armor.NewWriter(&buf)
+		}
+
+		w, err := age.Encrypt(dst, id.Recipient())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(plain)
+		w.Close()
+		dst.Close()
+		return buf.Bytes()
+	}
+
+	recs := targetRecord("http://a/") + targetRecord("http://b/")
+	tb := tarball(t, map[string][]byte{"a.warc": []byte(recs)}, "a.warc")
+	tests := []struct {
+		path string
+		in   []byte
+	}{
+		{"a.warc.gz.age", encrypt(gzipped(t, recs), false)},
+		{"a.warc.age", encrypt([]byte(recs), true)},
+		{"a.tar.gz.age", encrypt(gzipped(t, string(tb)), false)},
+		{"a.warc.gz", gzipped(t, recs)},
+	}
+
+	for _, tt := range tests {
+		got, err := collect(t, tt.path, tt.in)
+		if want := []string{"x", "x"}; err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%v: got %q, %v", tt.path, got, err)
+		}
+	}
+
+	if !hasWARCExt("a.warc.gz.age") || hasWARCExt("a.txt.age") {
+		t.Error("hasWARCExt with .age")
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
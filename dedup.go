@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	dedupBy           = flag.String("dedup-by", "url", "deduplicate the entries by url, by digest, the WARC-Payload-Digest, so that the same content under different URLs is output once, or by canonical, the URL as canonicalized by wayback replay systems")
+	collapseTimestamp = flag.Duration("collapse-timestamp", 0, "deduplicate the entries per WARC-Date in buckets of this duration, e.g. 1h to output one capture of a URL per hour, like wayback collapse")
+	collapseScheme    = flag.Bool("collapse-scheme", false, "deduplicate the http and https URLs of the same host and path as one, and output the https one if both were captured")
+)
+
+// dedupKey is the key entries are deduplicated and counted by: their
+// digest with -dedup-by digest, their canonical URL with -dedup-by
+// canonical, or their URL, also for those without a digest, and with
+// -collapse-timestamp the bucket of their date. With -collapse-scheme,
+// http URLs are keyed as https.
+func dedupKey(e *entry) string {
+	key := outputURL(e.URL)
+	if *collapseScheme && isHTTPURL(key) {
+		key = "https" + key[4:]
+	}
+
+	if *dedupBy == "digest" && len(e.Digest) > 0 {
+		key = e.Digest
+	} else if *dedupBy == "canonical" {
+		key = canonicalURL(e.URL)
+	}
+
+	if *collapseTimestamp > 0 {
+		if date, err := time.Parse(time.RFC3339Nano, e.Date); err == nil {
+			key += "\x00" + strconv.FormatInt(date.Truncate(*collapseTimestamp).Unix(), 10)
+		}
+	}
+
+	return key
+}
+
+func isHTTPURL(s string) bool {
+	return len(s) >= 7 && strings.EqualFold(s[:7], "http://")
+}
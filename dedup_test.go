@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDedupByDigest(t *testing.T) {
+	defer func() { *dedupBy = "url" }()
+	*dedupBy = "digest"
+	entries := []*entry{
+		{URL: "http://a/", Digest: "sha1:AAA"},
+		{URL: "http://a/?utm=x", Digest: "sha1:AAA"},
+		{URL: "http://b/", Digest: "sha1:BBB"},
+		{URL: "http://a/", Digest: "sha1:CCC"},
+		{URL: "http://c/"},
+		{URL: "http://c/"},
+	}
+
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	for i, e := range entries {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{e}})
+	}
+
+	if want := "http://a/\nhttp://b/\nhttp://a/\nhttp://c/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	w = newResultWriter(newDupWriter(newEntryWriter("text", &out)))
+	w.existing = nil
+	for i, e := range entries {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{e}})
+	}
+
+	want := "2\thttp://c/\n2\tsha1:AAA\thttp://a/\n1\tsha1:BBB\thttp://b/\n1\tsha1:CCC\thttp://a/\n"
+	if err := w.out.Close(); err != nil {
+		t.Fatal(err)
+	} else if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestCollapseTimestamp(t *testing.T) {
+	defer func() { *collapseTimestamp = 0 }()
+	*collapseTimestamp = time.Hour
+	entries := []*entry{
+		{URL: "http://a/", Date: "2024-03-01T12:00:00Z"},
+		{URL: "http://a/", Date: "2024-03-01T12:59:59Z"},
+		{URL: "http://b/", Date: "2024-03-01T12:30:00Z"},
+		{URL: "http://a/", Date: "2024-03-01T13:00:00Z"},
+		{URL: "http://a/", Date: "2024-03-01T14:10:00+01:00"},
+		{URL: "http://c/"},
+		{URL: "http://c/"},
+	}
+
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	for i, e := range entries {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{e}})
+	}
+
+	if want := "http://a/\nhttp://b/\nhttp://a/\nhttp://c/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestCollapseScheme(t *testing.T) {
+	defer func() { *collapseScheme = false }()
+	*collapseScheme = true
+	entries := []*entry{
+		{URL: "http://a/"},
+		{URL: "http://b/"},
+		{URL: "https://a/"},
+		{URL: "HTTP://a/"},
+		{URL: "https://c/"},
+		{URL: "http://c/"},
+		{URL: "http://b/"},
+		{URL: "ftp://a/"},
+	}
+
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	w.held = make(map[string]*entry)
+	for i, e := range entries {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{e}})
+	}
+
+	if want := "https://a/\nhttps://c/\nftp://a/\n"; out.String() != want {
+		t.Errorf("before flushing: got %q, want %q", out.String(), want)
+	}
+
+	w.flushHTTP()
+	if want := "https://a/\nhttps://c/\nftp://a/\nhttp://b/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strconv"
+)
+
+var countDups = flag.Bool("count-dups", false, "instead of deduplicating the URLs, count them, and write count<TAB>url lines by descending count, or count<TAB>key<TAB>url lines with -dedup-by digest or canonical")
+
+// dupWriter counts the entries by dedupKey, and writes them with their
+// counts to w on Close
+type dupWriter struct {
+	w      entryWriter
+	counts map[string]int
+	urls   map[string]string // the first URL of keys that aren't one
+}
+
+func newDupWriter(w entryWriter) *dupWriter {
+	return &dupWriter{w: w, counts: make(map[string]int), urls: make(map[string]string)}
+}
+
+func (w *dupWriter) Write(e *entry) error {
+	key := dedupKey(e)
+	if _, ok := w.counts[key]; !ok && key != e.URL {
+		w.urls[key] = e.URL
+	}
+
+	w.counts[key]++
+	return nil
+}
+
+func (w *dupWriter) Close() error {
+	urls := make([]string, 0, len(w.counts))
+	for url := range w.counts {
+		urls = append(urls, url)
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		if ci, cj := w.counts[urls[i]], w.counts[urls[j]]; ci != cj {
+			return ci > cj
+		}
+
+		return urls[i] < urls[j]
+	})
+
+	for _, key := range urls {
+		line := strconv.Itoa(w.counts[key]) + "\t" + key
+		if url, ok := w.urls[key]; ok {
+			line += "\t" + url
+		}
+
+		if err := w.w.Write(&entry{URL: line}); err != nil {
+			return err
+		}
+	}
+
+	return w.w.Close()
+}
+
+func (w *dupWriter) Abort() {
+	if a, ok := w.w.(aborter); ok {
+		a.Abort()
+	}
+}
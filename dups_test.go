@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDupWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := newResultWriter(newDupWriter(newEntryWriter("text", &out)))
+	w.existing = nil
+	for i, url := range []string{"http://b/", "http://a/", "http://c/", "http://a/", "http://c/", "http://a/"} {
+		w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{{URL: url}}})
+	}
+
+	if out.Len() > 0 {
+		t.Errorf("wrote %q before Close", out.String())
+	} else if err := w.out.Close(); err != nil {
+		t.Fatal(err)
+	} else if want := "3\thttp://a/\n2\thttp://c/\n1\thttp://b/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// -out es+http(s)://[user:password@]host[:port]/index indexes the entries
+// with the _bulk API of Elasticsearch or OpenSearch
+func isESURL(s string) bool {
+	return strings.HasPrefix(s, "es+http://") || strings.HasPrefix(s, "es+https://")
+}
+
+type esTarget struct {
+	bulkURL        string
+	user, password string
+	index          string
+	flush          int           // documents per request
+	retries        int           // of requests, or of rejected documents
+	backoff        time.Duration // before the first retry, doubled after
+}
+
+func parseESURL(s string) (*esTarget, error) {
+	u, err := url.Parse(strings.TrimPrefix(s, "es+"))
+	if err != nil {
+		return nil, err
+	} else if len(u.Host) == 0 {
+		return nil, fmt.Errorf("%v: missing host", s)
+	}
+
+	index := strings.Trim(u.Path, "/")
+	if len(index) == 0 || strings.Contains(index, "/") {
+		return nil, fmt.Errorf("%v: expected an index name as the path", s)
+	}
+
+	t := &esTarget{index: index, flush: 1000, retries: 5, backoff: time.Second}
+	if u.User != nil {
+		t.user = u.User.Username()
+		t.password, _ = u.User.Password()
+	}
+
+	q := u.Query()
+	if v := q.Get("flush"); len(v) > 0 {
+		if t.flush, err = strconv.Atoi(v); err != nil || t.flush <= 0 {
+			return nil, fmt.Errorf("%v: invalid flush", s)
+		}
+	}
+
+	if v := q.Get("retries"); len(v) > 0 {
+		if t.retries, err = strconv.Atoi(v); err != nil || t.retries < 0 {
+			return nil, fmt.Errorf("%v: invalid retries", s)
+		}
+	}
+
+	if v := q.Get("backoff"); len(v) > 0 {
+		if t.backoff, err = time.ParseDuration(v); err != nil || t.backoff < 0 {
+			return nil, fmt.Errorf("%v: invalid backoff", s)
+		}
+	}
+
+	u.User, u.Path, u.RawQuery = nil, "/_bulk", ""
+	t.bulkURL = u.String()
+	return t, nil
+}
+
+// esWriter batches the entries into _bulk requests of t.flush documents.
+// Failed requests, and documents rejected by a busy cluster (429), are
+// retried with exponential backoff.
+type esWriter struct {
+	t      *esTarget
+	client *http.Client
+	action []byte
+	docs   [][]byte // action and source lines
+}
+
+func newESWriter(s string) (*esWriter, error) {
+	t, err := parseESURL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := json.Marshal(map[string]interface{}{"index": map[string]string{"_index": t.index}})
+	if err != nil {
+		return nil, err
+	}
+
+	return &esWriter{t: t, client: getHTTPClient(), action: append(action, '\n')}, nil
+}
+
+func (w *esWriter) Write(e *entry) error {
+	source, err := marshalEntry(e)
+	if err != nil {
+		return err
+	}
+
+	doc := make([]byte, 0, len(w.action)+len(source)+1)
+	doc = append(append(append(doc, w.action...), source...), '\n')
+	w.docs = append(w.docs, doc)
+	if len(w.docs) >= w.t.flush {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// esItemError is a document rejected for good, e.g. by the mapping
+type esItemError struct {
+	status       int
+	kind, reason string
+}
+
+func (e *esItemError) Error() string {
+	return fmt.Sprintf("bulk index: %v %v: %v", e.status, e.kind, e.reason)
+}
+
+// Flush sends the batched documents
+func (w *esWriter) Flush() error {
+	docs := w.docs
+	w.docs = nil
+	var err error
+	for attempt := 0; len(docs) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > w.t.retries {
+				return err
+			}
+
+			slog.Warn("esWriter retrying", "documents", len(docs), "err", err)
+			time.Sleep(w.t.backoff << uint(attempt-1))
+		}
+
+		if docs, err = w.bulk(docs); err == nil {
+			return nil
+		} else if serr, ok := err.(*httpStatusError); ok && serr.permanent() {
+			return err
+		} else if _, ok := err.(*esItemError); ok {
+			return err
+		}
+	}
+
+	return err
+}
+
+type esBulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// bulk sends docs, returning those to retry
+func (w *esWriter) bulk(docs [][]byte) ([][]byte, error) {
+	req, err := http.NewRequest("POST", w.t.bulkURL, bytes.NewReader(bytes.Join(docs, nil)))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if len(w.t.user) > 0 {
+		req.SetBasicAuth(w.t.user, w.t.password)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return docs, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return docs, &httpStatusError{status: resp.Status, code: resp.StatusCode}
+	}
+
+	var br esBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&br); err != nil {
+		return docs, err
+	} else if !br.Errors {
+		return nil, nil
+	} else if len(br.Items) != len(docs) {
+		return nil, fmt.Errorf("bulk index: %v items for %v documents", len(br.Items), len(docs))
+	}
+
+	var retry [][]byte
+	for i, item := range br.Items {
+		for _, res := range item {
+			if res.Error == nil {
+				continue
+			} else if res.Status == http.StatusTooManyRequests {
+				retry = append(retry, docs[i])
+			} else {
+				return nil, &esItemError{res.Status, res.Error.Type, res.Error.Reason}
+			}
+		}
+	}
+
+	if len(retry) > 0 {
+		return retry, fmt.Errorf("bulk index: %v documents rejected", len(retry))
+	}
+
+	return nil, nil
+}
+
+func (w *esWriter) Close() error {
+	return w.Flush()
+}
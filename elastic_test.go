@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseESURL(t *testing.T) {
+	got, err := parseESURL("es+https://u:p@search:9200/crawl-urls?flush=500&retries=2&backoff=100ms")
+	want := esTarget{bulkURL: "https://search:9200/_bulk", user: "u", password: "p", index: "crawl-urls",
+		flush: 500, retries: 2, backoff: 100 * time.Millisecond}
+	if err != nil || *got != want {
+		t.Errorf("got %+v %v, want %+v", got, err, want)
+	}
+
+	if got, err := parseESURL("es+http://localhost:9200/urls"); err != nil || got.flush != 1000 || got.bulkURL != "http://localhost:9200/_bulk" {
+		t.Errorf("got %+v %v", got, err)
+	}
+
+	for _, bad := range []string{"es+http://localhost:9200", "es+http:///urls", "es+http://h/a/b", "es+http://h/urls?flush=0"} {
+		if _, err := parseESURL(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+// fakeES answers _bulk requests with the status respond returns for the
+// request (""), or else for the URL of each document, where 0 is success.
+// It records the URLs of the requests.
+type fakeES struct {
+	respond  func(url string) int
+	requests [][]string
+}
+
+func (s *fakeES) status(url string) int {
+	if s.respond == nil {
+		return 0
+	}
+
+	return s.respond(url)
+}
+
+func (s *fakeES) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if code := s.status(""); code != 0 {
+		s.requests = append(s.requests, nil)
+		w.WriteHeader(code)
+		return
+	}
+
+	var urls []string
+	var items []string
+	errors := false
+	sc := bufio.NewScanner(r.Body)
+	for sc.Scan() {
+		if !strings.Contains(sc.Text(), `"_index":"urls"`) {
+			http.Error(w, "expected an action", http.StatusBadRequest)
+			return
+		} else if !sc.Scan() {
+			break
+		}
+
+		var e entry
+		json.Unmarshal(sc.Bytes(), &e)
+		urls = append(urls, e.URL)
+		if status := s.status(e.URL); status == 0 {
+			items = append(items, `{"index":{"status":201}}`)
+		} else {
+			errors = true
+			items = append(items, fmt.Sprintf(`{"index":{"status":%d,"error":{"type":"t","reason":"r"}}}`, status))
+		}
+	}
+
+	s.requests = append(s.requests, urls)
+	fmt.Fprintf(w, `{"errors":%v,"items":[%v]}`, errors, strings.Join(items, ","))
+}
+
+func TestESWriter(t *testing.T) {
+	fake := &fakeES{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	target := "es+" + srv.URL + "/urls?flush=2&retries=2&backoff=0s"
+
+	w, err := newESWriter(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{"http://a/", "http://b/", "http://c/"} {
+		if err := w.Write(&entry{URL: url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	} else if got := fmt.Sprint(fake.requests); got != "[[http://a/ http://b/] [http://c/]]" {
+		t.Errorf("got requests %v", got)
+	}
+
+	// rejected documents are retried on their own
+	var rejections int
+	fake.requests = nil
+	fake.respond = func(url string) int {
+		if url == "http://b/" && rejections < 2 {
+			rejections++
+			return 429
+		}
+
+		return 0
+	}
+
+	w, _ = newESWriter(target)
+	w.Write(&entry{URL: "http://a/"})
+	if err := w.Write(&entry{URL: "http://b/"}); err != nil {
+		t.Fatal(err)
+	} else if got := fmt.Sprint(fake.requests); got != "[[http://a/ http://b/] [http://b/] [http://b/]]" {
+		t.Errorf("got requests %v", got)
+	}
+
+	// and until the retries are used up
+	rejections, fake.requests = -10, nil
+	w.Write(&entry{URL: "http://a/"})
+	if err := w.Write(&entry{URL: "http://b/"}); err == nil || len(fake.requests) != 3 {
+		t.Errorf("got %v after %v requests", err, len(fake.requests))
+	}
+
+	// others aren't retried
+	fake.requests = nil
+	fake.respond = func(url string) int {
+		if url == "http://b/" {
+			return 400
+		}
+
+		return 0
+	}
+
+	w.Write(&entry{URL: "http://a/"})
+	if err := w.Write(&entry{URL: "http://b/"}); err == nil || len(fake.requests) != 1 {
+		t.Errorf("got %v after %v requests", err, len(fake.requests))
+	}
+
+	// failed requests are
+	fake.requests = nil
+	fake.respond = func(url string) int {
+		if url == "" && len(fake.requests) < 2 {
+			return 503
+		}
+
+		return 0
+	}
+
+	w.Write(&entry{URL: "http://a/"})
+	if err := w.Close(); err != nil || len(fake.requests) != 3 {
+		t.Errorf("got %v after %v requests", err, len(fake.requests))
+	}
+}
@@ -0,0 +1,845 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	recordTypes = flag.String("type", "", "only output the URLs of records of these WARC-Types, comma separated, e.g. response,resource")
+	mimeTypes   = flag.String("mime", "", "only output the URLs of responses with these HTTP Content-Types, comma separated, or glob patterns like image/*")
+	statusCodes = flag.String("status", "", "only output the URLs of responses with these HTTP status codes, or ranges of them, comma separated, e.g. 200,301-302")
+	matchURL    = flag.String("match", "", "only output the URLs matching this regular expression")
+	excludeFile = flag.String("exclude-file", "", "don't output the URLs matching the regular expressions of this file, one per line, with # comments")
+	hostsAllow  = flag.String("hosts-allow", "", "only output the URLs of the hosts of this file, one per line, with .example.com or *.example.com also matching subdomains")
+	hostsDeny   = flag.String("hosts-deny", "", "don't output the URLs of the hosts of this file, in the format of -hosts-allow")
+	sinceDate   = flag.String("since", "", "only output the URLs of records with a WARC-Date at or after this time, e.g. 2024-03-01, 2024-03-01T12:00:00Z or 202403")
+	untilDate   = flag.String("until", "", "only output the URLs of records with a WARC-Date up to and including this time, e.g. 2024-03 for all of March 2024, in the formats of -since")
+	schemes     = flag.String("schemes", "", "only output the URLs of these schemes, comma separated, e.g. http,https, or not of those with a leading -, e.g. -dns,-whois")
+	minSize     = flag.String("min-size", "", "only output the URLs of records with a Content-Length, i.e. block length, of at least this many bytes, with an optional k, M or G suffix")
+	maxSize     = flag.String("max-size", "", "only output the URLs of records with a Content-Length of at most this many bytes, in the format of -min-size")
+	recordIDs   = flag.String("record-ids", "", "only output the URLs of the records with the WARC-Record-IDs of this file, one per line, with or without <>")
+	ipCIDRs     = flag.String("ip-cidr", "", "only output the URLs of records with a WARC-IP-Address in these CIDR ranges or addresses, comma separated, or not in those with a leading -, e.g. 192.0.2.0/24,-192.0.2.128/25")
+	domains     = flag.String("domains", "", "only output the URLs of these registered domains, by the public suffix list, and their subdomains, comma separated, e.g. example.com,example.co.uk")
+	surtFile    = flag.String("surt-prefix-file", "", "a file of -surt-prefix prefixes, one per line")
+	filterLists = flag.String("filter-lists", "", "don't output the URLs blocked by these AdBlock-style filter lists, comma separated files, e.g. easylist.txt,easyprivacy.txt")
+	ports       = flag.String("ports", "", "only output the URLs of these ports, comma separated, e.g. 80,443,8080, with those of http and https URLs without one 80 and 443")
+	tlds        = flag.String("tlds", "", "only output the URLs of hosts in these top-level domains, or other suffixes, comma separated, e.g. gov,edu,gov.uk")
+	maxDepth    = flag.Int("max-depth", -1, "don't output the URLs whose path has more than this many segments, e.g. 3 for http://a/1/2/3, to leave out crawl traps")
+	extensions  = flag.String("ext", "", "only output the URLs whose path ends in these extensions, comma separated, e.g. pdf,docx,tar.gz")
+)
+
+var truncated = flag.String("truncated", "include", "include, skip or only output the URLs of records with a WARC-Truncated header, e.g. to list them for a re-crawl")
+
+var includeRevisits = flag.Bool("include-revisits", false, "also output the URLs of revisit records, with \"revisit\": true in jsonl, which are always indexed with -format cdx and cdxj")
+
+var (
+	hasQuery = flag.Bool("has-query", false, "only output the URLs with a query string")
+	noQuery  = flag.Bool("no-query", false, "only output the URLs without a query string")
+)
+
+var excludeURLs, surtPrefixes, queryParams, respHeaders listFlag
+
+func init() {
+	flag.Var(&excludeURLs, "exclude", "don't output the URLs matching this regular expression, can be repeated")
+	flag.Var(&surtPrefixes, "surt-prefix", "only output the URLs with this SURT prefix, e.g. 'com,example)/blog', or not those with it after a -, can be repeated; the longest matching prefix decides")
+	flag.Var(&queryParams, "query-param", "only output the URLs with this query parameter, as key or key=value, can be repeated to output those with any of them")
+	flag.Var(&respHeaders, "resp-header", "only output the URLs of HTTP responses with a header matching this 'Name: regexp', e.g. 'Server: nginx.*', which must match all of its value; can be repeated to output those matching all of them")
+}
+
+// listFlag is a flag that can be repeated
+type listFlag []string
+
+func (f *listFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *listFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+var warcTypes = []string{"warcinfo", "response", "resource", "request", "metadata", "revisit", "conversion", "continuation"}
+
+// entryFilter tells whether to output an entry
+type entryFilter func(e *entry) bool
+
+// the filters of the entries, from their flags
+var entryFilters []entryFilter
+
+func setupFilters() error {
+	entryFilters = nil
+	types := make(map[string]bool)
+	if len(*recordTypes) > 0 {
+		for _, t := range splitList(*recordTypes) {
+			t = strings.ToLower(t)
+			if !hasString(warcTypes, t) {
+				return fmt.Errorf("-type: unknown WARC-Type %q", t)
+			}
+
+			types[t] = true
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return types[strings.ToLower(e.Type)]
+		})
+	}
+
+	// revisits are only of URLs captured before, so they're left out
+	// unless asked for
+	if !*includeRevisits && !types["revisit"] && !isCDXFormat(outputFormat) {
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return !strings.EqualFold(e.Type, "revisit")
+		})
+	}
+
+	if len(*filterFile) > 0 {
+		f, err := loadFilterFile(*filterFile)
+		if err != nil {
+			return fmt.Errorf("-filter-file: %v", err)
+		}
+
+		entryFilters = append(entryFilters, f)
+	}
+
+	if len(*mimeTypes) > 0 {
+		patterns := splitList(strings.ToLower(*mimeTypes))
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("-mime: %q: %v", p, err)
+			}
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return matchAny(patterns, e.MIME)
+		})
+	}
+
+	if len(*statusCodes) > 0 {
+		ranges, err := parseStatusRanges(*statusCodes)
+		if err != nil {
+			return fmt.Errorf("-status: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return inStatusRanges(ranges, e.Status)
+		})
+	}
+
+	if len(*matchURL) > 0 {
+		re, err := regexp.Compile(*matchURL)
+		if err != nil {
+			return fmt.Errorf("-match: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return re.MatchString(e.URL)
+		})
+	}
+
+	if len(excludeURLs) > 0 || len(*excludeFile) > 0 {
+		re, err := excludeRegexp(excludeURLs, *excludeFile)
+		if err != nil {
+			return err
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return !re.MatchString(e.URL)
+		})
+	}
+
+	if len(*filterLists) > 0 {
+		list, err := loadBlockList(splitList(*filterLists))
+		if err != nil {
+			return fmt.Errorf("-filter-lists: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return !list.blocked(e.URL, e.host())
+		})
+	}
+
+	if len(*charsets) > 0 {
+		keys := make(map[string]bool)
+		for _, c := range splitList(*charsets) {
+			keys[charsetKey(c)] = true
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return len(e.Charset) > 0 && keys[charsetKey(e.Charset)]
+		})
+	}
+
+	if len(*langs) > 0 {
+		keep := make(map[string]bool)
+		for _, lang := range splitList(strings.ToLower(*langs)) {
+			if _, ok := langStopwords[lang]; !ok {
+				return fmt.Errorf("-lang: %q not one of the languages detected, %v", lang, strings.Join(detectedLangs(), ", "))
+			}
+
+			keep[lang] = true
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return keep[e.Lang]
+		})
+	}
+
+	if len(respHeaders) > 0 {
+		type headerMatch struct {
+			name string
+			re   *regexp.Regexp
+		}
+
+		var matches []headerMatch
+		for _, h := range respHeaders {
+			name, value, ok := strings.Cut(h, ":")
+			if name = strings.TrimSpace(name); !ok || len(name) == 0 {
+				return fmt.Errorf("-resp-header: %q not Name: regexp", h)
+			}
+
+			re, err := regexp.Compile("^(?:" + strings.TrimSpace(value) + ")$")
+			if err != nil {
+				return fmt.Errorf("-resp-header: %v", err)
+			}
+
+			matches = append(matches, headerMatch{name, re})
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			for _, m := range matches {
+				if !matchesValue(m.re, headerValues(e.Header, m.name)) {
+					return false
+				}
+			}
+
+			return true
+		})
+	}
+
+	if len(*hostsAllow) > 0 {
+		hosts, err := loadHostSet(*hostsAllow)
+		if err != nil {
+			return fmt.Errorf("-hosts-allow: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return hosts.has(e.host())
+		})
+	}
+
+	if len(*hostsDeny) > 0 {
+		hosts, err := loadHostSet(*hostsDeny)
+		if err != nil {
+			return fmt.Errorf("-hosts-deny: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return !hosts.has(e.host())
+		})
+	}
+
+	if len(*sinceDate) > 0 || len(*untilDate) > 0 {
+		var since, until time.Time
+		var err error
+		if len(*sinceDate) > 0 {
+			if since, _, err = parsePeriod(*sinceDate); err != nil {
+				return fmt.Errorf("-since: %v", err)
+			}
+		}
+
+		if len(*untilDate) > 0 {
+			if _, until, err = parsePeriod(*untilDate); err != nil {
+				return fmt.Errorf("-until: %v", err)
+			}
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			date, err := time.Parse(time.RFC3339Nano, e.Date)
+			return err == nil && !date.Before(since) && (until.IsZero() || date.Before(until))
+		})
+	}
+
+	if len(*schemes) > 0 {
+		allow, deny := make(map[string]bool), make(map[string]bool)
+		for _, v := range splitList(strings.ToLower(*schemes)) {
+			if strings.HasPrefix(v, "-") {
+				deny[v[1:]] = true
+			} else {
+				allow[v] = true
+			}
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			scheme := urlScheme(e.URL)
+			return !deny[scheme] && (len(allow) == 0 || allow[scheme])
+		})
+	}
+
+	if len(*minSize) > 0 || len(*maxSize) > 0 {
+		min, err := parseQuantity(*minSize, 1024)
+		if err != nil {
+			return fmt.Errorf("-min-size: %v", err)
+		}
+
+		max, err := parseQuantity(*maxSize, 1024)
+		if err != nil {
+			return fmt.Errorf("-max-size: %v", err)
+		} else if len(*maxSize) == 0 {
+			max = math.MaxInt64
+		} else if max < min {
+			return errors.New("-max-size is less than -min-size")
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return e.Length >= min && e.Length <= max
+		})
+	}
+
+	if len(*recordIDs) > 0 {
+		lines, err := readListFile(*recordIDs)
+		if err != nil {
+			return fmt.Errorf("-record-ids: %v", err)
+		}
+
+		ids := make(map[string]bool, len(lines))
+		for _, id := range lines {
+			ids[trimRecordID(id)] = true
+		}
+
+		delete(ids, "")
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return ids[trimRecordID(e.RecordID)]
+		})
+	}
+
+	if len(*ipCIDRs) > 0 {
+		allow, deny, err := parseCIDRs(*ipCIDRs)
+		if err != nil {
+			return fmt.Errorf("-ip-cidr: %v", err)
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			ip, err := netip.ParseAddr(e.IP)
+			if err != nil {
+				return len(allow) == 0
+			}
+
+			ip = ip.Unmap()
+			return !containsAddr(deny, ip) && (len(allow) == 0 || containsAddr(allow, ip))
+		})
+	}
+
+	if len(*domains) > 0 {
+		set := make(map[string]bool)
+		for _, d := range splitList(strings.ToLower(*domains)) {
+			d = strings.TrimSuffix(d, ".")
+			if len(registeredDomain(d)) == 0 {
+				return fmt.Errorf("-domains: %q isn't a registered domain or below one", d)
+			}
+
+			set[d] = true
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return inDomains(set, strings.TrimSuffix(e.host(), "."))
+		})
+	}
+
+	if len(surtPrefixes) > 0 || len(*surtFile) > 0 {
+		prefixes := append([]string(nil), surtPrefixes...)
+		if len(*surtFile) > 0 {
+			lines, err := readListFile(*surtFile)
+			if err != nil {
+				return fmt.Errorf("-surt-prefix-file: %v", err)
+			}
+
+			prefixes = append(prefixes, lines...)
+		}
+
+		scope := newSURTScope(prefixes)
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return scope.has(e.URL)
+		})
+	}
+
+	if len(*ports) > 0 {
+		allowed := make(map[int]bool)
+		for _, p := range splitList(*ports) {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 || n > 65535 {
+				return fmt.Errorf("-ports: invalid port %q", p)
+			}
+
+			allowed[n] = true
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			n, err := strconv.Atoi(urlPort(e.URL))
+			return err == nil && allowed[n]
+		})
+	}
+
+	if len(*tlds) > 0 {
+		var suffixes []string
+		for _, tld := range splitList(strings.ToLower(*tlds)) {
+			suffixes = append(suffixes, "."+strings.Trim(tld, "."))
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			host := "." + strings.TrimSuffix(e.host(), ".")
+			for _, s := range suffixes {
+				if strings.HasSuffix(host, s) && len(host) > len(s) {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	if *maxDepth >= 0 {
+		depth := *maxDepth
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return urlDepth(e.URL) <= depth
+		})
+	}
+
+	if len(*extensions) > 0 {
+		var exts []string
+		for _, ext := range splitList(strings.ToLower(*extensions)) {
+			exts = append(exts, "."+strings.TrimPrefix(ext, "."))
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			name := strings.ToLower(urlFileName(e.URL))
+			for _, ext := range exts {
+				if strings.HasSuffix(name, ext) && len(name) > len(ext) {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	switch *truncated {
+	case "include":
+	case "skip", "only":
+		only := *truncated == "only"
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return (len(e.Truncated) > 0) == only
+		})
+	default:
+		return fmt.Errorf("-truncated: %q not include, skip or only", *truncated)
+	}
+
+	if *hasQuery && *noQuery {
+		return errors.New("-has-query and -no-query are exclusive")
+	} else if *hasQuery || *noQuery {
+		want := *hasQuery
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return (len(urlQuery(e.URL)) > 0) == want
+		})
+	}
+
+	if len(queryParams) > 0 {
+		params := make([][2]string, 0, len(queryParams)) // key and value, or "=" for any
+		for _, p := range queryParams {
+			key, value, ok := strings.Cut(p, "=")
+			if len(key) == 0 {
+				return fmt.Errorf("-query-param: %q: no key", p)
+			} else if !ok {
+				value = "="
+			}
+
+			params = append(params, [2]string{key, value})
+		}
+
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			query, _ := url.ParseQuery(urlQuery(e.URL))
+			for _, p := range params {
+				if values, ok := query[p[0]]; ok && (p[1] == "=" || hasString(values, p[1])) {
+					return true
+				}
+			}
+
+			return false
+		})
+	}
+
+	if len(*whereExpr) > 0 {
+		f, err := parseWhere(*whereExpr)
+		if err != nil {
+			return fmt.Errorf("-where: %v", err)
+		}
+
+		entryFilters = append(entryFilters, f)
+	}
+
+	switch *robotsMode {
+	case "":
+	case "exclude":
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return robots == nil || robots.allowed(e.URL)
+		})
+	case "flag":
+		// which keeps them all
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			e.RobotsDisallowed = robots != nil && !robots.allowed(e.URL)
+			return true
+		})
+	default:
+		return fmt.Errorf("-robots: %q not exclude or flag", *robotsMode)
+	}
+
+	switch *soft404 {
+	case "", "flag":
+	case "skip":
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return !e.Soft404
+		})
+	default:
+		return fmt.Errorf("-soft-404: %q not flag or skip", *soft404)
+	}
+
+	if *sampleRate < 0 || *sampleRate > 1 {
+		return fmt.Errorf("-sample: %v not between 0 and 1", *sampleRate)
+	} else if rate, seed := *sampleRate, *sampleSeed; rate > 0 && rate < 1 {
+		entryFilters = append(entryFilters, func(e *entry) bool {
+			return sampled(e, rate, seed)
+		})
+	}
+
+	return nil
+}
+
+// matchesValue tells whether re matches any of values
+func matchesValue(re *regexp.Regexp, values []string) bool {
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlPort returns the port of a URL, that of its scheme if it has none,
+// or an empty string if unknown
+func urlPort(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	} else if port := u.Port(); len(port) > 0 {
+		return port
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "ws":
+		return "80"
+	case "https", "wss":
+		return "443"
+	case "ftp":
+		return "21"
+	}
+
+	return ""
+}
+
+// urlQuery returns the query string of a URL, without the ?
+func urlQuery(s string) string {
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		return s[i+1:]
+	}
+
+	return ""
+}
+
+// urlDepth returns the number of non-empty segments of the path of a URL
+func urlDepth(s string) int {
+	u, err := url.Parse(s)
+	if err != nil {
+		return 0
+	}
+
+	var n int
+	for _, seg := range strings.Split(u.EscapedPath(), "/") {
+		if len(seg) > 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// urlFileName returns the last segment of the path of a URL, unescaped
+func urlFileName(s string) string {
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+		if j := strings.IndexByte(s, '/'); j >= 0 {
+			s = s[j:]
+		} else {
+			return ""
+		}
+	}
+
+	name := s[strings.LastIndexByte(s, '/')+1:]
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+
+	return name
+}
+
+// inDomains tells whether host is, or is below, one of the domains,
+// which are all registered domains or below one
+func inDomains(domains map[string]bool, host string) bool {
+	reg := registeredDomain(host)
+	if len(reg) == 0 {
+		return false
+	}
+
+	for d := host; len(d) >= len(reg); {
+		if domains[d] {
+			return true
+		}
+
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			break
+		}
+
+		d = d[i+1:]
+	}
+
+	return false
+}
+
+// parseCIDRs parses a list of CIDR ranges and addresses, of which those
+// with a leading - are in deny
+func parseCIDRs(s string) (allow, deny []netip.Prefix, err error) {
+	for _, v := range splitList(s) {
+		list := &allow
+		if strings.HasPrefix(v, "-") {
+			v, list = v[1:], &deny
+		}
+
+		var p netip.Prefix
+		if strings.Contains(v, "/") {
+			p, err = netip.ParsePrefix(v)
+		} else {
+			var ip netip.Addr
+			ip, err = netip.ParseAddr(v)
+			p = netip.PrefixFrom(ip, ip.BitLen())
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		*list = append(*list, p.Masked())
+	}
+
+	return allow, deny, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func trimRecordID(id string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+}
+
+// urlScheme returns the lowercased scheme of url, or an empty string if
+// it has none
+func urlScheme(url string) string {
+	for i := 0; i < len(url); i++ {
+		c := url[i]
+		switch {
+		case c == ':' && i > 0:
+			return strings.ToLower(url[:i])
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// the layouts of -since and -until, with the length of the period of each
+var periodLayouts = []struct {
+	layout              string
+	years, months, days int
+	unit                time.Duration
+}{
+	{"2006", 1, 0, 0, 0},
+	{"2006-01", 0, 1, 0, 0},
+	{"200601", 0, 1, 0, 0},
+	{"2006-01-02", 0, 0, 1, 0},
+	{"20060102", 0, 0, 1, 0},
+	{"2006010215", 0, 0, 0, time.Hour},
+	{"200601021504", 0, 0, 0, time.Minute},
+	{"20060102150405", 0, 0, 0, time.Second},
+}
+
+// parsePeriod parses a time as the start and end of the period it covers:
+// for 2024-03, from March 1 2024 until April 1. Times are in UTC, like
+// WARC-Dates, unless an RFC 3339 time says otherwise.
+func parsePeriod(s string) (time.Time, time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, t.Add(time.Nanosecond), nil
+	}
+
+	for _, p := range periodLayouts {
+		if t, err := time.Parse(p.layout, s); err == nil {
+			end := t.AddDate(p.years, p.months, p.days).Add(p.unit)
+			return t, end, nil
+		}
+	}
+
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// excludeRegexp returns the alternation of the -exclude patterns and
+// those of the -exclude-file path
+func excludeRegexp(patterns []string, path string) (*regexp.Regexp, error) {
+	patterns = append([]string(nil), patterns...)
+	if len(path) > 0 {
+		lines, err := readListFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, lines...)
+	}
+
+	var alts []string
+	for _, p := range patterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("-exclude: %v", err)
+		}
+
+		alts = append(alts, "(?:"+p+")")
+	}
+
+	return regexp.Compile(strings.Join(alts, "|"))
+}
+
+// readListFile returns the lines of path, except empty ones and # comments
+func readListFile(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, nil
+}
+
+// parseStatusRanges parses a list of status codes and ranges of them
+func parseStatusRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, v := range splitList(s) {
+		from, to, isRange := strings.Cut(v, "-")
+		if !isRange {
+			to = from
+		}
+
+		lo, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil || lo < 100 || lo > 999 {
+			return nil, fmt.Errorf("invalid status %q", v)
+		}
+
+		hi, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || hi < lo || hi > 999 {
+			return nil, fmt.Errorf("invalid status %q", v)
+		}
+
+		ranges = append(ranges, [2]int{lo, hi})
+	}
+
+	return ranges, nil
+}
+
+func inStatusRanges(ranges [][2]int, status int) bool {
+	for _, r := range ranges {
+		if status >= r[0] && status <= r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchAny tells whether s matches any of the glob patterns
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func keepEntry(e *entry) bool {
+	return matchesAll(entryFilters, e)
+}
+
+func matchesAll(filters []entryFilter, e *entry) bool {
+	for _, f := range filters {
+		if !f(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterEntries returns the entries that pass all the filters
+func filterEntries(entries []*entry) []*entry {
+	if len(entryFilters) == 0 {
+		return entries
+	}
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if keepEntry(e) {
+			kept = append(kept, e)
+		}
+	}
+
+	return kept
+}
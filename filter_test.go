@@ -0,0 +1,570 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withFilters sets up the filters of the flags set by set, and resets
+// them and the flags when the test is done
+func withFilters(t *testing.T, set func()) {
+	t.Helper()
+	saved := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) { saved[f.Name] = f.Value.String() })
+	t.Cleanup(func() {
+		flag.VisitAll(func(f *flag.Flag) {
+			if _, ok := f.Value.(*listFlag); ok {
+				return
+			} else if v := saved[f.Name]; f.Value.String() != v {
+				f.Value.Set(v)
+			}
+		})
+
+		excludeURLs, surtPrefixes, queryParams, respHeaders, entryFilters = nil, nil, nil, nil, nil
+	})
+
+	set()
+	if err := setupFilters(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+	withFilters(t, func() { *recordTypes = "response, Resource" })
+	entries := []*entry{
+		{URL: "http://a/", Type: "response"},
+		{URL: "http://b/", Type: "request"},
+		{URL: "http://c/", Type: "resource"},
+		{URL: "http://d/", Type: "metadata"},
+		{URL: "http://e/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a/", "http://c/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*recordTypes = "response,responses"
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+}
+
+func TestMIMEFilter(t *testing.T) {
+	withFilters(t, func() { *mimeTypes = "text/html,application/xhtml+xml, IMAGE/*" })
+	entries := []*entry{
+		{URL: "http://a/", MIME: "text/html"},
+		{URL: "http://b/", MIME: "text/plain"},
+		{URL: "http://c/", MIME: "image/png"},
+		{URL: "http://d/", MIME: "application/xhtml+xml"},
+		{URL: "http://e/"},
+		{URL: "http://f/", MIME: "image"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a/", "http://c/", "http://d/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*mimeTypes = "text/[html"
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestParseStatusRanges(t *testing.T) {
+	tests := []struct {
+		s    string
+		want [][2]int
+		ok   bool
+	}{
+		{"200", [][2]int{{200, 200}}, true},
+		{"200,301-302, 404", [][2]int{{200, 200}, {301, 302}, {404, 404}}, true},
+		{"500 - 599", [][2]int{{500, 599}}, true},
+		{"302-301", nil, false},
+		{"2xx", nil, false},
+		{"99", nil, false},
+		{"200-", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatusRanges(tt.s)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: got %v", tt.s, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestStatusFilter(t *testing.T) {
+	withFilters(t, func() { *statusCodes = "200,301-302" })
+	entries := []*entry{
+		{URL: "http://a/", Status: 200},
+		{URL: "http://b/", Status: 404},
+		{URL: "http://c/", Status: 302},
+		{URL: "http://d/"},
+		{URL: "http://e/", Status: 303},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a/", "http://c/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMatchFilter(t *testing.T) {
+	withFilters(t, func() { *matchURL = `^https?://[^/]+/product/` })
+	entries := []*entry{
+		{URL: "http://shop.example/product/1"},
+		{URL: "http://shop.example/cart"},
+		{URL: "https://shop.example/product/"},
+		{URL: "http://shop.example/a/product/2"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://shop.example/product/1", "https://shop.example/product/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*matchURL = "("
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for an invalid expression")
+	}
+}
+
+func TestExcludeFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "noise.txt")
+	noise := "# faceted navigation\n[?&]facet=\n\n  /calendar/  \n"
+	if err := os.WriteFile(path, []byte(noise), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	withFilters(t, func() {
+		flag.Set("exclude", `(?i)[?&]sessionid=`)
+		flag.Set("exclude", `\.ics$`)
+		*excludeFile = path
+	})
+
+	entries := []*entry{
+		{URL: "http://a.example/"},
+		{URL: "http://a.example/?SessionID=1"},
+		{URL: "http://a.example/events/calendar/2024"},
+		{URL: "http://a.example/shoes?size=9&facet=red"},
+		{URL: "http://a.example/feed.ics"},
+		{URL: "http://a.example/shoes?size=9"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a.example/", "http://a.example/shoes?size=9"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// a pattern of its own, e.g. not one closing the group of another
+	if _, err := excludeRegexp([]string{"a)|(b"}, ""); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		s          string
+		start, end string
+	}{
+		{"2024", "2024-01-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"2024-02", "2024-02-01T00:00:00Z", "2024-03-01T00:00:00Z"},
+		{"202412", "2024-12-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"2024-02-28", "2024-02-28T00:00:00Z", "2024-02-29T00:00:00Z"},
+		{"2024022912", "2024-02-29T12:00:00Z", "2024-02-29T13:00:00Z"},
+		{"20240229123005", "2024-02-29T12:30:05Z", "2024-02-29T12:30:06Z"},
+		{"2024-02-29T12:30:05+01:00", "2024-02-29T11:30:05Z", "2024-02-29T11:30:05.000000001Z"},
+	}
+
+	for _, tt := range tests {
+		start, end, err := parsePeriod(tt.s)
+		if err != nil {
+			t.Errorf("%q: %v", tt.s, err)
+		} else if got := start.UTC().Format(time.RFC3339Nano); got != tt.start {
+			t.Errorf("%q: got start %v, want %v", tt.s, got, tt.start)
+		} else if got := end.UTC().Format(time.RFC3339Nano); got != tt.end {
+			t.Errorf("%q: got end %v, want %v", tt.s, got, tt.end)
+		}
+	}
+
+	if _, _, err := parsePeriod("last week"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestDateFilter(t *testing.T) {
+	withFilters(t, func() { *sinceDate, *untilDate = "2024-03-01", "2024-03" })
+	entries := []*entry{
+		{URL: "http://a/", Date: "2024-02-29T23:59:59Z"},
+		{URL: "http://b/", Date: "2024-03-01T00:00:00Z"},
+		{URL: "http://c/", Date: "2024-03-31T23:59:59.5Z"},
+		{URL: "http://d/", Date: "2024-04-01T00:00:00Z"},
+		{URL: "http://e/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://b/", "http://c/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestURLScheme(t *testing.T) {
+	for url, want := range map[string]string{
+		"HTTP://example.com/": "http",
+		"dns:example.com":     "dns",
+		"svn+ssh://host/repo": "svn+ssh",
+		"/relative/path":      "",
+		"example.com:8080":    "example.com",
+		"1http://x":           "",
+		":foo":                "",
+		"no-scheme":           "",
+	} {
+		if got := urlScheme(url); got != want {
+			t.Errorf("%q: got %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestSchemeFilter(t *testing.T) {
+	entries := func() []*entry {
+		return []*entry{
+			{URL: "http://a/"},
+			{URL: "HTTPS://b/"},
+			{URL: "dns:c"},
+			{URL: "whois://d/"},
+			{URL: "mailto:e@example.com"},
+		}
+	}
+
+	withFilters(t, func() { *schemes = "http,https" })
+	got := entryURLs(filterEntries(entries()))
+	if want := []string{"http://a/", "HTTPS://b/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*schemes = "-dns, -whois"
+	setupFilters()
+	got = entryURLs(filterEntries(entries()))
+	if want := []string{"http://a/", "HTTPS://b/", "mailto:e@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSizeFilter(t *testing.T) {
+	entries := func() []*entry {
+		return []*entry{
+			{URL: "http://a/", Length: 512},
+			{URL: "http://b/", Length: 2048},
+			{URL: "http://c/", Length: 3 << 20},
+			{URL: "http://d/"},
+		}
+	}
+
+	withFilters(t, func() { *minSize = "1k" })
+	got := entryURLs(filterEntries(entries()))
+	if want := []string{"http://b/", "http://c/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*minSize, *maxSize = "", "2048"
+	setupFilters()
+	got = entryURLs(filterEntries(entries()))
+	if want := []string{"http://a/", "http://b/", "http://d/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*minSize, *maxSize = "1M", "1k"
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for -max-size < -min-size")
+	}
+}
+
+func TestRevisitFilter(t *testing.T) {
+	entries := func() []*entry {
+		return []*entry{
+			{URL: "http://a/", Type: "response"},
+			{URL: "http://b/", Type: "revisit", Revisit: true},
+		}
+	}
+
+	defer func(f string) { outputFormat = f }(outputFormat)
+	for _, tt := range []struct {
+		set  func()
+		want []string
+	}{
+		{func() {}, []string{"http://a/"}},
+		{func() { *includeRevisits = true }, []string{"http://a/", "http://b/"}},
+		{func() { *recordTypes = "revisit" }, []string{"http://b/"}},
+		{func() { outputFormat = "cdxj" }, []string{"http://a/", "http://b/"}},
+	} {
+		outputFormat = "text"
+		withFilters(t, tt.set)
+		if got := entryURLs(filterEntries(entries())); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %v, want %v", got, tt.want)
+		}
+
+		*includeRevisits, *recordTypes = false, ""
+	}
+}
+
+func TestRevisitEntry(t *testing.T) {
+	rec := warcRecord("WARC-Type: revisit\r\nWARC-Target-URI: http://example.com/\r\n"+
+		"WARC-Date: 2024-01-02T03:04:05Z\r\n", "")
+	res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+	if len(res.entries) != 1 || !res.entries[0].Revisit {
+		t.Fatalf("got %+v", res.entries)
+	}
+
+	data, err := marshalEntry(res.entries[0])
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), `"warc_type":"revisit","warc_date":"2024-01-02T03:04:05Z","length":0,"revisit":true`) {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestRecordIDFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.txt")
+	os.WriteFile(path, []byte("<urn:uuid:1>\nurn:uuid:3\n"), 0644)
+	withFilters(t, func() { *recordIDs = path })
+	entries := []*entry{
+		{URL: "http://a/", RecordID: "<urn:uuid:1>"},
+		{URL: "http://b/", RecordID: "<urn:uuid:2>"},
+		{URL: "http://c/", RecordID: "<urn:uuid:3>"},
+		{URL: "http://d/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a/", "http://c/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	entries := func() []*entry {
+		return []*entry{
+			{URL: "http://a/", IP: "192.0.2.10"},
+			{URL: "http://b/", IP: "192.0.2.200"},
+			{URL: "http://c/", IP: "198.51.100.1"},
+			{URL: "http://d/", IP: "2001:db8::1"},
+			{URL: "http://e/"},
+			{URL: "http://f/", IP: "::ffff:192.0.2.11"},
+		}
+	}
+
+	withFilters(t, func() { *ipCIDRs = "192.0.2.0/24, -192.0.2.128/25, 2001:db8::1" })
+	got := entryURLs(filterEntries(entries()))
+	if want := []string{"http://a/", "http://d/", "http://f/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*ipCIDRs = "-198.51.100.0/24"
+	setupFilters()
+	got = entryURLs(filterEntries(entries()))
+	if want := []string{"http://a/", "http://b/", "http://d/", "http://e/", "http://f/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*ipCIDRs = "192.0.2.0/33"
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for an invalid range")
+	}
+}
+
+func TestDomainFilter(t *testing.T) {
+	withFilters(t, func() { *domains = "example.com, example.co.uk,blog.example.org" })
+	entries := []*entry{
+		{URL: "http://blog.example.com/"},
+		{URL: "http://example.com/"},
+		{URL: "http://examplefoo.com/"},
+		{URL: "http://www.example.co.uk/"},
+		{URL: "http://other.co.uk/"},
+		{URL: "http://a.blog.example.org/"},
+		{URL: "http://www.example.org/"},
+		{URL: "http://192.0.2.1/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	want := []string{"http://blog.example.com/", "http://example.com/", "http://www.example.co.uk/", "http://a.blog.example.org/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	*domains = "co.uk"
+	if err := setupFilters(); err == nil {
+		t.Error("expected an error for a public suffix")
+	}
+}
+
+func TestURLFileName(t *testing.T) {
+	for u, want := range map[string]string{
+		"http://example.com/docs/report.pdf":         "report.pdf",
+		"http://example.com/docs/report.pdf?dl=1#p2": "report.pdf",
+		"http://example.com/get?file=report.pdf":     "get",
+		"http://example.com":                         "",
+		"http://example.com/dir/":                    "",
+		"http://example.com/My%20Report.PDF":         "My Report.PDF",
+		"/relative/archive.tar.gz":                   "archive.tar.gz",
+	} {
+		if got := urlFileName(u); got != want {
+			t.Errorf("%q: got %q, want %q", u, got, want)
+		}
+	}
+}
+
+func TestExtFilter(t *testing.T) {
+	withFilters(t, func() { *extensions = "pdf, .DOCX,tar.gz" })
+	entries := []*entry{
+		{URL: "http://a/report.PDF?download=1"},
+		{URL: "http://a/view?file=report.pdf"},
+		{URL: "http://a/letter.docx"},
+		{URL: "http://a/src.tar.gz"},
+		{URL: "http://a/src.gz"},
+		{URL: "http://a/.pdf"},
+		{URL: "http://a.pdf/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://a/report.PDF?download=1", "http://a/letter.docx", "http://a/src.tar.gz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQueryFilters(t *testing.T) {
+	entries := []*entry{
+		{URL: "http://a/"},
+		{URL: "http://a/?"},
+		{URL: "http://a/#x?y=1"},
+		{URL: "http://a/?utm_source=feed&id=1"},
+		{URL: "http://a/?id=2&id=3"},
+		{URL: "http://a/?api_key="},
+	}
+
+	for _, tt := range []struct {
+		set  func()
+		want []string
+	}{
+		{func() { *hasQuery = true }, []string{"http://a/?utm_source=feed&id=1", "http://a/?id=2&id=3", "http://a/?api_key="}},
+		{func() { *noQuery = true }, []string{"http://a/", "http://a/?", "http://a/#x?y=1"}},
+		{func() { queryParams = listFlag{"utm_source"} }, []string{"http://a/?utm_source=feed&id=1"}},
+		{func() { queryParams = listFlag{"id=3", "api_key"} }, []string{"http://a/?id=2&id=3", "http://a/?api_key="}},
+		{func() { queryParams = listFlag{"api_key="} }, []string{"http://a/?api_key="}},
+		{func() { queryParams = listFlag{"y"} }, nil},
+	} {
+		t.Run("", func(t *testing.T) {
+			withFilters(t, tt.set)
+			if got := entryURLs(filterEntries(append([]*entry(nil), entries...))); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncatedFilter(t *testing.T) {
+	rec := warcRecord("WARC-Type: response\r\nWARC-Target-URI: http://a/\r\nWARC-Truncated: Length\r\n", "")
+	res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+	if len(res.entries) != 1 || res.entries[0].Truncated != "length" {
+		t.Fatalf("got %+v", res.entries)
+	}
+
+	entries := []*entry{{URL: "http://a/", Truncated: "length"}, {URL: "http://b/"}}
+	for mode, want := range map[string][]string{
+		"include": {"http://a/", "http://b/"},
+		"skip":    {"http://b/"},
+		"only":    {"http://a/"},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			withFilters(t, func() { *truncated = mode })
+			if got := entryURLs(filterEntries(append([]*entry(nil), entries...))); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRespHeaderFilter(t *testing.T) {
+	withFilters(t, func() { respHeaders = listFlag{"server: nginx.*", "Cache-Control:no-store|private"} })
+	var entries []*entry
+	for i, header := range []string{
+		"Server: nginx/1.25\r\nCache-Control: private",
+		"Server: openresty-nginx\r\nCache-Control: private",
+		"Server: nginx\r\nCache-Control: max-age=60\r\nCache-Control: no-store",
+		"Server: nginx",
+	} {
+		rec := warcRecord(fmt.Sprintf("WARC-Type: response\r\nWARC-Target-URI: http://a/%v\r\n", i)+
+			"Content-Type: application/http; msgtype=response\r\n", "HTTP/1.1 200 OK\r\n"+header+"\r\n\r\n")
+		res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+		entries = append(entries, res.entries...)
+	}
+
+	if got := entryURLs(entries); !reflect.DeepEqual(got, []string{"http://a/0", "http://a/2"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestPortFilter(t *testing.T) {
+	withFilters(t, func() { *ports = "80, 8080" })
+	entries := []*entry{
+		{URL: "http://a/"},
+		{URL: "https://a/"},
+		{URL: "https://a:080/"},
+		{URL: "http://a:8080/x"},
+		{URL: "http://a:443/"},
+		{URL: "dns:a"},
+	}
+
+	if got := entryURLs(filterEntries(entries)); !reflect.DeepEqual(got, []string{"http://a/", "https://a:080/", "http://a:8080/x"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestTLDFilter(t *testing.T) {
+	withFilters(t, func() { *tlds = "GOV,.edu,gov.uk" })
+	entries := []*entry{
+		{URL: "https://www.example.gov/"},
+		{URL: "http://EXAMPLE.EDU./"},
+		{URL: "http://example.gov.uk/"},
+		{URL: "http://example.co.uk/"},
+		{URL: "http://examplegov/"},
+		{URL: "http://gov/"},
+	}
+
+	if got := entryURLs(filterEntries(entries)); !reflect.DeepEqual(got, []string{"https://www.example.gov/", "http://EXAMPLE.EDU./", "http://example.gov.uk/"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestMaxDepthFilter(t *testing.T) {
+	for _, tt := range []struct {
+		url   string
+		depth int
+	}{
+		{"http://a", 0},
+		{"http://a/", 0},
+		{"http://a/?b/c/d", 0},
+		{"http://a/b/c/", 2},
+		{"http://a//b//c#d/e", 2},
+		{"http://a/b%2Fc/d", 2},
+		{"dns:a.example", 0},
+	} {
+		if got := urlDepth(tt.url); got != tt.depth {
+			t.Errorf("%v: got depth %v, want %v", tt.url, got, tt.depth)
+		}
+	}
+
+	withFilters(t, func() { *maxDepth = 1 })
+	entries := []*entry{{URL: "http://a/"}, {URL: "http://a/b"}, {URL: "http://a/b/c"}}
+	if got := entryURLs(filterEntries(entries)); !reflect.DeepEqual(got, []string{"http://a/", "http://a/b"}) {
+		t.Errorf("got %v", got)
+	}
+}
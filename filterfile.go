@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var filterFile = flag.String("filter-file", "", "a YAML or JSON file of include and exclude rules, of which the first matching a URL decides whether it's output")
+
+// filterSpec is a -filter-file. Its rules are evaluated in order, and the
+// first that matches an entry includes or excludes it, or else its
+// default does, include unless set.
+type filterSpec struct {
+	Default string       `yaml:"default"`
+	Rules   []filterRule `yaml:"rules"`
+}
+
+// filterRule matches the entries that match all of its conditions, of
+// which each list matches if any of its items does
+type filterRule struct {
+	Action string   `yaml:"action"` // include or exclude
+	Type   []string `yaml:"type"`
+	MIME   []string `yaml:"mime"`   // or glob patterns, as of -mime
+	Status []string `yaml:"status"` // or ranges, as of -status
+	Scheme []string `yaml:"scheme"`
+	Hosts  []string `yaml:"hosts"` // as of -hosts-allow
+	Match  string   `yaml:"match"` // a regular expression of the URL
+	Where  string   `yaml:"where"` // as of -where
+}
+
+func loadFilterFile(path string) (entryFilter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON is YAML too
+	var spec filterSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, err
+	}
+
+	return spec.compile()
+}
+
+func (spec *filterSpec) compile() (entryFilter, error) {
+	def := true
+	switch spec.Default {
+	case "", "include":
+	case "exclude":
+		def = false
+	default:
+		return nil, fmt.Errorf("default: %q not include or exclude", spec.Default)
+	}
+
+	type rule struct {
+		include bool
+		conds   []entryFilter
+	}
+
+	rules := make([]rule, len(spec.Rules))
+	for i := range spec.Rules {
+		r := &spec.Rules[i]
+		if r.Action != "include" && r.Action != "exclude" {
+			return nil, fmt.Errorf("rule %v: action %q not include or exclude", i+1, r.Action)
+		}
+
+		conds, err := r.conds()
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %v", i+1, err)
+		}
+
+		rules[i] = rule{include: r.Action == "include", conds: conds}
+	}
+
+	return func(e *entry) bool {
+		for _, r := range rules {
+			if matchesAll(r.conds, e) {
+				return r.include
+			}
+		}
+
+		return def
+	}, nil
+}
+
+func (r *filterRule) conds() ([]entryFilter, error) {
+	var conds []entryFilter
+	if len(r.Type) > 0 {
+		types := make(map[string]bool)
+		for _, t := range r.Type {
+			types[strings.ToLower(t)] = true
+		}
+
+		conds = append(conds, func(e *entry) bool {
+			return types[strings.ToLower(e.Type)]
+		})
+	}
+
+	if len(r.MIME) > 0 {
+		patterns := make([]string, len(r.MIME))
+		for i, p := range r.MIME {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("mime: %q: %v", p, err)
+			}
+
+			patterns[i] = strings.ToLower(p)
+		}
+
+		conds = append(conds, func(e *entry) bool {
+			return matchAny(patterns, e.MIME)
+		})
+	}
+
+	if len(r.Status) > 0 {
+		ranges, err := parseStatusRanges(strings.Join(r.Status, ","))
+		if err != nil {
+			return nil, fmt.Errorf("status: %v", err)
+		}
+
+		conds = append(conds, func(e *entry) bool {
+			return inStatusRanges(ranges, e.Status)
+		})
+	}
+
+	if len(r.Scheme) > 0 {
+		schemes := make(map[string]bool)
+		for _, s := range r.Scheme {
+			schemes[strings.ToLower(s)] = true
+		}
+
+		conds = append(conds, func(e *entry) bool {
+			return schemes[urlScheme(e.URL)]
+		})
+	}
+
+	if len(r.Hosts) > 0 {
+		hosts := newHostSet(r.Hosts)
+		conds = append(conds, func(e *entry) bool {
+			return hosts.has(e.host())
+		})
+	}
+
+	if len(r.Match) > 0 {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("match: %v", err)
+		}
+
+		conds = append(conds, func(e *entry) bool {
+			return re.MatchString(e.URL)
+		})
+	}
+
+	if len(r.Where) > 0 {
+		f, err := parseWhere(r.Where)
+		if err != nil {
+			return nil, fmt.Errorf("where: %v", err)
+		}
+
+		conds = append(conds, f)
+	}
+
+	return conds, nil
+}
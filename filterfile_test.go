@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilterFile(t *testing.T) {
+	spec := `{
+		"default": "exclude",
+		"rules": [
+			{"action": "exclude", "mime": ["image/*"]},
+			{"action": "exclude", "where": "url contains \"/private/\""},
+			{"action": "include", "type": ["response"], "status": ["200-299", "304"], "hosts": [".example.com"]},
+			{"action": "include", "scheme": ["dns"]},
+			{"action": "include", "match": "^https://b/"}
+		]
+	}`
+
+	path := filepath.Join(t.TempDir(), "filters.json")
+	os.WriteFile(path, []byte(spec), 0644)
+	withFilters(t, func() { *filterFile = path })
+	entries := []*entry{
+		{URL: "http://www.example.com/", Type: "response", Status: 200, MIME: "text/html"},
+		{URL: "http://www.example.com/a.png", Type: "response", Status: 200, MIME: "image/png"},
+		{URL: "http://example.com/private/x", Type: "response", Status: 200},
+		{URL: "http://example.com/y", Type: "response", Status: 404},
+		{URL: "http://example.com/z", Type: "response", Status: 304},
+		{URL: "http://other.com/", Type: "response", Status: 200},
+		{URL: "http://example.com/", Type: "request"},
+		{URL: "dns:example.com", Type: "response"},
+		{URL: "https://b/c.gif", MIME: "image/gif"},
+		{URL: "https://b/c"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	want := []string{"http://www.example.com/", "http://example.com/z", "dns:example.com", "https://b/c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFilterSpecErrors(t *testing.T) {
+	for _, spec := range []filterSpec{
+		{Default: "drop"},
+		{Rules: []filterRule{{Action: "keep"}}},
+		{Rules: []filterRule{{Action: "include", Status: []string{"abc"}}}},
+		{Rules: []filterRule{{Action: "include", MIME: []string{"["}}}},
+		{Rules: []filterRule{{Action: "include", Match: "("}}},
+		{Rules: []filterRule{{Action: "include", Where: "status =="}}},
+	} {
+		if _, err := spec.compile(); err == nil {
+			t.Errorf("%+v: no error", spec)
+		}
+	}
+
+	// no rules, by default include
+	f, err := (&filterSpec{}).compile()
+	if err != nil || !f(&entry{}) {
+		t.Errorf("empty spec: %v", err)
+	}
+}
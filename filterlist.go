@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// blockList is the rules of AdBlock-style filter lists, of which those
+// for a domain and its subdomains, the bulk of them, are looked up as
+// hosts while the others are matched as one regular expression
+type blockList struct {
+	blockHosts, allowHosts *hostSet
+	block, allow           *regexp.Regexp // nil if none
+}
+
+func loadBlockList(paths []string) (*blockList, error) {
+	var lines []string
+	for _, path := range paths {
+		l, err := readListFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, l...)
+	}
+
+	return newBlockList(lines)
+}
+
+// newBlockList parses the rules in lines: || anchors a domain, | the
+// start or end of the URL, * matches anything, ^ a separator, /.../ is a
+// regular expression and @@ an exception. Options after $ are ignored,
+// and element hiding rules skipped.
+func newBlockList(lines []string) (*blockList, error) {
+	var blockHosts, allowHosts, block, allow []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[Adblock") ||
+			strings.Contains(line, "##") || strings.Contains(line, "#@#") || strings.Contains(line, "#?#") {
+			continue
+		}
+
+		hosts, patterns := &blockHosts, &block
+		rule := line
+		if strings.HasPrefix(rule, "@@") {
+			rule, hosts, patterns = rule[2:], &allowHosts, &allow
+		}
+
+		rule = trimFilterOptions(rule)
+		if host, ok := filterHost(rule); ok {
+			*hosts = append(*hosts, "."+host)
+			continue
+		}
+
+		re, err := filterRegexp(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %v", line, err)
+		} else if len(re) > 0 {
+			*patterns = append(*patterns, re)
+		}
+	}
+
+	l := &blockList{blockHosts: newHostSet(blockHosts), allowHosts: newHostSet(allowHosts)}
+	var err error
+	if l.block, err = compileAlternation(block); err != nil {
+		return nil, err
+	} else if l.allow, err = compileAlternation(allow); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func compileAlternation(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	return regexp.Compile("(?i)(?:" + strings.Join(patterns, ")|(?:") + ")")
+}
+
+var filterOptions = regexp.MustCompile(`^[A-Za-z0-9~,=_|.-]+$`)
+
+// trimFilterOptions returns rule without its $ options
+func trimFilterOptions(rule string) string {
+	if i := strings.LastIndexByte(rule, '$'); i >= 0 && filterOptions.MatchString(rule[i+1:]) {
+		return rule[:i]
+	}
+
+	return rule
+}
+
+// filterHost returns the domain of a ||example.com^ rule
+func filterHost(rule string) (string, bool) {
+	if !strings.HasPrefix(rule, "||") {
+		return "", false
+	}
+
+	host := strings.TrimSuffix(strings.TrimSuffix(rule[2:], "|"), "^")
+	if len(host) == 0 {
+		return "", false
+	}
+
+	for _, c := range host {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '-') {
+			return "", false
+		}
+	}
+
+	return strings.ToLower(host), true
+}
+
+// filterRegexp returns the regular expression of a rule, or an empty
+// string for one that would match everything
+func filterRegexp(rule string) (string, error) {
+	if len(rule) > 2 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+		re := rule[1 : len(rule)-1]
+		_, err := regexp.Compile(re)
+		return re, err
+	}
+
+	var b strings.Builder
+	if strings.HasPrefix(rule, "||") {
+		b.WriteString(`^[a-z][a-z0-9+.-]*://(?:[^/?#]*\.)?`)
+		rule = rule[2:]
+	} else if strings.HasPrefix(rule, "|") {
+		b.WriteString("^")
+		rule = rule[1:]
+	}
+
+	end := strings.HasSuffix(rule, "|")
+	rule = strings.TrimSuffix(rule, "|")
+	if len(strings.Trim(rule, "*")) == 0 {
+		return "", nil
+	}
+
+	for _, c := range rule {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '^':
+			b.WriteString(`(?:[^\w.%-]|$)`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if end {
+		b.WriteString("$")
+	}
+
+	return b.String(), nil
+}
+
+// blocked tells whether a URL of host is blocked by a rule, and not
+// excepted by another
+func (l *blockList) blocked(url, host string) bool {
+	if !l.blockHosts.has(host) && (l.block == nil || !l.block.MatchString(url)) {
+		return false
+	}
+
+	return !l.allowHosts.has(host) && (l.allow == nil || !l.allow.MatchString(url))
+}
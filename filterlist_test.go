@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBlockList(t *testing.T) {
+	l, err := newBlockList([]string{
+		"[Adblock Plus 2.0]",
+		"! a comment",
+		"||tracker.example^",
+		"||ads.example^$third-party",
+		"/pixel.gif?",
+		"|https://cdn.example/beacon",
+		"&utm_campaign=*&ad_id=",
+		"||example.org/ads/*.js|",
+		"/\\/banner[0-9]+\\./",
+		"example.com##.ad",
+		"@@||tracker.example/ok^",
+		"@@||ads.example^",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for url, want := range map[string]bool{
+		"http://tracker.example/t.js":          true,
+		"https://a.TRACKER.example/t.js":       true,
+		"http://nottracker.example/":           false,
+		"http://tracker.example/ok/x":          false,
+		"http://ads.example/x":                 false,
+		"http://a/pixel.gif?id=1":              true,
+		"http://a/pixel.gif":                   false,
+		"https://cdn.example/beacon?x":         true,
+		"http://cdn.example/beacon":            false,
+		"http://a/?x=1&utm_campaign=a&ad_id=2": true,
+		"http://www.example.org/ads/a/b.js":    true,
+		"http://example.org/ads/a.js?v=1":      false,
+		"http://a/img/banner12.png":            true,
+		"http://example.com/":                  false,
+	} {
+		u := &entry{URL: url}
+		if got := l.blocked(url, u.host()); got != want {
+			t.Errorf("%v: got %v, want %v", url, got, want)
+		}
+	}
+}
+
+func TestFilterListsFilter(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	os.WriteFile(a, []byte("||a.example^\n"), 0644)
+	os.WriteFile(b, []byte("! b\n/track?\n"), 0644)
+	withFilters(t, func() { *filterLists = a + "," + b })
+	entries := []*entry{{URL: "http://a.example/"}, {URL: "http://b/track?x"}, {URL: "http://b/"}}
+	if got := entryURLs(filterEntries(entries)); !reflect.DeepEqual(got, []string{"http://b/"}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the endpoints of Cloud Storage and the GCE metadata server, replaceable
+// in tests
+var (
+	gcsBaseURL     = "https://storage.googleapis.com/"
+	gcsMetadataURL = "http://metadata.google.internal/computeMetadata/v1/"
+)
+
+func isGCS(name string) bool {
+	return strings.HasPrefix(name, "gs://")
+}
+
+func parseGCS(name string) (bucket, object string, err error) {
+	bucket, object, _ = strings.Cut(strings.TrimPrefix(name, "gs://"), "/")
+	if len(bucket) == 0 {
+		return "", "", fmt.Errorf("%v: missing bucket", name)
+	}
+
+	return bucket, object, nil
+}
+
+func isGCSPrefix(name string) bool {
+	_, object, err := parseGCS(name)
+	return err == nil && (len(object) == 0 || strings.HasSuffix(object, "/"))
+}
+
+func openGCS(name string, offset, length int64) (io.ReadCloser, error) {
+	bucket, object, err := parseGCS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHTTPRangeReader(gcsObjectURL(bucket, object), offset, length, authorizeGCS)
+}
+
+func gcsObjectURL(bucket, object string) string {
+	return gcsBaseURL + bucket + "/" + uriEscape(object, false)
+}
+
+// listGCS returns the WARC objects under a gs://bucket/prefix/ in name order
+func listGCS(name string) ([]string, error) {
+	bucket, prefix, err := parseGCS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	query := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+	for {
+		r, err := newHTTPReader(gcsBaseURL+"storage/v1/b/"+
+			url.PathEscape(bucket)+"/o?"+query.Encode(), authorizeGCS)
+		if err != nil {
+			return nil, err
+		}
+
+		var res struct {
+			Items []struct {
+				Name string
+			}
+			NextPageToken string
+		}
+
+		err = json.NewDecoder(r).Decode(&res)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range res.Items {
+			if hasWARCExt(obj.Name) {
+				names = append(names, "gs://"+bucket+"/"+obj.Name)
+			}
+		}
+
+		if len(res.NextPageToken) == 0 {
+			break
+		}
+
+		query.Set("pageToken", res.NextPageToken)
+	}
+
+	return names, nil
+}
+
+var (
+	gcsTokenMu      sync.Mutex
+	gcsToken        string
+	gcsTokenExpires time.Time
+	gcsNoToken      bool
+)
+
+// authorizeGCS adds a bearer token from $GOOGLE_OAUTH_ACCESS_TOKEN or the
+// GCE metadata server. Without either, objects must be public.
+func authorizeGCS(req *http.Request) error {
+	token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	if len(token) == 0 {
+		var err error
+		if token, err = metadataToken(); err != nil {
+			return err
+		}
+	}
+
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return nil
+}
+
+func metadataToken() (string, error) {
+	gcsTokenMu.Lock()
+	defer gcsTokenMu.Unlock()
+
+	if gcsNoToken {
+		return "", nil
+	} else if len(gcsToken) > 0 && time.Until(gcsTokenExpires) > 5*time.Minute {
+		return gcsToken, nil
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	req, _ := http.NewRequest("GET", gcsMetadataURL+"instance/service-accounts/default/token", nil)
+	req.Header.Set("Metadata-Flavor", "Google")
+	data, err := metadataRead(client, req)
+	if err != nil {
+		if len(gcsToken) == 0 {
+			// not on GCE, don't keep asking
+			gcsNoToken = true
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.Unmarshal(data, &res); err != nil {
+		return "", err
+	}
+
+	gcsToken = res.AccessToken
+	gcsTokenExpires = time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	return gcsToken, nil
+}
+
+func metadataRead(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{resp.Status, resp.StatusCode}
+	}
+
+	return io.ReadAll(resp.Body)
+}
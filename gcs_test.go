@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGCS(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket, object string
+		prefix, fails  bool
+	}{
+		{"gs://b/crawl/a.warc.gz", "b", "crawl/a.warc.gz", false, false},
+		{"gs://b/crawl/", "b", "crawl/", true, false},
+		{"gs://b", "b", "", true, false},
+		{"gs://b/", "b", "", true, false},
+		{"gs:///a.warc.gz", "", "", false, true},
+	}
+
+	for _, tt := range tests {
+		bucket, object, err := parseGCS(tt.name)
+		if (err != nil) != tt.fails {
+			t.Errorf("%v: got error %v", tt.name, err)
+		} else if bucket != tt.bucket || object != tt.object {
+			t.Errorf("%v: got %q, %q, want %q, %q", tt.name, bucket, object, tt.bucket, tt.object)
+		} else if isGCSPrefix(tt.name) != tt.prefix {
+			t.Errorf("%v: prefix %v, want %v", tt.name, !tt.prefix, tt.prefix)
+		}
+	}
+}
+
+// fakeGCS serves the objects of a bucket, and the tokens of a metadata
+// server under /computeMetadata/v1/
+type fakeGCS struct {
+	objects  map[string]string
+	ntokens  int
+	noToken  bool     // no metadata server
+	auth     []string // the Authorization of each object request
+	pageSize int
+}
+
+func (s *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/computeMetadata/v1/") {
+		if s.noToken || r.Header.Get("Metadata-Flavor") != "Google" ||
+			r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			http.NotFound(w, r)
+			return
+		}
+
+		s.ntokens++
+		fmt.Fprintf(w, `{"access_token": "token-%v", "expires_in": 3600, "token_type": "Bearer"}`, s.ntokens)
+		return
+	}
+
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	if bucket, ok := strings.CutPrefix(r.URL.Path, "/storage/v1/b/"); ok {
+		s.list(w, strings.TrimSuffix(bucket, "/o"), r.URL.Query().Get("prefix"), r.URL.Query().Get("pageToken"))
+		return
+	}
+
+	data, ok := s.objects[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+	var first, last int
+	if n, _ := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &first, &last); n > 0 {
+		if n == 1 || last >= len(data) {
+			last = len(data) - 1
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", first, last, len(data)))
+		w.Header().Set("Content-Length", strconv.Itoa(last-first+1))
+		w.WriteHeader(http.StatusPartialContent)
+		data = data[first : last+1]
+	}
+
+	io.WriteString(w, data)
+}
+
+func (s *fakeGCS) list(w http.ResponseWriter, bucket, prefix, after string) {
+	var names []string
+	for path := range s.objects {
+		if name, ok := strings.CutPrefix(path, "/"+bucket+"/"); ok && strings.HasPrefix(name, prefix) && name > after {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	res := struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+		NextPageToken string `json:"nextPageToken,omitempty"`
+	}{}
+
+	if len(names) > s.pageSize {
+		names, res.NextPageToken = names[:s.pageSize], names[s.pageSize-1]
+	}
+
+	for _, name := range names {
+		res.Items = append(res.Items, struct {
+			Name string `json:"name"`
+		}{name})
+	}
+
+	json.NewEncoder(w).Encode(&res)
+}
+
+// withFakeGCS points Cloud Storage and the metadata server at a fakeGCS
+func withFakeGCS(t *testing.T) *fakeGCS {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	fake := &fakeGCS{objects: make(map[string]string), pageSize: 2}
+	srv := httptest.NewServer(fake)
+	base, metadata := gcsBaseURL, gcsMetadataURL
+	gcsBaseURL, gcsMetadataURL = srv.URL+"/", srv.URL+"/computeMetadata/v1/"
+	gcsToken, gcsTokenExpires, gcsNoToken = "", time.Time{}, false
+	t.Cleanup(func() {
+		srv.Close()
+		gcsBaseURL, gcsMetadataURL = base, metadata
+		gcsToken, gcsTokenExpires, gcsNoToken = "", time.Time{}, false
+	})
+
+	return fake
+}
+
+func TestOpenGCS(t *testing.T) {
+	fake := withFakeGCS(t)
+	data := strings.Repeat("0123456789", 100)
+	fake.objects["/b/a b+c/å.warc.gz"] = data
+
+	for _, tt := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, data},
+		{10, 5, data[10:15]},
+		{990, -1, data[990:]},
+	} {
+		r, err := openGCS("gs://b/a b+c/å.warc.gz", tt.offset, tt.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if string(got) != tt.want {
+			t.Errorf("%v+%v: got %q, want %q", tt.offset, tt.length, got, tt.want)
+		}
+	}
+
+	// the metadata token is fetched once, and sent with each request
+	if fake.ntokens != 1 {
+		t.Errorf("%v tokens fetched, want 1", fake.ntokens)
+	}
+
+	for _, auth := range fake.auth {
+		if auth != "Bearer token-1" {
+			t.Errorf("got Authorization %q", auth)
+		}
+	}
+
+	if _, err := openGCS("gs://b/missing.warc.gz", 0, -1); err == nil {
+		t.Error("opened a missing object")
+	}
+
+	mtime, err := modTime("gs://b/a b+c/å.warc.gz")
+	if err != nil {
+		t.Fatal(err)
+	} else if want := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC); !mtime.Equal(want) {
+		t.Errorf("got %v, want %v", mtime, want)
+	}
+}
+
+func TestGCSTokens(t *testing.T) {
+	fake := withFakeGCS(t)
+	fake.objects["/b/a.warc.gz"] = "x"
+
+	// the environment's token is preferred
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "from-env")
+	if r, err := openGCS("gs://b/a.warc.gz", 0, -1); err != nil {
+		t.Fatal(err)
+	} else {
+		r.Close()
+	}
+
+	// without a token or metadata server, requests are unauthorized
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+	fake.noToken = true
+	for i := 0; i < 2; i++ {
+		if r, err := openGCS("gs://b/a.warc.gz", 0, -1); err != nil {
+			t.Fatal(err)
+		} else {
+			r.Close()
+		}
+	}
+
+	if want := []string{"Bearer from-env", "", ""}; !reflect.DeepEqual(fake.auth, want) {
+		t.Errorf("got %q, want %q", fake.auth, want)
+	} else if !gcsNoToken {
+		t.Error("the missing metadata server will be asked again")
+	}
+}
+
+func TestListGCS(t *testing.T) {
+	fake := withFakeGCS(t)
+	for _, name := range []string{"crawl/a.warc.gz", "crawl/b.txt", "crawl/c.warc", "crawl/d/e.arc.gz", "other/f.warc.gz"} {
+		fake.objects["/b/"+name] = "x"
+	}
+
+	names, err := listGCS("gs://b/crawl/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"gs://b/crawl/a.warc.gz", "gs://b/crawl/c.warc", "gs://b/crawl/d/e.arc.gz"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var webhdfsPort = flag.Int("webhdfs-port", 9870, "WebHDFS port of hdfs:// namenodes given without a port")
+
+func isHDFS(name string) bool {
+	return strings.HasPrefix(name, "hdfs://")
+}
+
+// parseHDFS splits hdfs://namenode[:port]/path. The port is the namenode's
+// WebHDFS (HTTP) port.
+func parseHDFS(name string) (string, string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return "", "", err
+	} else if len(u.Host) == 0 {
+		return "", "", fmt.Errorf("%v: missing namenode", name)
+	}
+
+	host := u.Host
+	if len(u.Port()) == 0 {
+		host = net.JoinHostPort(u.Hostname(), strconv.Itoa(*webhdfsPort))
+	}
+
+	return host, u.Path, nil
+}
+
+func webhdfsURL(host, path, op string, query url.Values) string {
+	if query == nil {
+		query = url.Values{}
+	}
+
+	query.Set("op", op)
+	if user := os.Getenv("HADOOP_USER_NAME"); len(user) > 0 {
+		query.Set("user.name", user)
+	}
+
+	return "http://" + host + "/webhdfs/v1" + uriEscape(path, false) + "?" + query.Encode()
+}
+
+// openHDFS streams a file through WebHDFS. The namenode redirects the
+// request to a datanode, and the range is given as query parameters.
+func openHDFS(name string, offset, length int64) (io.ReadCloser, error) {
+	host, path, err := parseHDFS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &httpReader{url: name, urlAt: func(offset, end int64) string {
+		query := url.Values{}
+		if offset > 0 {
+			query.Set("offset", strconv.FormatInt(offset, 10))
+		}
+
+		if end >= 0 {
+			query.Set("length", strconv.FormatInt(end-offset, 10))
+		}
+
+		return webhdfsURL(host, path, "OPEN", query)
+	}}
+
+	if err := r.open(offset, length); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func isHDFSDir(name string) bool {
+	return isHDFS(name) && strings.HasSuffix(name, "/")
+}
+
+// listHDFS returns the WARC files in an hdfs:// directory in name order
+func listHDFS(name string) ([]string, error) {
+	host, path, err := parseHDFS(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := openHTTP(webhdfsURL(host, path, "LISTSTATUS", nil), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	var res struct {
+		FileStatuses struct {
+			FileStatus []struct {
+				PathSuffix string `json:"pathSuffix"`
+				Type       string `json:"type"`
+			}
+		}
+	}
+
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, st := range res.FileStatuses.FileStatus {
+		if st.Type == "FILE" && hasWARCExt(st.PathSuffix) {
+			names = append(names, name+st.PathSuffix)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no WARC files found")
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHDFS(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	var opens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path == "/webhdfs/v1/crawl/" && q.Get("op") == "LISTSTATUS":
+			io.WriteString(w, `{"FileStatuses": {"FileStatus": [
+				{"pathSuffix": "b.warc.gz", "type": "FILE"},
+				{"pathSuffix": "a.warc.gz", "type": "FILE"},
+				{"pathSuffix": "sub.warc.gz", "type": "DIRECTORY"},
+				{"pathSuffix": "_SUCCESS", "type": "FILE"}
+			]}}`)
+		case r.URL.Path == "/webhdfs/v1/crawl/a.warc.gz" && q.Get("op") == "OPEN":
+			// the namenode redirects to a datanode
+			http.Redirect(w, r, "/datanode?"+r.URL.RawQuery, http.StatusTemporaryRedirect)
+		case r.URL.Path == "/datanode":
+			opens = append(opens, r.URL.RawQuery)
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			end := len(data)
+			if l := q.Get("length"); len(l) > 0 {
+				n, _ := strconv.Atoi(l)
+				end = offset + n
+			}
+
+			w.Header().Set("Content-Length", strconv.Itoa(end-offset))
+			if len(opens) == 1 {
+				// the first transfer breaks off
+				w.Write(data[offset : offset+100])
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+
+			w.Write(data[offset:end])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	base := "hdfs://" + strings.TrimPrefix(srv.URL, "http://") + "/crawl/"
+	got, err := expandInputs([]string{base})
+	if want := []string{base + "a.warc.gz", base + "b.warc.gz"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, %v, want %q", got, err, want)
+	}
+
+	f, err := openRange(got[0], 500, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	out, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(out, data[500:1500]) {
+		t.Fatalf("got %v bytes, %v", len(out), err)
+	}
+
+	want := []string{"length=1000&offset=500&op=OPEN", "length=900&offset=600&op=OPEN"}
+	if !reflect.DeepEqual(opens, want) {
+		t.Fatalf("opened %q, want %q", opens, want)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+// hostSet is a set of hosts, and of domains whose subdomains are in it
+type hostSet struct {
+	hosts   map[string]bool
+	domains map[string]bool
+}
+
+// newHostSet returns the set of hosts, of which those starting with . or
+// *. are domains, matching themselves and their subdomains
+func newHostSet(hosts []string) *hostSet {
+	s := &hostSet{hosts: make(map[string]bool), domains: make(map[string]bool)}
+	for _, h := range hosts {
+		h = strings.TrimSuffix(strings.ToLower(h), ".")
+		if strings.HasPrefix(h, "*.") {
+			h = h[1:]
+		}
+
+		if strings.HasPrefix(h, ".") {
+			s.domains[h[1:]] = true
+		} else {
+			s.hosts[h] = true
+		}
+	}
+
+	return s
+}
+
+func loadHostSet(path string) (*hostSet, error) {
+	hosts, err := readListFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newHostSet(hosts), nil
+}
+
+// has tells whether host, lowercased, is in s
+func (s *hostSet) has(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if len(host) == 0 {
+		return false
+	} else if s.hosts[host] {
+		return true
+	}
+
+	for d := host; ; {
+		if s.domains[d] {
+			return true
+		}
+
+		i := strings.IndexByte(d, '.')
+		if i < 0 {
+			return false
+		}
+
+		d = d[i+1:]
+	}
+}
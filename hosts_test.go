@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestHostSet(t *testing.T) {
+	s := newHostSet([]string{"Example.com", ".example.org", "*.example.net.", "192.0.2.1"})
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"www.example.com", false},
+		{"example.org", true},
+		{"a.b.example.org", true},
+		{"badexample.org", false},
+		{"example.net", true},
+		{"www.example.net.", true},
+		{"192.0.2.1", true},
+		{"org", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.has(tt.host); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHostFilters(t *testing.T) {
+	dir := t.TempDir()
+	allow, deny := filepath.Join(dir, "allow.txt"), filepath.Join(dir, "deny.txt")
+	os.WriteFile(allow, []byte("# in scope\n.example.com\nexample.org\n"), 0644)
+	os.WriteFile(deny, []byte("ads.example.com\n"), 0644)
+	withFilters(t, func() { *hostsAllow, *hostsDeny = allow, deny })
+	entries := []*entry{
+		{URL: "http://www.example.com/"},
+		{URL: "http://ads.example.com/banner"},
+		{URL: "https://EXAMPLE.org:8443/"},
+		{URL: "http://www.example.org/"},
+		{URL: "http://other.example/"},
+	}
+
+	got := entryURLs(filterEntries(entries))
+	if want := []string{"http://www.example.com/", "https://EXAMPLE.org:8443/"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	httpTimeout = flag.Duration("http-timeout", 30*time.Second, "connect, response and idle read timeout for HTTP(S) inputs")
+	httpRetries = flag.Int("http-retries", 5, "number of retries for failed HTTP(S) requests")
+)
+
+var (
+	httpClientOnce sync.Once
+	httpClient     *http.Client
+)
+
+func getHTTPClient() *http.Client {
+	httpClientOnce.Do(func() {
+		dialer := &net.Dialer{Timeout: *httpTimeout}
+		httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				DialContext:           dialer.DialContext,
+				TLSHandshakeTimeout:   *httpTimeout,
+				ResponseHeaderTimeout: *httpTimeout,
+				// compressed WARCs must reach the WARC reader as-is
+				DisableCompression: true,
+			},
+		}
+	})
+
+	return httpClient
+}
+
+func isHTTP(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+type httpStatusError struct {
+	status string
+	code   int
+}
+
+func (e *httpStatusError) Error() string {
+	return "HTTP " + e.status
+}
+
+// temporary client errors are worth retrying, other 4xx are not
+func (e *httpStatusError) permanent() bool {
+	return e.code >= 400 && e.code < 500 &&
+		e.code != http.StatusRequestTimeout &&
+		e.code != http.StatusTooManyRequests
+}
+
+// httpReader streams a remote file. When a request fails mid-stream it
+// is reissued with a Range header from the current offset, so the
+// consumer sees one uninterrupted stream.
+type httpReader struct {
+	url     string
+	prepare func(*http.Request) error // e.g. request signing, may be nil
+	offset  int64
+	end     int64 // offset to stop at, -1 for the end of the file
+	size    int64 // -1 if unknown
+	nfails  int
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	idle    *time.Timer
+
+	// for servers that take the range in the URL instead of a Range
+	// header, returns the URL of the data from offset to end (-1 for all)
+	urlAt func(offset, end int64) string
+
+	// for inputs read with clients of their own, e.g. S3, instead of a
+	// GET: returns the data from offset to end (-1 for all), and the size
+	// of the file, -1 if unknown
+	fetch func(ctx context.Context, offset, end int64) (io.ReadCloser, int64, error)
+}
+
+func openHTTP(url string, offset, length int64) (io.ReadCloser, error) {
+	return newHTTPRangeReader(url, offset, length, nil)
+}
+
+func newHTTPReader(url string, prepare func(*http.Request) error) (*httpReader, error) {
+	return newHTTPRangeReader(url, 0, -1, prepare)
+}
+
+// newHTTPRangeReader reads length bytes from offset on, or to the end of
+// the file if length is negative
+func newHTTPRangeReader(url string, offset, length int64,
+	prepare func(*http.Request) error) (*httpReader, error) {
+	r := &httpReader{url: url, prepare: prepare}
+	if err := r.open(offset, length); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *httpReader) open(offset, length int64) error {
+	r.offset, r.end, r.size = offset, -1, -1
+	if length >= 0 {
+		r.end = offset + length
+	}
+
+	return r.connect()
+}
+
+func (r *httpReader) connect() error {
+	var err error
+	for attempt := 0; attempt <= *httpRetries; attempt++ {
+		if attempt > 0 {
+			slog.Warn("httpReader retrying", "url", r.url, "offset", r.offset, "err", err)
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
+		}
+
+		if err = r.get(); err == nil {
+			return nil
+		} else if serr, ok := err.(*httpStatusError); ok && serr.permanent() {
+			break
+		}
+	}
+
+	return err
+}
+
+func (r *httpReader) get() error {
+	if r.fetch != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		body, size, err := r.fetch(ctx, r.offset, r.end)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		r.size = size
+		r.attach(body, cancel)
+		return nil
+	}
+
+	url := r.url
+	if r.urlAt != nil {
+		url = r.urlAt(r.offset, r.end)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	ranged := r.offset > 0 || r.end >= 0
+	if r.urlAt != nil {
+		// the range is in the URL
+	} else if r.end >= 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.offset, r.end-1))
+	} else if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+	}
+
+	if r.prepare != nil {
+		if err := r.prepare(req); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && ranged:
+	case resp.StatusCode == http.StatusOK && r.urlAt != nil:
+		if resp.ContentLength >= 0 {
+			r.size = r.offset + resp.ContentLength
+		}
+	case resp.StatusCode == http.StatusOK:
+		// no range support, so skip what we already have
+		r.size = resp.ContentLength
+		if _, err := io.CopyN(io.Discard, resp.Body, r.offset); err != nil {
+			resp.Body.Close()
+			cancel()
+			return err
+		}
+	default:
+		resp.Body.Close()
+		cancel()
+		return &httpStatusError{resp.Status, resp.StatusCode}
+	}
+
+	r.attach(resp.Body, cancel)
+	return nil
+}
+
+func (r *httpReader) attach(body io.ReadCloser, cancel context.CancelFunc) {
+	r.body = body
+	r.cancel = cancel
+	// only armed while waiting for data, so that a consumer that stops
+	// reading (backpressure, a full prefetch queue) doesn't kill the
+	// connection
+	r.idle = time.AfterFunc(*httpTimeout, cancel)
+	r.idle.Stop()
+}
+
+func (r *httpReader) close() {
+	if r.body != nil {
+		r.idle.Stop()
+		r.body.Close()
+		r.cancel()
+		r.body = nil
+	}
+}
+
+func (r *httpReader) Read(p []byte) (int, error) {
+	for {
+		if r.end >= 0 {
+			if r.offset >= r.end {
+				return 0, io.EOF
+			} else if int64(len(p)) > r.end-r.offset {
+				// the server may have ignored the end of the range
+				p = p[:r.end-r.offset]
+			}
+		}
+
+		if r.body == nil {
+			if err := r.connect(); err != nil {
+				return 0, err
+			}
+		}
+
+		r.idle.Reset(*httpTimeout)
+		n, err := r.body.Read(p)
+		r.idle.Stop()
+		r.offset += int64(n)
+		if n > 0 {
+			r.nfails = 0
+		}
+
+		if err == io.EOF && (r.size < 0 || r.offset >= r.size) {
+			return n, io.EOF
+		} else if err != nil {
+			r.close()
+			if n > 0 {
+				return n, nil
+			}
+
+			// a connection that keeps dying without making progress
+			if r.nfails++; r.nfails > *httpRetries {
+				return 0, err
+			}
+
+			continue
+		}
+
+		return n, nil
+	}
+}
+
+func (r *httpReader) Close() error {
+	r.close()
+	return nil
+}
+
+// uriEscape percent-encodes everything but the RFC 3986 unreserved
+// characters, and optionally '/', as object stores expect of their keys.
+func uriEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
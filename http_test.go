@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPReaderIdleConsumer(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	var nrequests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nrequests++
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	// only shorten the idle timeout, not the shared client's timeouts
+	getHTTPClient()
+	defer func(d time.Duration) { *httpTimeout = d }(*httpTimeout)
+	*httpTimeout = 50 * time.Millisecond
+
+	r, err := openHTTP(srv.URL, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+	buf := make([]byte, 100)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// a slow consumer must not make the connection time out
+	time.Sleep(200 * time.Millisecond)
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if got := append(buf, rest...); !bytes.Equal(got, data) {
+		t.Fatalf("got %v bytes, want %v", len(got), len(data))
+	} else if nrequests != 1 {
+		t.Fatalf("%v requests, want 1", nrequests)
+	}
+}
+
+func TestHTTPReaderResume(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefghij"), 1000)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		ranges = append(ranges, rng)
+		start := 0
+		if len(rng) > 0 {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)-start))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start:])
+			return
+		}
+
+		// cut the first response short
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data[:3000])
+		panic(http.ErrAbortHandler)
+	}))
+	defer srv.Close()
+
+	r, err := openHTTP(srv.URL, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(got, data) {
+		t.Fatalf("got %v bytes, want %v", len(got), len(data))
+	} else if len(ranges) != 2 || ranges[1] != "bytes=3000-" {
+		t.Fatalf("requests with ranges %q", ranges)
+	}
+}
+
+func TestHTTPReaderRange(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	for _, ranges := range []bool{true, false} {
+		var got []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = append(got, r.Header.Get("Range"))
+			if !ranges {
+				r.Header.Del("Range")
+			}
+
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+		}))
+
+		r, err := openHTTP(srv.URL, 1234, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := io.ReadAll(r)
+		r.Close()
+		srv.Close()
+		if err != nil {
+			t.Errorf("ranges=%v: %v", ranges, err)
+		} else if !bytes.Equal(out, data[1234:1334]) {
+			t.Errorf("ranges=%v: got %q", ranges, out)
+		} else if len(got) != 1 || got[0] != "bytes=1234-1333" {
+			t.Errorf("ranges=%v: requests with ranges %q", ranges, got)
+		}
+	}
+}
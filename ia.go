@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// overridden in tests
+var archiveOrgBase = "https://archive.org/"
+
+func isIA(name string) bool {
+	return strings.HasPrefix(name, "ia://")
+}
+
+// parseIA splits ia://identifier[/file]
+func parseIA(name string) (string, string, error) {
+	id, file, _ := strings.Cut(strings.TrimPrefix(name, "ia://"), "/")
+	if len(id) == 0 || strings.ContainsAny(id, "?#") {
+		return "", "", fmt.Errorf("%v: not an item identifier", name)
+	}
+
+	return id, file, nil
+}
+
+func isIAItem(name string) bool {
+	_, file, err := parseIA(name)
+	return err == nil && len(file) == 0
+}
+
+func iaDownloadURL(id, file string) string {
+	return archiveOrgBase + "download/" + url.PathEscape(id) + "/" + uriEscape(file, false)
+}
+
+func openIA(name string, offset, length int64) (io.ReadCloser, error) {
+	id, file, err := parseIA(name)
+	if err != nil {
+		return nil, err
+	} else if len(file) == 0 {
+		return nil, fmt.Errorf("%v: item, not a file", name)
+	}
+
+	return openHTTP(iaDownloadURL(id, file), offset, length)
+}
+
+// listIA returns the WARC files of an item as ia://identifier/file, in
+// name order, from the metadata API
+func listIA(name string) ([]string, error) {
+	id, _, err := parseIA(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := openHTTP(archiveOrgBase+"metadata/"+url.PathEscape(id), 0, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	var res struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+		IsDark bool `json:"is_dark"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return nil, err
+	} else if res.IsDark {
+		return nil, errors.New("item is not available")
+	}
+
+	var names []string
+	for _, f := range res.Files {
+		if hasWARCExt(f.Name) {
+			names = append(names, "ia://"+id+"/"+f.Name)
+		}
+	}
+
+	// an unknown identifier returns an empty object
+	if len(names) == 0 {
+		return nil, errors.New("no WARC files found")
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestIA(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/metadata/crawl-1":
+			io.WriteString(w, `{"files": [
+				{"name": "b.warc.gz", "format": "Web ARChive GZ"},
+				{"name": "crawl-1.cdx.gz", "format": "CDX Index"},
+				{"name": "sub dir/a.warc.gz", "format": "Web ARChive GZ"},
+				{"name": "crawl-1_meta.xml", "format": "Metadata"}
+			]}`)
+		case "/metadata/missing":
+			io.WriteString(w, `{}`)
+		case "/download/crawl-1/sub%20dir/a.warc.gz":
+			io.WriteString(w, "data")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(base string) { archiveOrgBase = base }(archiveOrgBase)
+	archiveOrgBase = srv.URL + "/"
+
+	got, err := expandInputs([]string{"ia://crawl-1"})
+	want := []string{"ia://crawl-1/b.warc.gz", "ia://crawl-1/sub dir/a.warc.gz"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, %v, want %q", got, err, want)
+	}
+
+	if _, err := expandInputs([]string{"ia://missing"}); err == nil {
+		t.Error("no error for an unknown item")
+	}
+
+	f, err := openInput(got[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if data, err := io.ReadAll(f); err != nil || string(data) != "data" {
+		t.Fatalf("got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+var idnForm = flag.String("idn", "", "write internationalized hostnames as unicode, their U-labels, or as ascii, their punycode A-labels, however they were captured")
+
+// idnURL returns s with the labels of its host converted to unicode or to
+// ascii. Labels that aren't valid in the form they're in, e.g. bad
+// punycode or invalid UTF-8, are left as they are, and so are IP
+// literals and URLs without a host.
+func idnURL(s string, toUnicode bool) string {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return s
+	}
+
+	start := i + 3
+	end := len(s)
+	if j := strings.IndexAny(s[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+
+	host := s[start:end]
+	if at := strings.LastIndexByte(host, '@'); at >= 0 {
+		start += at + 1
+		host = host[at+1:]
+	}
+
+	if strings.HasPrefix(host, "[") {
+		return s
+	} else if c := strings.LastIndexByte(host, ':'); c >= 0 {
+		host = host[:c]
+	}
+
+	// hosts may be percent-encoded, as UTF-8 or else left as they are
+	decoded, err := url.PathUnescape(host)
+	if err != nil || !utf8.ValidString(decoded) {
+		decoded = host
+	}
+
+	conv := idnHost(decoded, toUnicode)
+	if conv == host {
+		return s
+	}
+
+	return s[:start] + conv + s[start+len(host):]
+}
+
+func idnHost(host string, toUnicode bool) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		var conv string
+		var err error
+		if toUnicode && len(label) > 4 && strings.EqualFold(label[:4], "xn--") {
+			conv, err = idna.Lookup.ToUnicode(label)
+		} else if !toUnicode && !isASCII(label) {
+			conv, err = idna.Lookup.ToASCII(label)
+		} else {
+			continue
+		}
+
+		if err == nil && len(conv) > 0 {
+			labels[i] = conv
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
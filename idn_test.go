@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestIDNURL(t *testing.T) {
+	tests := []struct {
+		in        string
+		toUnicode bool
+		want      string
+	}{
+		{"http://xn--bcher-kva.example/a", true, "http://bücher.example/a"},
+		{"http://XN--BCHER-KVA.example/a", true, "http://bücher.example/a"},
+		{"http://bücher.example/a", false, "http://xn--bcher-kva.example/a"},
+		{"http://user@bücher.example:8080/a?b#c", false, "http://user@xn--bcher-kva.example:8080/a?b#c"},
+		{"http://xn--bcher-kva.bücher.example/", false, "http://xn--bcher-kva.xn--bcher-kva.example/"},
+		{"http://xn--bcher-kva.bücher.example/", true, "http://bücher.bücher.example/"},
+		{"http://b%C3%BCcher.example/", false, "http://xn--bcher-kva.example/"},
+		{"http://b%C3%BCcher.example/", true, "http://bücher.example/"},
+		{"http://b%FCcher.example/", true, "http://b%FCcher.example/"},
+		{"http://xn--.example/", true, "http://xn--.example/"},
+		{"http://xn--a!b.example/", true, "http://xn--a!b.example/"},
+		{"http://Example.com/", false, "http://Example.com/"},
+		{"http://[::1]:80/", true, "http://[::1]:80/"},
+		{"dns:bücher.example", false, "dns:bücher.example"},
+	}
+
+	for _, tt := range tests {
+		if got := idnURL(tt.in, tt.toUnicode); got != tt.want {
+			t.Errorf("idnURL(%q, %v) = %q, want %q", tt.in, tt.toUnicode, got, tt.want)
+		}
+	}
+}
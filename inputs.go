@@ -0,0 +1,347 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const prefetchChunkSize = 1 << 20
+
+// prefetchReader opens an input and reads ahead into a bounded queue of
+// chunks in the background, so that the next remote input is already
+// streaming while the current one is being parsed.
+type prefetchReader struct {
+	chunks chan []byte
+	done   chan struct{}
+	err    error // set before chunks is closed
+	cur    []byte
+}
+
+func startPrefetch(name string, offset, length int64, nbytes int) *prefetchReader {
+	nchunks := nbytes / prefetchChunkSize
+	if nchunks < 1 {
+		nchunks = 1
+	}
+
+	p := &prefetchReader{
+		chunks: make(chan []byte, nchunks),
+		done:   make(chan struct{}),
+	}
+
+	go p.run(name, offset, length)
+	return p
+}
+
+func (p *prefetchReader) run(name string, offset, length int64) {
+	defer close(p.chunks)
+	f, err := openRange(name, offset, length)
+	if err != nil {
+		p.err = err
+		return
+	}
+
+	defer f.Close()
+	for {
+		buf := make([]byte, prefetchChunkSize)
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			select {
+			case p.chunks <- buf[:n]:
+			case <-p.done:
+				return
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return
+		} else if err != nil {
+			p.err = err
+			return
+		}
+	}
+}
+
+func (p *prefetchReader) Read(b []byte) (int, error) {
+	if len(p.cur) == 0 {
+		chunk, ok := <-p.chunks
+		if !ok {
+			if p.err != nil {
+				return 0, p.err
+			}
+
+			return 0, io.EOF
+		}
+
+		p.cur = chunk
+	}
+
+	n := copy(b, p.cur)
+	p.cur = p.cur[n:]
+	return n, nil
+}
+
+func (p *prefetchReader) Close() error {
+	close(p.done)
+	for range p.chunks {
+	}
+
+	return nil
+}
+
+const stdinInput = "-"
+
+// openInput opens a named WARC input for reading. "-" is standard input
+// and http(s)://, s3://, gs://, sftp://, ia:// and hdfs:// URLs are
+// streamed from the network.
+func openInput(name string) (io.ReadCloser, error) {
+	return openRange(name, 0, -1)
+}
+
+// stdinReader is standard input, which isn't closed along with the input
+type stdinReader struct {
+	*os.File
+}
+
+func (stdinReader) Close() error {
+	return nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// openRange opens length bytes of an input starting at offset, or the
+// rest of the input if length is negative. Remote inputs are requested
+// with a Range header, local files are seeked and standard input is
+// skipped forward.
+func openRange(name string, offset, length int64) (io.ReadCloser, error) {
+	var f io.ReadCloser
+	var err error
+	if isHTTP(name) {
+		return openHTTP(name, offset, length)
+	} else if isS3(name) {
+		return openS3(name, offset, length)
+	} else if isGCS(name) {
+		return openGCS(name, offset, length)
+	} else if isSFTP(name) {
+		return openSFTP(name, offset, length)
+	} else if isIA(name) {
+		return openIA(name, offset, length)
+	} else if isHDFS(name) {
+		return openHDFS(name, offset, length)
+	} else if name == stdinInput {
+		f = stdinReader{os.Stdin}
+		if offset > 0 {
+			_, err = io.CopyN(io.Discard, f, offset)
+		}
+	} else {
+		var file *os.File
+		if file, err = os.Open(name); err != nil {
+			return nil, err
+		}
+
+		f = file
+		if offset > 0 {
+			_, err = file.Seek(offset, io.SeekStart)
+		}
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	} else if length >= 0 {
+		return limitedReadCloser{io.LimitReader(f, length), f}, nil
+	}
+
+	return f, nil
+}
+
+var warcExts = []string{
+	".warc", ".warc.gz", ".warc.bz2", ".warc.xz", ".warc.zst",
+	".arc", ".arc.gz", ".wat.gz", ".wet.gz",
+}
+
+func hasWARCExt(name string) bool {
+	// encrypted with age
+	name = strings.TrimSuffix(name, ".age")
+	for _, ext := range warcExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// walkDir returns the WARC files found under root in lexical order, so
+// repeated runs over the same tree process files in the same order. The
+// number of regular files that were ignored is returned as well.
+func walkDir(root string) ([]string, int, error) {
+	var paths []string
+	var nignored int
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+
+			slog.Error("walkDir", "err", err)
+			return nil
+		}
+
+		if d.Type().IsRegular() {
+			if hasWARCExt(d.Name()) {
+				paths = append(paths, path)
+			} else {
+				nignored++
+			}
+		}
+
+		return nil
+	})
+
+	return paths, nignored, err
+}
+
+// readPathsFile reads a newline separated list of inputs, like the
+// warc.paths.gz files published by Common Crawl. The list itself may be
+// gzipped and may be any input openInput understands. Relative entries
+// are prefixed with base, if set.
+func readPathsFile(name, base string) ([]string, error) {
+	f, err := openInput(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		defer zr.Close()
+		r = zr
+	}
+
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if len(base) > 0 && !isRemote(line) && !filepath.IsAbs(line) {
+			line = strings.TrimSuffix(base, "/") + "/" + line
+		}
+
+		paths = append(paths, line)
+	}
+
+	return paths, scanner.Err()
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isRemote(name string) bool {
+	return strings.Contains(name, "://")
+}
+
+func isGlob(pattern string) bool {
+	return !isRemote(pattern) && strings.ContainsAny(pattern, "*?[")
+}
+
+// expandInputs expands glob patterns and bucket prefixes in the input list.
+// Patterns are expanded here rather than by the shell so that large
+// crawls don't run into ARG_MAX. Other inputs are passed through as-is.
+func expandInputs(inputs []string) ([]string, error) {
+	var paths []string
+	for _, input := range inputs {
+		if isS3(input) && isS3Prefix(input) {
+			names, err := listS3(input)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", input, err)
+			}
+
+			paths = append(paths, names...)
+			continue
+		} else if isGCS(input) && isGCSPrefix(input) {
+			names, err := listGCS(input)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", input, err)
+			}
+
+			paths = append(paths, names...)
+			continue
+		} else if isIA(input) && isIAItem(input) {
+			names, err := listIA(input)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", input, err)
+			}
+
+			paths = append(paths, names...)
+			continue
+		} else if isHDFSDir(input) {
+			names, err := listHDFS(input)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", input, err)
+			}
+
+			paths = append(paths, names...)
+			continue
+		} else if isSFTPDir(input) {
+			names, err := listSFTP(input)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", input, err)
+			}
+
+			paths = append(paths, names...)
+			continue
+		} else if !isGlob(input) {
+			paths = append(paths, input)
+			continue
+		}
+
+		matches, err := filepath.Glob(input)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", input, err)
+		} else if len(matches) == 0 {
+			return nil, fmt.Errorf("%v: no matching files", input)
+		}
+
+		paths = append(paths, matches...)
+	}
+
+	return paths, nil
+}
+
+// splitList splits a comma separated flag value
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenRange(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "x.warc")
+	if err := os.WriteFile(name, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, -1, "0123456789"},
+		{3, -1, "3456789"},
+		{3, 4, "3456"},
+		{0, 0, ""},
+		{8, 10, "89"},
+	}
+
+	for _, tt := range tests {
+		f, err := openRange(name, tt.offset, tt.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%v+%v: got %q, %v, want %q", tt.offset, tt.length, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sebcat/warc"
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	kafkaBrokers = flag.String("kafka-brokers", "", "comma separated Kafka brokers to consume WARC records from")
+	kafkaTopic   = flag.String("kafka-topic", "", "Kafka topic of -kafka-brokers")
+	kafkaGroup   = flag.String("kafka-group", "warc-urls", "Kafka consumer group of -kafka-brokers")
+)
+
+// kafkaRecords consumes WARC records from a Kafka topic. A message holds
+// one or more records, possibly compressed. Its offset is committed once
+// the results of all its records have been written, so a restarted
+// consumer continues after the last message fully processed.
+func kafkaRecords(brokers []string, topic, group string, recs chan *rawRecord, nrecords *int) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  brokers,
+		Topic:    topic,
+		GroupID:  group,
+		MaxBytes: 64 << 20,
+	})
+
+	commits := make(chan kafka.Message, 1024)
+	done := make(chan struct{})
+	go kafkaCommit(r, commits, done)
+
+	in := &inputFile{path: "kafka:" + topic}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stopping
+		cancel()
+	}()
+
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			if !stopped() {
+				slog.Error("kafkaRecords", "err", err)
+			}
+
+			break
+		}
+
+		if err := readMessage(in, msg.Value, recs, nrecords); err != nil {
+			slog.Error("kafkaRecords", "partition", msg.Partition, "offset", msg.Offset, "err", err)
+		}
+
+		recs <- &rawRecord{in: in, seq: in.nrecs, offset: -1, ack: func() { commits <- msg }}
+		in.nrecs++
+	}
+
+	recs <- &rawRecord{in: in, seq: in.nrecs, last: true, ack: func() {
+		// wait for the last commits before the results are done
+		close(commits)
+		<-done
+	}}
+	close(recs)
+}
+
+func readMessage(in *inputFile, value []byte, recs chan *rawRecord, nrecords *int) error {
+	r, err := newRecordReader(bytes.NewReader(value), "auto", false)
+	if err != nil {
+		return err
+	}
+
+	for !stopped() {
+		started := time.Now()
+		rec, err := r.NextRaw()
+		if err == io.EOF {
+			return nil
+		}
+
+		metrics.observe("read", started)
+		metrics.recordsRead.Add(1)
+		if err == warc.ErrMalformedRecord {
+			metrics.parseErrors.Add(1)
+			slog.Error("readMessage", "err", err)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		recs <- &rawRecord{in: in, seq: in.nrecs, data: rec, offset: -1, length: -1}
+		in.nrecs++
+		if nrecords != nil {
+			*nrecords++
+		}
+	}
+
+	return nil
+}
+
+// kafkaCommit commits the offsets of processed messages, batching those
+// that are done while a commit is in progress, and closes r when commits
+// is closed
+func kafkaCommit(r *kafka.Reader, commits chan kafka.Message, done chan struct{}) {
+	defer close(done)
+	defer r.Close()
+	for msg := range commits {
+		batch := []kafka.Message{msg}
+	drain:
+		for len(batch) < cap(commits) {
+			select {
+			case msg, ok := <-commits:
+				if !ok {
+					break drain
+				}
+
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+
+		if err := r.CommitMessages(context.Background(), batch...); err != nil {
+			slog.Error("kafkaCommit", "err", err)
+		}
+	}
+}
+
+func isKafkaURL(s string) bool {
+	return strings.HasPrefix(s, "kafka://")
+}
+
+// parseKafkaURL splits kafka://broker[,broker...]/topic
+func parseKafkaURL(s string) ([]string, string, error) {
+	brokers, topic, _ := strings.Cut(strings.TrimPrefix(s, "kafka://"), "/")
+	if len(splitList(brokers)) == 0 || len(topic) == 0 || strings.Contains(topic, "/") {
+		return nil, "", fmt.Errorf("%v: not kafka://broker/topic", s)
+	}
+
+	return splitList(brokers), topic, nil
+}
+
+// messageWriter is a kafka.Writer
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaWriter publishes entries as messages of a URL, or of a JSON object
+// for -format jsonl, keyed by host so that the URLs of a host go to the
+// same partition. Messages are sent in batches, and waited for on Flush.
+type kafkaWriter struct {
+	w     messageWriter
+	json  bool
+	batch []kafka.Message
+}
+
+const kafkaBatch = 1000
+
+func newKafkaWriter(url, format string) (*kafkaWriter, error) {
+	brokers, topic, err := parseKafkaURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaWriter{
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchSize:    kafkaBatch,
+			BatchTimeout: 100 * time.Millisecond,
+			RequiredAcks: kafka.RequireAll,
+		},
+		json: format == "jsonl",
+	}, nil
+}
+
+func (w *kafkaWriter) Write(e *entry) error {
+	value := []byte(e.URL)
+	if w.json {
+		var err error
+		if value, err = marshalEntry(e); err != nil {
+			return err
+		}
+	}
+
+	w.batch = append(w.batch, kafka.Message{Key: []byte(e.host()), Value: value})
+	if len(w.batch) >= kafkaBatch {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends the batch and waits for it to be acknowledged
+func (w *kafkaWriter) Flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+
+	err := w.w.WriteMessages(context.Background(), w.batch...)
+	w.batch = w.batch[:0]
+	return err
+}
+
+func (w *kafkaWriter) Close() error {
+	err := w.Flush()
+	if cerr := w.w.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
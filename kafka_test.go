@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestReadMessage(t *testing.T) {
+	recs := targetRecord("http://a/") + targetRecord("http://b/")
+	for _, value := range [][]byte{[]byte(recs), gzipped(t, recs)} {
+		ch := make(chan *rawRecord, 10)
+		in := &inputFile{}
+		var n int
+		if err := readMessage(in, value, ch, &n); err != nil {
+			t.Fatal(err)
+		}
+
+		close(ch)
+		var urls []string
+		for rec := range ch {
+			urls = append(urls, entryURLs(newResult(rec).entries)...)
+		}
+
+		if want := []string{"http://a/", "http://b/"}; !reflect.DeepEqual(urls, want) {
+			t.Errorf("got %q, want %q", urls, want)
+		} else if n != 2 || in.nrecs != 2 {
+			t.Errorf("%v records, seq %v", n, in.nrecs)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	got := splitList(" kafka-1:9092,kafka-2:9092,, ")
+	if want := []string{"kafka-1:9092", "kafka-2:9092"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestParseKafkaURL(t *testing.T) {
+	brokers, topic, err := parseKafkaURL("kafka://k1:9092,k2:9092/urls")
+	if err != nil || !reflect.DeepEqual(brokers, []string{"k1:9092", "k2:9092"}) || topic != "urls" {
+		t.Errorf("got %q %q %v", brokers, topic, err)
+	}
+
+	for _, bad := range []string{"kafka://k1:9092", "kafka:///urls", "kafka://k1/a/b"} {
+		if _, _, err := parseKafkaURL(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+type fakeProducer struct {
+	batches [][]kafka.Message
+	closed  bool
+}
+
+func (p *fakeProducer) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	p.batches = append(p.batches, append([]kafka.Message(nil), msgs...))
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaWriter(t *testing.T) {
+	for _, format := range []string{"text", "jsonl"} {
+		p := &fakeProducer{}
+		kw, err := newKafkaWriter("kafka://k1:9092/urls", format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kw.w = p
+		w := newResultWriter(kw)
+		for i := 0; i < kafkaBatch+10; i++ {
+			w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1},
+				entries: []*entry{{URL: fmt.Sprintf("http://h%v.example/?%v&", i%3, i/2), Type: "response"}}})
+		}
+
+		// a full batch is sent as it's filled, the rest before a checkpoint
+		if len(p.batches) != 1 || len(p.batches[0]) != kafkaBatch {
+			t.Fatalf("%v: %v batches", format, len(p.batches))
+		}
+
+		(&checkpointer{path: filepath.Join(t.TempDir(), "ckpt")}).save(w)
+		if len(p.batches) != 2 || len(p.batches[1]) != 10 {
+			t.Fatalf("%v: %v batches after the checkpoint", format, len(p.batches))
+		} else if err := kw.Close(); err != nil || !p.closed {
+			t.Fatalf("%v: closed %v %v", format, p.closed, err)
+		}
+
+		msg := p.batches[0][5]
+		want := "http://h2.example/?2&"
+		if format == "jsonl" {
+			want = `{"url":"http://h2.example/?2&","warc_type":"response","length":0}`
+		}
+
+		if string(msg.Key) != "h2.example" || string(msg.Value) != want {
+			t.Errorf("%v: got %q %q", format, msg.Key, msg.Value)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var langs = flag.String("lang", "", "only output the URLs of text/html responses whose text is detected to be in these languages, comma separated ISO 639-1 codes of those detected (da, de, en, es, fi, fr, it, nl, no, pl, pt, sv)")
+
+// the most of a body whose text is looked at
+const langMaxSize = 64 << 10
+
+// a detected language has at least this many of its stopwords, and more
+// than any other
+const langMinHits = 3
+
+// langStopwords are common words of the languages detected,
+// distinctive of them together
+var langStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "it", "for", "with", "was", "on", "are", "this", "be", "by", "you"},
+	"sv": {"och", "att", "det", "som", "är", "på", "för", "med", "av", "inte", "den", "till", "har", "jag", "om", "var"},
+	"da": {"og", "det", "at", "er", "til", "på", "med", "ikke", "som", "af", "for", "har", "den", "jeg", "de", "et"},
+	"no": {"og", "det", "er", "ikke", "til", "på", "med", "som", "av", "for", "har", "jeg", "den", "å", "var", "ble"},
+	"fi": {"ja", "on", "ei", "se", "että", "oli", "hän", "mutta", "kun", "niin", "myös", "ovat", "tai", "jos", "kuin"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "mit", "sich", "auf", "für", "ein", "eine", "dem", "zu", "auch", "von"},
+	"nl": {"het", "een", "en", "van", "is", "dat", "niet", "op", "te", "voor", "met", "zijn", "ook", "aan", "maar", "de"},
+	"fr": {"le", "les", "et", "des", "est", "une", "pas", "pour", "dans", "que", "qui", "sur", "au", "du", "avec", "il"},
+	"es": {"el", "los", "las", "y", "que", "en", "es", "por", "una", "con", "para", "del", "se", "no", "lo", "como"},
+	"it": {"il", "che", "di", "per", "non", "una", "sono", "con", "del", "della", "è", "gli", "anche", "come", "nel"},
+	"pt": {"os", "que", "não", "do", "da", "em", "um", "uma", "para", "com", "é", "por", "mais", "dos", "como"},
+	"pl": {"i", "w", "nie", "na", "się", "jest", "że", "z", "do", "to", "jak", "ale", "co", "tak", "po"},
+}
+
+// the languages of each stopword
+var stopwordLangs = func() map[string][]string {
+	m := make(map[string][]string)
+	for lang, words := range langStopwords {
+		for _, w := range words {
+			m[w] = append(m[w], lang)
+		}
+	}
+
+	return m
+}()
+
+// detectedLangs returns the codes of langStopwords in order
+func detectedLangs() []string {
+	var codes []string
+	for lang := range langStopwords {
+		codes = append(codes, lang)
+	}
+
+	sort.Strings(codes)
+	return codes
+}
+
+var htmlLang = regexp.MustCompile(`(?i)<html[^>]*\slang\s*=\s*["']?([a-z]{2,3})`)
+
+// detectLang returns the language of the text of the HTML response of
+// block by its stopwords, or else as declared by its lang attribute, or
+// an empty string if neither tells
+func detectLang(block []byte) string {
+	_, body, err := httpBody(block, langMaxSize)
+	if err != nil {
+		return ""
+	}
+
+	if lang := textLang(stripTags(body)); len(lang) > 0 {
+		return lang
+	} else if m := htmlLang.FindSubmatch(body); m != nil {
+		lang := string(bytes.ToLower(m[1]))
+		if lang == "nb" || lang == "nn" {
+			lang = "no"
+		}
+
+		return lang
+	}
+
+	return ""
+}
+
+// textLang returns the language of text with the most stopwords, if it's
+// got enough of them and more than any other
+func textLang(text string) string {
+	hits := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		for _, lang := range stopwordLangs[w] {
+			hits[lang]++
+		}
+	}
+
+	var best string
+	var most, second int
+	for lang, n := range hits {
+		if n > most {
+			best, most, second = lang, n, most
+		} else if n > second {
+			second = n
+		}
+	}
+
+	if most < langMinHits || most == second {
+		return ""
+	}
+
+	return best
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTextLang(t *testing.T) {
+	for text, want := range map[string]string{
+		"The quick brown fox jumps over the lazy dog, and it is in this story that the dog was sleeping.":        "en",
+		"Det var en gång en katt som inte ville gå till skolan, och den satt på taket för att vänta.":            "sv",
+		"Der Hund ist nicht mit der Katze auf dem Dach, und das ist auch gut so für die Nachbarn von uns.":       "de",
+		"Le chat est dans la maison et il ne veut pas sortir pour la pluie qui tombe sur les toits du quartier.": "fr",
+		"El perro y el gato están en la casa, que es grande, por una razón que no se sabe con certeza.":          "es",
+		"Hello world.": "",
+		"":             "",
+	} {
+		if got := textLang(text); got != want {
+			t.Errorf("%q: got %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestLangFilter(t *testing.T) {
+	withFilters(t, func() { *langs = "en,SV" })
+	var entries []*entry
+	for i, html := range []string{
+		"<html><body><p>This is a page about the history of the city, and it is in English.</p></body></html>",
+		"<html lang=sv><body><p>Det här är en sida om staden, och den är på svenska för att alla ska förstå.</p></body></html>",
+		"<html><body><p>Der Hund ist nicht mit der Katze auf dem Dach, und das ist auch gut so.</p></body></html>",
+		"<html lang=en-GB><body><p>Hi!</p></body></html>",
+		"<html lang=de><body><p>Hallo!</p></body></html>",
+	} {
+		rec := warcRecord(fmt.Sprintf("WARC-Type: response\r\nWARC-Target-URI: http://a/%v\r\n", i)+
+			"Content-Type: application/http; msgtype=response\r\n",
+			"HTTP/1.1 200 OK\r\nContent-Type: text/html; charset=utf-8\r\n\r\n"+html)
+		res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+		entries = append(entries, res.entries...)
+	}
+
+	if got := entryURLs(entries); !reflect.DeepEqual(got, []string{"http://a/0", "http://a/1", "http://a/3"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestLangSetting(t *testing.T) {
+	withFilters(t, func() {})
+	for _, setting := range []string{"ja", "en,x", "english"} {
+		*langs = setting
+		if err := setupFilters(); err == nil || !strings.Contains(err.Error(), "da, de, en, es") {
+			t.Errorf("%q: got %v", setting, err)
+		}
+	}
+}
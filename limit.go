@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"sync"
+)
+
+var (
+	limitURLs    = flag.Int64("limit", 0, "stop once this many URLs have been written, e.g. to spot-check an archive")
+	limitRecords = flag.Int64("limit-records", 0, "stop once the URLs of this many records have been written")
+	perHost      = flag.Int("per-host", 0, "output at most this many URLs of each registered domain, e.g. 1 for a seed list")
+	skipRecords  = flag.Int64("skip", 0, "skip the first this many records with URLs, which are still deduplicated against, e.g. to page through an archive with -limit-records")
+)
+
+var (
+	stopping = make(chan struct{}) // closed to stop reading the inputs
+	stopOnce sync.Once
+)
+
+// stopInputs makes the readers of the inputs stop, as if they had all
+// been read
+func stopInputs() {
+	stopOnce.Do(func() { close(stopping) })
+}
+
+func stopped() bool {
+	select {
+	case <-stopping:
+		return true
+	default:
+		return false
+	}
+}
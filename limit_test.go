@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestResultWriterLimits(t *testing.T) {
+	results := func() []*result {
+		var res []*result
+		for i, urls := range [][]string{{"http://a/", "http://b/"}, {"http://a/"}, {"http://c/", "http://d/"}, {"http://e/"}} {
+			r := &result{rec: &rawRecord{in: &inputFile{}, seq: i, data: []byte("r"), offset: -1}}
+			r.add(&entry{}, "", urls)
+			res = append(res, r)
+		}
+
+		return res
+	}
+
+	for _, tt := range []struct {
+		urls, records int64
+		want          string
+		nrecords      int
+	}{
+		{0, 0, "http://a/\nhttp://b/\nhttp://c/\nhttp://d/\nhttp://e/\n", 4},
+		{3, 0, "http://a/\nhttp://b/\nhttp://c/\n", 3},
+		{2, 0, "http://a/\nhttp://b/\n", 1},
+		{0, 2, "http://a/\nhttp://b/\n", 2},
+		{0, 3, "http://a/\nhttp://b/\nhttp://c/\nhttp://d/\n", 3},
+	} {
+		var out bytes.Buffer
+		w := newResultWriter(newEntryWriter("text", &out))
+		w.limitURLs, w.limitRecords = tt.urls, tt.records
+		for _, res := range results() {
+			w.add(res)
+		}
+
+		if out.String() != tt.want {
+			t.Errorf("-limit %v -limit-records %v: got %q, want %q", tt.urls, tt.records, out.String(), tt.want)
+		} else if w.nrecords != tt.nrecords {
+			t.Errorf("-limit %v -limit-records %v: got %v records, want %v", tt.urls, tt.records, w.nrecords, tt.nrecords)
+		} else if stopped() != (tt.urls > 0 || tt.records > 0) {
+			t.Errorf("-limit %v -limit-records %v: stopped %v", tt.urls, tt.records, stopped())
+		}
+
+		stopping, stopOnce = make(chan struct{}), sync.Once{}
+	}
+}
+
+func TestReadFileStopped(t *testing.T) {
+	defer func() { stopping, stopOnce = make(chan struct{}), sync.Once{} }()
+	data := warcRecord("WARC-Type: response\r\nWARC-Target-URI: http://a/\r\n", "a")
+	recs := make(chan *rawRecord) // would block if anything was sent
+	stopInputs()
+	if err := readFile(&inputFile{}, "a.warc", strings.NewReader(data), true, recs, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResultWriterSkip(t *testing.T) {
+	defer func() { stopping, stopOnce = make(chan struct{}), sync.Once{} }()
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	w.skipRecords, w.limitRecords = 2, 2
+	for i, urls := range [][]string{{"http://a/"}, {}, {"http://b/"}, {"http://a/", "http://c/"}, {"http://d/"}, {"http://e/"}} {
+		r := &result{rec: &rawRecord{in: &inputFile{}, seq: i, data: []byte("r"), offset: -1}}
+		r.add(&entry{}, "", urls)
+		w.add(r)
+	}
+
+	// a is skipped, but still not written again
+	if want := "http://c/\nhttp://d/\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestResultWriterPerHost(t *testing.T) {
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	w.perHost = 2
+	for i, urls := range [][]string{
+		{"http://a.example.com/", "http://b.example.com/", "http://a.example.com/"},
+		{"http://example.com/x", "http://example.co.uk/"},
+		{"http://192.0.2.1/", "http://192.0.2.1/a", "http://192.0.2.1/b"},
+	} {
+		r := &result{rec: &rawRecord{in: &inputFile{}, seq: i, data: []byte("r"), offset: -1}}
+		r.add(&entry{}, "", urls)
+		w.add(r)
+	}
+
+	want := "http://a.example.com/\nhttp://b.example.com/\nhttp://example.co.uk/\nhttp://192.0.2.1/\nhttp://192.0.2.1/a\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
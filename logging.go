@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+)
+
+var (
+	logFormat = flag.String("log-format", "text", "log to standard error as text, or as json objects with the fields of each message")
+	logLevel  = flag.String("log-level", "info", "least severe messages to log: debug, info, warn or error")
+)
+
+// setupLogging sets the default slog logger from -log-format and
+// -log-level. What's still logged with the log package, the fatal
+// errors, is logged at the error level.
+func setupLogging() error {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		return err
+	}
+
+	switch *logFormat {
+	case "text":
+		slog.SetLogLoggerLevel(level)
+	case "json":
+		h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+		slog.SetDefault(slog.New(h))
+		log.SetFlags(0)
+		log.SetOutput(slog.NewLogLogger(h, slog.LevelError).Writer())
+	default:
+		return errors.New("expected text or json")
+	}
+
+	return nil
+}
+
+// recordAttrs are args, after the input file and offset of rec if known
+func recordAttrs(rec *rawRecord, args ...any) []any {
+	var attrs []any
+	if rec.in != nil {
+		attrs = append(attrs, "file", rec.in.path)
+	}
+
+	if rec.offset >= 0 {
+		attrs = append(attrs, "offset", rec.offset)
+	}
+
+	return append(attrs, args...)
+}
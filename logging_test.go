@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestSetupLogging(t *testing.T) {
+	defer func(l *slog.Logger, w io.Writer, flags int) {
+		*logFormat, *logLevel = "text", "info"
+		slog.SetDefault(l)
+		slog.SetLogLoggerLevel(slog.LevelInfo)
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	}(slog.Default(), log.Writer(), log.Flags())
+
+	for _, tt := range []struct {
+		format, level string
+		ok            bool
+	}{
+		{"text", "info", true},
+		{"text", "debug", true},
+		{"json", "warn", true},
+		{"json", "ERROR", true},
+		{"xml", "info", false},
+		{"text", "loud", false},
+	} {
+		*logFormat, *logLevel = tt.format, tt.level
+		if err := setupLogging(); (err == nil) != tt.ok {
+			t.Errorf("%v %v: got %v", tt.format, tt.level, err)
+		}
+	}
+}
+
+func TestRecordAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	rec := &rawRecord{in: &inputFile{path: "a.warc.gz"}, offset: 1234, length: -1}
+	logger.Error("processRecords", recordAttrs(rec, "url", "http://example.com/", "err", "malformed")...)
+	logger.Error("processRecords", recordAttrs(&rawRecord{offset: -1}, "err", "malformed")...)
+
+	var got []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var m map[string]interface{}
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		delete(m, "time")
+		got = append(got, m)
+	}
+
+	want := []map[string]interface{}{
+		{"level": "ERROR", "msg": "processRecords", "file": "a.warc.gz", "offset": 1234.0,
+			"url": "http://example.com/", "err": "malformed"},
+		{"level": "ERROR", "msg": "processRecords", "err": "malformed"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
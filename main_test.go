@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadParallel(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 7; i++ {
+		var recs string
+		for j := 0; j < 50; j++ {
+			recs += targetRecord(fmt.Sprintf("http://%v/%v", i, j))
+		}
+
+		path := filepath.Join(dir, fmt.Sprintf("%v.warc", i))
+		os.WriteFile(path, []byte(recs), 0644)
+		paths = append(paths, path)
+	}
+
+	paths = append(paths, filepath.Join(dir, "missing.warc"))
+	defer func(n int) { *nfiles = n }(*nfiles)
+	for _, n := range []int{1, 3, 10} {
+		*nfiles = n
+		var inputs []*inputFile
+		for i, path := range paths {
+			inputs = append(inputs, &inputFile{index: i, path: path})
+		}
+
+		var out bytes.Buffer
+		recs := make(chan *rawRecord)
+		results := make(chan *result)
+		done := make(chan struct{})
+		var nrecords, nfailed int
+		go readRecords(inputs, recs, &nrecords, &nfailed)
+		processRecords(recs, results, 4)
+		writeResults(newResultWriter(newEntryWriter("text", &out)), results, done)
+		<-done
+
+		urls := strings.Fields(out.String())
+		if nrecords != 350 || nfailed != 1 || len(urls) != 350 {
+			t.Fatalf("n=%v: %v records, %v failed, %v URLs", n, nrecords, nfailed, len(urls))
+		}
+
+		// each input's URLs are in input order
+		next := make(map[string]int)
+		for _, u := range urls {
+			var i, j int
+			fmt.Sscanf(u, "http://%d/%d", &i, &j)
+			key := fmt.Sprint(i)
+			if next[key] != j {
+				t.Fatalf("n=%v: got %v, want http://%v/%v", n, u, i, next[key])
+			}
+
+			next[key]++
+		}
+	}
+}
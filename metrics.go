@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var metricsAddr = flag.String("metrics-addr", "", "serve Prometheus metrics on this address, e.g. :9090, at /metrics")
+
+// the metrics of the run, updated whether they're served or not
+var metrics = newRunMetrics()
+
+// latency buckets of the stages, in seconds
+var stageBuckets = []float64{0.00001, 0.0001, 0.001, 0.01, 0.1, 1, 10}
+
+type latencyHistogram struct {
+	counts []atomic.Uint64 // by bucket, and of those over the last one
+	nanos  atomic.Uint64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(stageBuckets, d.Seconds())
+	h.counts[i].Add(1)
+	h.nanos.Add(uint64(d))
+}
+
+type runMetrics struct {
+	recordsRead atomic.Int64
+	parseErrors atomic.Int64
+	urlsEmitted atomic.Int64
+	pending     atomic.Int64 // results waiting for those before them
+
+	stages map[string]*latencyHistogram // read, parse and write
+
+	mu     sync.Mutex
+	queues map[string]func() int // depths of the channels between the stages
+}
+
+func newRunMetrics() *runMetrics {
+	m := &runMetrics{
+		stages: make(map[string]*latencyHistogram),
+		queues: make(map[string]func() int),
+	}
+
+	for _, stage := range []string{"read", "parse", "write"} {
+		m.stages[stage] = &latencyHistogram{counts: make([]atomic.Uint64, len(stageBuckets)+1)}
+	}
+
+	return m
+}
+
+func (m *runMetrics) observe(stage string, started time.Time) {
+	m.stages[stage].observe(time.Since(started))
+}
+
+func (m *runMetrics) addQueue(name string, depth func() int) {
+	m.mu.Lock()
+	m.queues[name] = depth
+	m.mu.Unlock()
+}
+
+func writeMetric(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, typ)
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'g', -1, 64)
+}
+
+// write writes m in the Prometheus text format
+func (m *runMetrics) write(w io.Writer) {
+	for _, c := range []struct {
+		name, help string
+		v          *atomic.Int64
+	}{
+		{"warc_urls_records_read_total", "Records read from the inputs.", &m.recordsRead},
+		{"warc_urls_parse_errors_total", "Records that couldn't be parsed, or their URLs extracted.", &m.parseErrors},
+		{"warc_urls_urls_emitted_total", "URLs written to the output.", &m.urlsEmitted},
+	} {
+		writeMetric(w, c.name, "counter", c.help)
+		fmt.Fprintf(w, "%v %v\n", c.name, c.v.Load())
+	}
+
+	var stages []string
+	for stage := range m.stages {
+		stages = append(stages, stage)
+	}
+
+	sort.Strings(stages)
+	name := "warc_urls_stage_duration_seconds"
+	writeMetric(w, name, "histogram", "Time taken by a stage for a record.")
+	for _, stage := range stages {
+		h := m.stages[stage]
+		var n uint64
+		for i := range h.counts {
+			le := "+Inf"
+			if i < len(stageBuckets) {
+				le = formatSeconds(stageBuckets[i])
+			}
+
+			n += h.counts[i].Load()
+			fmt.Fprintf(w, "%v_bucket{stage=%q,le=%q} %v\n", name, stage, le, n)
+		}
+
+		secs := time.Duration(h.nanos.Load()).Seconds()
+		fmt.Fprintf(w, "%v_sum{stage=%q} %v\n", name, stage, formatSeconds(secs))
+		fmt.Fprintf(w, "%v_count{stage=%q} %v\n", name, stage, n)
+	}
+
+	depths := m.queueDepths()
+	var queues []string
+	for q := range depths {
+		queues = append(queues, q)
+	}
+
+	sort.Strings(queues)
+	name = "warc_urls_queue_depth"
+	writeMetric(w, name, "gauge", "Records or results waiting for the next stage.")
+	for _, q := range queues {
+		fmt.Fprintf(w, "%v{queue=%q} %v\n", name, q, depths[q])
+	}
+}
+
+// queueDepths are the depths of the queues, including the pending results
+func (m *runMetrics) queueDepths() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	depths := map[string]int{"pending": int(m.pending.Load())}
+	for q, depth := range m.queues {
+		depths[q] = depth()
+	}
+
+	return depths
+}
+
+// serveMetrics listens on addr, and serves the metrics at /metrics
+func serveMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		metrics.write(w)
+	})
+
+	go http.Serve(l, mux)
+	return nil
+}
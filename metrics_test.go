@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	m := newRunMetrics()
+	m.recordsRead.Add(3)
+	m.parseErrors.Add(1)
+	m.urlsEmitted.Add(7)
+	m.pending.Add(2)
+	m.stages["parse"].observe(50 * time.Microsecond)
+	m.stages["parse"].observe(2 * time.Millisecond)
+	m.stages["parse"].observe(time.Minute)
+	m.addQueue("records", func() int { return 4 })
+
+	var buf bytes.Buffer
+	m.write(&buf)
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE warc_urls_records_read_total counter\nwarc_urls_records_read_total 3\n",
+		"warc_urls_parse_errors_total 1\n",
+		"warc_urls_urls_emitted_total 7\n",
+		"# TYPE warc_urls_stage_duration_seconds histogram\n",
+		`warc_urls_stage_duration_seconds_bucket{stage="parse",le="1e-05"} 0` + "\n",
+		`warc_urls_stage_duration_seconds_bucket{stage="parse",le="0.0001"} 1` + "\n",
+		`warc_urls_stage_duration_seconds_bucket{stage="parse",le="0.01"} 2` + "\n",
+		`warc_urls_stage_duration_seconds_bucket{stage="parse",le="10"} 2` + "\n",
+		`warc_urls_stage_duration_seconds_bucket{stage="parse",le="+Inf"} 3` + "\n",
+		`warc_urls_stage_duration_seconds_sum{stage="parse"} 60.00205` + "\n",
+		`warc_urls_stage_duration_seconds_count{stage="parse"} 3` + "\n",
+		`warc_urls_stage_duration_seconds_count{stage="read"} 0` + "\n",
+		`warc_urls_queue_depth{queue="records"} 4` + "\n",
+		`warc_urls_queue_depth{queue="pending"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%v", want, out)
+		}
+	}
+}
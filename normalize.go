@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var normalize = flag.Bool("normalize", false, "normalize the URLs before they're filtered, deduplicated and output: lowercase their scheme and host, remove default ports and dot segments, and normalize percent-encoding")
+
+// urlRewrite rewrites the URL of an entry, before it's filtered,
+// deduplicated and output
+type urlRewrite func(s string) string
+
+// the rewrites of the URLs, from their flags, in order
+var urlRewrites []urlRewrite
+
+func setupRewrites() error {
+	urlRewrites = nil
+	if *normalize {
+		urlRewrites = append(urlRewrites, normalizeURL)
+	}
+
+	patterns := splitList(*stripParams)
+	if *stripTracking {
+		patterns = append(patterns, trackingParams...)
+	}
+
+	if len(patterns) > 0 {
+		r, err := paramStripper(patterns)
+		if err != nil {
+			return err
+		}
+
+		urlRewrites = append(urlRewrites, r)
+	}
+
+	if *stripFragment {
+		urlRewrites = append(urlRewrites, removeFragment)
+	}
+
+	if *sortQuery {
+		urlRewrites = append(urlRewrites, sortParams)
+	}
+
+	switch *idnForm {
+	case "":
+	case "unicode", "ascii":
+		toUnicode := *idnForm == "unicode"
+		urlRewrites = append(urlRewrites, func(s string) string {
+			return idnURL(s, toUnicode)
+		})
+	default:
+		return fmt.Errorf("invalid -idn setting %q, expected unicode or ascii", *idnForm)
+	}
+
+	return nil
+}
+
+func rewriteURL(s string) string {
+	for _, r := range urlRewrites {
+		s = r(s)
+	}
+
+	return s
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ftp": "21", "ws": "80", "wss": "443"}
+
+// normalizeURL returns s normalized as by RFC 3986, or as it is if it
+// can't be parsed or has no host
+func normalizeURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || len(u.Host) == 0 {
+		return s
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); len(port) > 0 && defaultPorts[scheme] == port || strings.HasSuffix(host, ":") {
+		host = host[:strings.LastIndexByte(host, ':')]
+	}
+
+	p := removeDotSegments(normalizePercent(u.EscapedPath()))
+	if len(p) == 0 && (scheme == "http" || scheme == "https") {
+		p = "/"
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme + "://")
+	if u.User != nil {
+		b.WriteString(u.User.String() + "@")
+	}
+
+	b.WriteString(host + p)
+	if len(u.RawQuery) > 0 || u.ForceQuery {
+		b.WriteString("?" + normalizePercent(u.RawQuery))
+	}
+
+	if len(u.Fragment) > 0 {
+		b.WriteString("#" + normalizePercent(u.EscapedFragment()))
+	}
+
+	return b.String()
+}
+
+// normalizePercent decodes the percent-encoded unreserved characters of
+// s, and uppercases the hex digits of the other escapes
+func normalizePercent(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(s[i+1:i+3]))
+		}
+
+		i += 2
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+
+	return c - 'A' + 10
+}
+
+// removeDotSegments resolves the . and .. segments of a path, as by RFC
+// 3986 section 5.2.4
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+
+	var out []string
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		last := i == len(segs)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || len(out) == 1 && len(out[0]) > 0 {
+				out = out[:len(out)-1]
+			}
+
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+
+	return strings.Join(out, "/")
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"HTTP://Example.COM:80/a/./b/../c":        "http://example.com/a/c",
+		"https://example.com:443":                 "https://example.com/",
+		"https://example.com:8443/":               "https://example.com:8443/",
+		"http://example.com:/x":                   "http://example.com/x",
+		"http://example.com/%7euser/%2fa%3F":      "http://example.com/~user/%2Fa%3F",
+		"http://example.com/%41%62/?q=%7e%2b#%2D": "http://example.com/Ab/?q=~%2B#-",
+		"http://example.com/../../a/..":           "http://example.com/",
+		"http://example.com/a/.":                  "http://example.com/a/",
+		"http://u:p@Example.com/?":                "http://u:p@example.com/?",
+		"http://[::1]:80/a":                       "http://[::1]/a",
+		"dns:Example.com":                         "dns:Example.com",
+		"http://a/%zz":                            "http://a/%zz",
+	} {
+		if got := normalizeURL(in); got != want {
+			t.Errorf("%v: got %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestRemoveDotSegments(t *testing.T) {
+	for in, want := range map[string]string{
+		"/a/b/c/./../../g":   "/a/g",
+		"mid/content=5/../6": "mid/6",
+		"/./":                "/",
+		"/..":                "/",
+		"/a//b/../c":         "/a//c",
+		"/a.b/c.":            "/a.b/c.",
+	} {
+		if got := removeDotSegments(in); got != want {
+			t.Errorf("%v: got %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeEntries(t *testing.T) {
+	defer func() { *normalize = false; setupRewrites() }()
+	*normalize = true
+	setupRewrites()
+	res := &result{}
+	res.add(&entry{}, "", []string{"HTTP://A:80/b/../c", "http://a/c"})
+	if got := entryURLs(res.entries); len(got) != 2 || got[0] != "http://a/c" || got[1] != "http://a/c" {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"time"
+)
+
+var notifyURL = flag.String("notify-url", "", "POST a JSON summary of the run to this URL when it finishes or fails")
+
+// runNotice is the -notify-url summary, kept updated by main
+type runNotice struct {
+	Status          string  `json:"status"` // finished or failed
+	Error           string  `json:"error,omitempty"`
+	Files           int     `json:"files"`
+	FilesFailed     int     `json:"files_failed"`
+	Records         int64   `json:"records"`
+	ParseErrors     int64   `json:"parse_errors"`
+	URLs            int64   `json:"urls"`
+	Started         string  `json:"started"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+var (
+	notice     runNotice
+	runStarted = time.Now()
+)
+
+// notify sends the notice with status, and the error msg if failed
+func notify(status, msg string) error {
+	notice.Status, notice.Error = status, msg
+	notice.Records = metrics.recordsRead.Load()
+	notice.ParseErrors = metrics.parseErrors.Load()
+	notice.URLs = metrics.urlsEmitted.Load()
+	notice.Started = runStarted.UTC().Format(time.RFC3339)
+	notice.DurationSeconds = time.Since(runStarted).Seconds()
+	data, err := json.Marshal(&notice)
+	if err != nil {
+		return err
+	}
+
+	resp, err := getHTTPClient().Post(*notifyURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return &httpStatusError{status: resp.Status, code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// fatal logs v and exits like log.Fatal, after notifying -notify-url of
+// the failure and removing the temporary files of uncommitted outputs
+func fatal(v ...interface{}) {
+	removeTempFiles()
+	if len(*notifyURL) > 0 {
+		if err := notify("failed", fmt.Sprint(v...)); err != nil {
+			slog.Warn("notify", "url", *notifyURL, "err", err)
+		}
+	}
+
+	log.Fatal(v...)
+}
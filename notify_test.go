@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	var got runNotice
+	var contentType string
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		got = runNotice{}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+
+		w.WriteHeader(status)
+	}))
+
+	defer srv.Close()
+	defer func(u string, n runNotice) { *notifyURL, notice = u, n }(*notifyURL, notice)
+	*notifyURL = srv.URL
+	notice = runNotice{Files: 3, FilesFailed: 1}
+	if err := notify("failed", "writing output: disk full"); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("got Content-Type %q", contentType)
+	} else if got.Status != "failed" || got.Error != "writing output: disk full" ||
+		got.Files != 3 || got.FilesFailed != 1 || len(got.Started) == 0 {
+		t.Errorf("got %+v", got)
+	}
+
+	status = http.StatusBadGateway
+	if err := notify("finished", ""); err == nil {
+		t.Error("expected an error for a 502")
+	} else if got.Status != "finished" || len(got.Error) > 0 {
+		t.Errorf("got %+v", got)
+	}
+}
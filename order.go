@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var inputOrder = flag.String("input-order", "", "order inputs by name, name-desc, mtime or mtime-desc (newest first) instead of as given")
+
+var inputOrders = []string{"", "name", "name-desc", "mtime", "mtime-desc"}
+
+// orderInputs sorts paths by name or modification time. Sorting is
+// stable, and inputs whose time can't be found sort as the oldest.
+func orderInputs(paths []string, order string) []string {
+	if len(order) == 0 {
+		return paths
+	}
+
+	sorted := append([]string(nil), paths...)
+	desc := strings.HasSuffix(order, "-desc")
+	if strings.HasPrefix(order, "name") {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if desc {
+				return sorted[i] > sorted[j]
+			}
+
+			return sorted[i] < sorted[j]
+		})
+
+		return sorted
+	}
+
+	mtimes := statModTimes(sorted)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := mtimes[sorted[i]], mtimes[sorted[j]]
+		if desc {
+			return ti.After(tj)
+		}
+
+		return ti.Before(tj)
+	})
+
+	return sorted
+}
+
+// statModTimes looks up the modification times of inputs, a few at a
+// time as remote inputs take a request each
+func statModTimes(paths []string) map[string]time.Time {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	mtimes := make(map[string]time.Time)
+	queue := make(chan string)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				t, err := modTime(path)
+				if err != nil {
+					slog.Error("orderInputs", "file", path, "err", err)
+				}
+
+				mu.Lock()
+				mtimes[path] = t
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		queue <- path
+	}
+
+	close(queue)
+	wg.Wait()
+	return mtimes
+}
+
+func modTime(name string) (time.Time, error) {
+	switch {
+	case name == stdinInput:
+		return time.Time{}, nil
+	case isHTTP(name):
+		return httpModTime(name, nil)
+	case isS3(name):
+		return s3ModTime(name)
+	case isGCS(name):
+		bucket, object, err := parseGCS(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return httpModTime(gcsObjectURL(bucket, object), authorizeGCS)
+	case isIA(name):
+		id, file, err := parseIA(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return httpModTime(iaDownloadURL(id, file), nil)
+	case isHDFS(name):
+		return hdfsModTime(name)
+	case isSFTP(name):
+		username, addr, p, err := parseSFTP(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		c, err := sftpClient(username, addr)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		fi, err := c.Stat(p)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return fi.ModTime(), nil
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
+
+// httpModTime returns the Last-Modified time of a URL from a HEAD request
+func httpModTime(url string, prepare func(*http.Request) error) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *httpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if prepare != nil {
+		if err := prepare(req); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	resp, err := getHTTPClient().Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, &httpStatusError{resp.Status, resp.StatusCode}
+	}
+
+	return http.ParseTime(resp.Header.Get("Last-Modified"))
+}
+
+func hdfsModTime(name string) (time.Time, error) {
+	host, path, err := parseHDFS(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	r, err := openHTTP(webhdfsURL(host, path, "GETFILESTATUS", nil), 0, -1)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	defer r.Close()
+	var res struct {
+		FileStatus struct {
+			ModificationTime int64 `json:"modificationTime"`
+		}
+	}
+
+	if err := json.NewDecoder(r).Decode(&res); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.UnixMilli(res.FileStatus.ModificationTime), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderInputs(t *testing.T) {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /<hours>.warc.gz was modified <hours> after base
+		var hours time.Duration
+		for _, c := range strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".warc.gz") {
+			hours = hours*10 + time.Duration(c-'0')
+		}
+
+		http.ServeContent(w, r, "", base.Add(hours*time.Hour), strings.NewReader("x"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	local := func(name string, hours int) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, nil, 0644)
+		mtime := base.Add(time.Duration(hours) * time.Hour)
+		os.Chtimes(path, mtime, mtime)
+		return path
+	}
+
+	a, b, c := local("a.warc.gz", 5), local("b.warc.gz", 1), local("c.warc.gz", 3)
+	remote := srv.URL + "/4.warc.gz"
+	missing := filepath.Join(dir, "missing.warc.gz")
+	paths := []string{b, remote, missing, c, a}
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"", paths},
+		{"name", []string{a, b, c, missing, remote}},
+		{"name-desc", []string{remote, missing, c, b, a}},
+		{"mtime", []string{missing, b, c, remote, a}},
+		{"mtime-desc", []string{a, remote, c, b, missing}},
+	}
+
+	for _, tt := range tests {
+		if got := orderInputs(paths, tt.order); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.order, got, tt.want)
+		}
+	}
+
+	if !reflect.DeepEqual(paths, []string{b, remote, missing, c, a}) {
+		t.Error("paths modified")
+	}
+}
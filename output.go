@@ -0,0 +1,977 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sebcat/warc"
+)
+
+var (
+	outputSink = flag.String("out", "", "publish the URLs to kafka://broker[,broker...]/topic, add them to a set or stream at redis://host[:port][/db]?key=..., or index them at es+http(s)://host[:port]/index, instead of writing them out")
+	outputFile = flag.String("o", "", "write the output to this file, which is only created once the run completes (default standard output)")
+	compress   = flag.String("compress", "", "compress the output with gzip or zstd")
+	rotateSize = flag.String("rotate-size", "", "start a new -o part once this many bytes are written, e.g. 1G")
+	rotateN    = flag.String("rotate-count", "", "start a new -o part after this many URLs, e.g. 10M")
+	print0     = flag.Bool("print0", false, "end URLs with a NUL byte instead of a newline in text output, as for xargs -0")
+	fieldsFlag = flag.String("fields", "url", "comma separated columns of -format csv and tsv: "+strings.Join(fieldNames, ", "))
+	recOffsets = flag.Bool("offsets", false, "follow the URLs of text output with the file, compressed offset and compressed length of their records, tab separated, and add them to jsonl output")
+	tmplFlag   = flag.String("template", "", "Go text/template of the lines of text output, e.g. '{{.URL}}\\t{{.Date}}', with the fields of -format jsonl: URL, From, Type, Date, RecordID, Digest, Length, MIME, Status, Revisit, Truncated, RobotsDisallowed, Soft404, and with -offsets File, Offset and CompressedLength")
+)
+
+var outputFormats = []string{"text", "jsonl", "csv", "tsv", "parquet", "avro", "cdxj", "cdx", "sitemap"}
+
+var compressions = []string{"", "gzip", "zstd"}
+
+// set from -fields, -template, -rotate-size and -rotate-count
+var (
+	outputFields   = []string{"url"}
+	outputTemplate *template.Template
+	maxPartSize    int64
+	maxPartCount   int64
+)
+
+// entry is a URL to output, along with the record it was found in. For
+// URLs found in the content of a record (-wat-outlinks, -wet-urls), From
+// is the record's Target-URI.
+type entry struct {
+	URL      string `json:"url"`
+	From     string `json:"from,omitempty"`
+	Type     string `json:"warc_type,omitempty"`
+	Date     string `json:"warc_date,omitempty"`
+	RecordID string `json:"record_id,omitempty"`
+	Digest   string `json:"digest,omitempty"`
+	Length   int64  `json:"length"`
+	MIME     string `json:"mime,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Revisit  bool   `json:"revisit,omitempty"`
+
+	// the WARC-Truncated reason, e.g. length, for -truncated
+	Truncated        string `json:"truncated,omitempty"`
+	RobotsDisallowed bool   `json:"robots_disallowed,omitempty"` // by -robots flag
+	Soft404          bool   `json:"soft_404,omitempty"`          // by -soft-404
+
+	IP      string `json:"-"` // WARC-IP-Address, for -ip-cidr
+	Header  string `json:"-"` // header lines of HTTP responses, for -resp-header
+	Charset string `json:"-"` // of HTTP responses, for -charset
+	Lang    string `json:"-"` // of HTML responses, for -lang
+
+	// where the record is, for cdx and cdxj
+	File             string `json:"-"`
+	Offset           int64  `json:"-"`
+	CompressedLength int64  `json:"-"`
+}
+
+// recordEntry returns the entry of r, without its URL. block is the
+// record block, whose HTTP headers give the status and MIME type of
+// responses.
+func recordEntry(r *warc.Record, block []byte) *entry {
+	e := &entry{
+		Type:     strings.TrimSpace(r.Fields.Value("WARC-Type")),
+		Date:     strings.TrimSpace(r.Fields.Value("WARC-Date")),
+		RecordID: strings.TrimSpace(r.Fields.Value("WARC-Record-ID")),
+		Digest:   strings.TrimSpace(r.Fields.Value("WARC-Payload-Digest")),
+		MIME:     mediaType(r.Fields.Value("Content-Type")),
+		IP:       strings.TrimSpace(r.Fields.Value("WARC-IP-Address")),
+
+		Truncated: strings.ToLower(strings.TrimSpace(r.Fields.Value("WARC-Truncated"))),
+	}
+
+	if len(e.Digest) == 0 {
+		e.Digest = strings.TrimSpace(r.Fields.Value("WARC-Block-Digest"))
+	}
+
+	e.Length, _ = strconv.ParseInt(strings.TrimSpace(r.Fields.Value("Content-Length")), 10, 64)
+	e.Revisit = e.Type == "revisit"
+	if e.MIME == "application/http" {
+		e.Status, e.MIME = httpResponse(block)
+		if len(respHeaders) > 0 {
+			e.Header = httpHeader(block)
+		}
+
+		if len(*charsets) > 0 && e.Status > 0 {
+			e.Charset = responseCharset(block, e.MIME)
+		}
+
+		if len(*langs) > 0 && e.Status > 0 && (e.MIME == "text/html" || e.MIME == "application/xhtml+xml") {
+			e.Lang = detectLang(block)
+		}
+
+		if len(*soft404) > 0 && e.Status == 200 {
+			e.Soft404 = isSoft404(block, e.MIME)
+		}
+	}
+
+	return e
+}
+
+// mediaType returns the lowercased media type of a Content-Type value,
+// without parameters
+func mediaType(s string) string {
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// httpResponse returns the status code and media type of the HTTP
+// response starting block, or zero values if it isn't one
+func httpResponse(block []byte) (int, string) {
+	var status int
+	var mime string
+	for i := 0; len(block) > 0; i++ {
+		var line []byte
+		if j := bytes.IndexByte(block, '\n'); j >= 0 {
+			line, block = block[:j], block[j+1:]
+		} else {
+			line, block = block, nil
+		}
+
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			break
+		} else if i == 0 {
+			fields := strings.Fields(string(line))
+			if len(fields) < 2 || !strings.HasPrefix(fields[0], "HTTP/") {
+				return 0, ""
+			}
+
+			status, _ = strconv.Atoi(fields[1])
+		} else if k := bytes.IndexByte(line, ':'); k > 0 &&
+			strings.EqualFold(string(bytes.TrimSpace(line[:k])), "Content-Type") {
+			mime = mediaType(string(line[k+1:]))
+		}
+	}
+
+	return status, mime
+}
+
+// httpHeader returns the header lines of the HTTP response starting
+// block, or an empty string if it isn't one
+func httpHeader(block []byte) string {
+	if !bytes.HasPrefix(block, []byte("HTTP/")) {
+		return ""
+	}
+
+	_, header, _ := bytes.Cut(block, []byte("\n"))
+	if i := bytes.Index(header, []byte("\n\r\n")); i >= 0 {
+		header = header[:i+1]
+	} else if i := bytes.Index(header, []byte("\n\n")); i >= 0 {
+		header = header[:i+1]
+	}
+
+	return string(header)
+}
+
+// httpBody returns the status of the HTTP response of block, and up to
+// max bytes of its body, dechunked and decompressed, of which a truncated
+// one is what there is
+func httpBody(block []byte, max int64) (int, []byte, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer resp.Body.Close()
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if body, err = gzip.NewReader(resp.Body); err != nil {
+			return resp.StatusCode, nil, err
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, max))
+	if err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+
+	return resp.StatusCode, data, err
+}
+
+// headerValues returns the values of the name fields of header lines
+func headerValues(header, name string) []string {
+	var values []string
+	for _, line := range strings.Split(header, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			values = append(values, strings.TrimSpace(v))
+		}
+	}
+
+	return values
+}
+
+// host returns the lowercased host name of the URL of e
+func (e *entry) host() string {
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// entryFields are the columns of csv and tsv output
+var entryFields = map[string]func(e *entry) string{
+	"url":       func(e *entry) string { return outputURL(e.URL) },
+	"from":      func(e *entry) string { return e.From },
+	"type":      func(e *entry) string { return e.Type },
+	"date":      func(e *entry) string { return e.Date },
+	"record_id": func(e *entry) string { return e.RecordID },
+	"digest":    func(e *entry) string { return e.Digest },
+	"length":    func(e *entry) string { return strconv.FormatInt(e.Length, 10) },
+	"mime":      func(e *entry) string { return e.MIME },
+	"status":    func(e *entry) string { return strconv.Itoa(e.Status) },
+	"host":      func(e *entry) string { return e.host() },
+
+	"file":              func(e *entry) string { return e.File },
+	"offset":            func(e *entry) string { return offsetField(e.Offset) },
+	"compressed_length": func(e *entry) string { return offsetField(e.CompressedLength) },
+}
+
+// offsetFields need records to be read with their offsets and lengths
+var offsetFields = []string{"file", "offset", "compressed_length"}
+
+// offsetField is an offset or length, empty if unknown
+func offsetField(n int64) string {
+	if n < 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(n, 10)
+}
+
+func orDash(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+
+	return s
+}
+
+var fieldNames = []string{"url", "from", "type", "date", "record_id", "digest", "length", "mime", "status", "host",
+	"file", "offset", "compressed_length"}
+
+// parseFields parses a -fields setting
+func parseFields(s string) ([]string, error) {
+	fields := splitList(s)
+	if len(fields) == 0 {
+		return nil, errors.New("no fields")
+	}
+
+	for _, f := range fields {
+		if _, ok := entryFields[f]; !ok {
+			return nil, fmt.Errorf("unknown field %q", f)
+		}
+	}
+
+	return fields, nil
+}
+
+// entryWriter writes entries in an output format. Close flushes what's
+// left, and must be called once all entries are written.
+type entryWriter interface {
+	Write(e *entry) error
+	Close() error
+}
+
+// parseFormat splits a -format setting into the input and output formats
+// it lists, e.g. "zstd,jsonl". Either defaults to the first of its kind.
+func parseFormat(s string) (string, string, error) {
+	in, out := inputFormats[0], outputFormats[0]
+	var nin, nout int
+	for _, f := range splitList(s) {
+		if hasString(inputFormats, f) {
+			in = f
+			nin++
+		} else if hasString(outputFormats, f) {
+			out = f
+			nout++
+		} else {
+			return "", "", fmt.Errorf("unknown format %q", f)
+		}
+	}
+
+	if nin > 1 || nout > 1 {
+		return "", "", fmt.Errorf("%q: more than one input or output format", s)
+	}
+
+	return in, out, nil
+}
+
+// newEntryWriter returns a writer of format to w. Entries are written as
+// they come, so that the output of -watch and of pipes isn't held back,
+// except for parquet which is written in row groups, and avro in blocks.
+func newEntryWriter(format string, w io.Writer) entryWriter {
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return &jsonlWriter{enc, *recOffsets}
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+
+		return &csvWriter{w: cw, fields: outputFields}
+	case "parquet":
+		return newParquetWriter(w)
+	case "avro":
+		return newAvroWriter(w)
+	case "cdxj":
+		return &cdxjWriter{w}
+	case "cdx":
+		return &cdxWriter{w: w}
+	case "sitemap":
+		return &sitemapPartWriter{w: w}
+	default:
+		tw := &textWriter{w: w, end: "\n", tmpl: outputTemplate, offsets: *recOffsets}
+		if *print0 {
+			tw.end = "\x00"
+		}
+
+		return tw
+	}
+}
+
+// openOutput returns a writer of outputFormat to w, compressed according
+// to -compress. Closing it finishes the compressed stream, but leaves w
+// open.
+func openOutput(w io.Writer) (entryWriter, error) {
+	var c io.WriteCloser
+	switch *compress {
+	case "gzip":
+		c = gzip.NewWriter(w)
+	case "zstd":
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+
+		c = zw
+	default:
+		return newEntryWriter(outputFormat, w), nil
+	}
+
+	raw := &countingWriter{w: c}
+	return &compressedWriter{newEntryWriter(outputFormat, raw), c, raw}, nil
+}
+
+type compressedWriter struct {
+	entryWriter
+	c   io.WriteCloser
+	raw *countingWriter // the bytes written before compression
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// headerWriter is a writer that starts its output with a header, which
+// skipHeader leaves out, for an output that's continued
+type headerWriter interface {
+	skipHeader()
+}
+
+func (w *compressedWriter) skipHeader() {
+	if h, ok := w.entryWriter.(headerWriter); ok {
+		h.skipHeader()
+	}
+}
+
+func (w *compressedWriter) Close() error {
+	err := w.entryWriter.Close()
+	if cerr := w.c.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// textWriter writes URLs, followed by the file, offset and length of
+// their records with offsets, or tmpl executed for their entries if set,
+// ending with end, a newline or a NUL byte
+type textWriter struct {
+	w       io.Writer
+	end     string
+	tmpl    *template.Template
+	offsets bool
+}
+
+func (w *textWriter) Write(e *entry) error {
+	if w.tmpl == nil && w.offsets {
+		_, err := fmt.Fprintf(w.w, "%v\t%v\t%v\t%v%v", outputURL(e.URL),
+			orDash(e.File), orDash(offsetField(e.Offset)), orDash(offsetField(e.CompressedLength)), w.end)
+		return err
+	} else if w.tmpl == nil {
+		_, err := io.WriteString(w.w, outputURL(e.URL)+w.end)
+		return err
+	}
+
+	te := *e
+	te.URL = outputURL(e.URL)
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, &te); err != nil {
+		return err
+	}
+
+	buf.WriteString(w.end)
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+// parseTemplate parses a -template, where \t, \n and \\ are a tab, a
+// newline and a backslash, as shells don't make them easy to type
+func parseTemplate(s string) (*template.Template, error) {
+	s = strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n").Replace(s)
+	return template.New("template").Parse(s)
+}
+
+func (w *textWriter) Close() error {
+	return nil
+}
+
+// marshalEntry returns the JSON object of e, as written by jsonlWriter
+func marshalEntry(e *entry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(e); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonlWriter writes one JSON object per line
+type jsonlWriter struct {
+	enc     *json.Encoder
+	offsets bool
+}
+
+// offsetEntry is an entry along with where its record is, if known
+type offsetEntry struct {
+	*entry
+	File             string `json:"file,omitempty"`
+	Offset           *int64 `json:"offset,omitempty"`
+	CompressedLength *int64 `json:"compressed_length,omitempty"`
+}
+
+func (w *jsonlWriter) Write(e *entry) error {
+	if *surtOutput {
+		se := *e
+		se.URL = surt(e.URL)
+		e = &se
+	}
+
+	if !w.offsets {
+		return w.enc.Encode(e)
+	}
+
+	oe := offsetEntry{entry: e, File: e.File}
+	if e.Offset >= 0 {
+		oe.Offset = &e.Offset
+	}
+
+	if e.CompressedLength >= 0 {
+		oe.CompressedLength = &e.CompressedLength
+	}
+
+	return w.enc.Encode(&oe)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+// csvWriter writes the outputFields of each entry as a row, after a
+// header row with their names
+type csvWriter struct {
+	w      *csv.Writer
+	fields []string
+	header bool // whether the header is written
+}
+
+func (w *csvWriter) Write(e *entry) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	row := make([]string, len(w.fields))
+	for i, f := range w.fields {
+		row[i] = entryFields[f](e)
+	}
+
+	return w.writeRow(row)
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+
+	w.header = true
+	return w.writeRow(w.fields)
+}
+
+func (w *csvWriter) writeRow(row []string) error {
+	w.w.Write(row)
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) skipHeader() {
+	w.header = true
+}
+
+// Close writes the header of an output without entries
+func (w *csvWriter) Close() error {
+	return w.writeHeader()
+}
+
+// parseQuantity parses a number with an optional k, M, G or T suffix,
+// each a factor of unit larger than the previous
+func parseQuantity(s string, unit int64) (int64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	m := int64(1)
+	if i := strings.IndexByte("kMGT", s[len(s)-1]); i >= 0 {
+		for ; i >= 0; i-- {
+			m *= unit
+		}
+
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err == nil && n < 0 {
+		err = errors.New("negative")
+	}
+
+	return n * m, err
+}
+
+type aborter interface {
+	Abort()
+}
+
+// createOutput returns the writer of the output: to standard output, to
+// the -o file, or to its parts if rotated or sharded, sorted first with
+// -sort or counted with -count-dups, or to the -sqlite or -pg-dsn
+// database or -out sink
+func createOutput() (entryWriter, error) {
+	var w entryWriter
+	var err error
+	if *countOnly {
+		return discardWriter{}, nil
+	} else if len(*sqliteFile) > 0 {
+		return newSQLiteWriter(*sqliteFile, *sqliteBatch)
+	} else if len(*pgDSN) > 0 {
+		return newPGWriter(*pgDSN, *pgTable, *pgBatch, *pgUpsert)
+	} else if isKafkaURL(*outputSink) {
+		return newKafkaWriter(*outputSink, outputFormat)
+	} else if isRedisURL(*outputSink) {
+		return newRedisWriter(*outputSink)
+	} else if isESURL(*outputSink) {
+		return newESWriter(*outputSink)
+	} else if outputFormat == "sitemap" {
+		w = newSitemapWriter(*outputFile, *sitemapURL)
+	} else if len(*shardBy) > 0 {
+		w = newShardWriter(*outputFile, *shardFiles)
+	} else if maxPartSize > 0 || maxPartCount > 0 {
+		w = &rotatingWriter{path: *outputFile, maxSize: maxPartSize, maxCount: maxPartCount}
+	} else if len(*outputFile) > 0 {
+		w, err = createFile(*outputFile)
+	} else {
+		w, err = openOutput(os.Stdout)
+	}
+
+	if err == nil && *sortOutput {
+		key := func(e *entry) string { return outputURL(e.URL) }
+		if isCDXFormat(outputFormat) {
+			key = cdxKey
+		}
+
+		w = newSortWriter(w, sortMemSize, key)
+	}
+
+	if err == nil && *countDups {
+		w = newDupWriter(w)
+	}
+
+	return w, err
+}
+
+// fileWriter writes to a committer, which is committed on Close
+type fileWriter struct {
+	entryWriter
+	f *countingFile
+}
+
+// committer is an -o file, an atomicFile or an s3Upload, which only
+// appears once committed
+type committer interface {
+	io.Writer
+	Commit() error
+	Abort()
+}
+
+func createOutputFile(path string) (committer, error) {
+	if isS3(path) {
+		return createS3Upload(path)
+	}
+
+	return createAtomic(path)
+}
+
+func createFile(path string) (*fileWriter, error) {
+	af, err := createOutputFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &countingFile{af: af}
+	w, err := openOutput(f)
+	if err != nil {
+		af.Abort()
+		return nil, err
+	}
+
+	return &fileWriter{w, f}, nil
+}
+
+func (w *fileWriter) Close() error {
+	if err := w.entryWriter.Close(); err != nil {
+		w.f.af.Abort()
+		return err
+	}
+
+	return w.f.af.Commit()
+}
+
+func (w *fileWriter) Abort() {
+	w.f.af.Abort()
+}
+
+// size returns the bytes written to the file, or those written before
+// compressing them if uncompressed
+func (w *fileWriter) size(uncompressed bool) int64 {
+	if cw, ok := w.entryWriter.(*compressedWriter); ok && uncompressed {
+		return cw.raw.n
+	}
+
+	return w.f.n
+}
+
+// countingFile counts the bytes written to a committer
+type countingFile struct {
+	af committer
+	n  int64
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	n, err := f.af.Write(p)
+	f.n += int64(n)
+	return n, err
+}
+
+// rotatingWriter writes parts of at most maxCount entries, or of about
+// maxSize bytes, numbered from 1 before the extension of path, e.g.
+// urls-0001.txt.gz
+type rotatingWriter struct {
+	path         string
+	maxSize      int64
+	maxCount     int64
+	uncompressed bool // maxSize is of the bytes before compression
+	part         int
+	n            int64 // entries in the current part
+	w            *fileWriter
+	resumed      bool // parts after part may be left by an interrupted run
+}
+
+func (w *rotatingWriter) Write(e *entry) error {
+	if w.w != nil && (w.maxCount > 0 && w.n >= w.maxCount ||
+		w.maxSize > 0 && w.w.size(w.uncompressed) >= w.maxSize) {
+		err := w.w.Close()
+		w.w = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.w == nil {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+
+	w.n++
+	return w.w.Write(e)
+}
+
+func (w *rotatingWriter) next() error {
+	w.part++
+	w.n = 0
+	fw, err := createFile(partName(w.path, w.part))
+	if err != nil {
+		return err
+	}
+
+	w.w = fw
+	return nil
+}
+
+// Flush commits the current part, so that a checkpoint isn't ahead of
+// the parts written. The next entry starts a new part.
+func (w *rotatingWriter) Flush() error {
+	if w.w == nil {
+		return nil
+	}
+
+	err := w.w.Close()
+	w.w = nil
+	return err
+}
+
+// resume continues the parts of an interrupted run after part, the last
+// one its checkpoint was saved with
+func (w *rotatingWriter) resume(part int) {
+	w.part, w.resumed = part, true
+}
+
+// Close commits the last part, or writes an empty first part if there
+// were no entries. When resumed, the parts left after it by the
+// interrupted run are removed, since their URLs were written again.
+func (w *rotatingWriter) Close() error {
+	if w.w == nil && w.part == 0 {
+		if err := w.next(); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if w.w != nil {
+		err = w.w.Close()
+		w.w = nil
+	}
+
+	if err == nil && w.resumed && !isS3(w.path) {
+		for n := w.part + 1; ; n++ {
+			if rerr := os.Remove(partName(w.path, n)); rerr != nil {
+				if !errors.Is(rerr, os.ErrNotExist) {
+					err = rerr
+				}
+
+				break
+			}
+		}
+	}
+
+	return err
+}
+
+func (w *rotatingWriter) Abort() {
+	if w.w != nil {
+		w.w.Abort()
+		w.w = nil
+	}
+}
+
+// partName returns the name of part n of path
+func partName(path string, n int) string {
+	return suffixName(path, fmt.Sprintf("%04d", n))
+}
+
+// suffixName inserts -suffix before the first extension of the base name
+// of path
+func suffixName(path, suffix string) string {
+	dir, base := filepath.Split(path)
+	ext := ""
+	if i := strings.IndexByte(base, '.'); i > 0 {
+		base, ext = base[:i], base[i:]
+	}
+
+	return dir + base + "-" + suffix + ext
+}
+
+// atomicFile is written to a temporary file next to path, which replaces
+// path when committed, so that path is never left partially written.
+// Writes are buffered, since nothing can be read before the commit.
+type atomicFile struct {
+	f    *os.File // nil while suspended
+	w    *bufio.Writer
+	tmp  string
+	path string
+}
+
+// tempFiles are the temporary files of the atomicFiles not yet committed
+// or aborted, removed by removeTempFiles on exit
+var tempFiles = struct {
+	sync.Mutex
+	names map[string]struct{}
+}{names: make(map[string]struct{})}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+
+	tempFiles.Lock()
+	tempFiles.names[f.Name()] = struct{}{}
+	tempFiles.Unlock()
+	return &atomicFile{f, bufio.NewWriterSize(f, 1<<20), f.Name(), path}, nil
+}
+
+// removeTempFiles removes the temporary files of all atomicFiles, when
+// exiting without committing them
+func removeTempFiles() {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	for name := range tempFiles.names {
+		os.Remove(name)
+		delete(tempFiles.names, name)
+	}
+}
+
+// removeTempFilesOnSignal exits on SIGINT or SIGTERM after removing the
+// temporary files of all atomicFiles
+func removeTempFilesOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-c
+		slog.Error("exiting", "signal", sig.String())
+		removeTempFiles()
+		os.Exit(1)
+	}()
+}
+
+func (f *atomicFile) Write(p []byte) (int, error) {
+	return f.w.Write(p)
+}
+
+// suspend closes the temporary file until resume reopens it, to limit
+// the number of files open
+func (f *atomicFile) suspend() error {
+	err := f.w.Flush()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+
+	f.f = nil
+	return err
+}
+
+func (f *atomicFile) resume() error {
+	fd, err := os.OpenFile(f.tmp, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	f.f = fd
+	f.w.Reset(fd)
+	return nil
+}
+
+// Commit syncs and closes the file and renames it to its path, or removes
+// it if that fails. The directory is synced too, so that the rename
+// survives a crash.
+func (f *atomicFile) Commit() error {
+	var err error
+	if f.f == nil {
+		err = f.resume()
+	}
+
+	if err == nil {
+		if err = f.w.Flush(); err == nil {
+			err = f.f.Sync()
+		}
+
+		if cerr := f.f.Close(); err == nil {
+			err = cerr
+		}
+
+		f.f = nil
+	}
+
+	if err == nil {
+		err = os.Rename(f.tmp, f.path)
+	}
+
+	if err == nil {
+		err = syncDir(filepath.Dir(f.path))
+	} else {
+		os.Remove(f.tmp)
+	}
+
+	f.forget()
+	return err
+}
+
+// Abort closes and removes the file
+func (f *atomicFile) Abort() {
+	if f.f != nil {
+		f.f.Close()
+		f.f = nil
+	}
+
+	os.Remove(f.tmp)
+	f.forget()
+}
+
+func (f *atomicFile) forget() {
+	tempFiles.Lock()
+	delete(tempFiles.names, f.tmp)
+	tempFiles.Unlock()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func entryURLs(entries []*entry) []string {
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, e.URL)
+	}
+
+	return urls
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		in, out string
+		ok      bool
+	}{
+		{"auto", "auto", "text", true},
+		{"jsonl", "auto", "jsonl", true},
+		{"zstd,jsonl", "zstd", "jsonl", true},
+		{"jsonl, plain", "plain", "jsonl", true},
+		{"gzip,zstd", "", "", false},
+		{"jsonl,text", "", "", false},
+		{"yaml", "", "", false},
+	}
+
+	for _, test := range tests {
+		in, out, err := parseFormat(test.format)
+		if ok := err == nil; ok != test.ok || in != test.in || out != test.out {
+			t.Errorf("%q: got %q %q %v", test.format, in, out, err)
+		}
+	}
+}
+
+func TestJSONLOutput(t *testing.T) {
+	fields := "WARC-Type: response\r\n" +
+		"WARC-Target-URI: http://example.com/?a=1&b=2\r\n" +
+		"WARC-Date: 2024-01-02T03:04:05Z\r\n" +
+		"WARC-Record-ID: <urn:uuid:1>\r\n" +
+		"WARC-Block-Digest: sha1:BLOCK\r\n"
+	recs := []string{
+		warcRecord(fields+"WARC-Payload-Digest: sha1:PAYLOAD\r\n", "hello"),
+		warcRecord(fields, "hello, again"),
+	}
+
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("jsonl", &out))
+	for i, r := range recs {
+		w.add(newResult(&rawRecord{in: &inputFile{}, seq: i, data: []byte(r), offset: -1}))
+	}
+
+	// the URL is written once, and as is
+	if !strings.Contains(out.String(), `"url":"http://example.com/?a=1&b=2"`) {
+		t.Errorf("URL escaped: %v", out.String())
+	}
+
+	var got []entry
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, e)
+	}
+
+	want := entry{
+		URL:      "http://example.com/?a=1&b=2",
+		Type:     "response",
+		Date:     "2024-01-02T03:04:05Z",
+		RecordID: "<urn:uuid:1>",
+		Digest:   "sha1:PAYLOAD",
+		Length:   5,
+	}
+
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCSVOutput(t *testing.T) {
+	defer func(fields []string) { outputFields = fields }(outputFields)
+	var err error
+	if outputFields, err = parseFields("url,date,type,length"); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &entry{URL: `http://example.com/a,"b"`, Type: "response", Date: "2024-01-02T03:04:05Z", Length: 12}
+	for _, test := range []struct{ format, want string }{
+		{"csv", "url,date,type,length\n\"http://example.com/a,\"\"b\"\"\",2024-01-02T03:04:05Z,response,12\n"},
+		{"tsv", "url\tdate\ttype\tlength\n\"http://example.com/a,\"\"b\"\"\"\t2024-01-02T03:04:05Z\tresponse\t12\n"},
+	} {
+		var out bytes.Buffer
+		w := newEntryWriter(test.format, &out)
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		} else if err := w.Close(); err != nil {
+			t.Fatal(err)
+		} else if out.String() != test.want {
+			t.Errorf("%v: got %q, want %q", test.format, out.String(), test.want)
+		}
+
+		// the header is written even without entries
+		out.Reset()
+		newEntryWriter(test.format, &out).Close()
+		if want := strings.SplitAfter(test.want, "\n")[0]; out.String() != want {
+			t.Errorf("%v: got %q without entries, want %q", test.format, out.String(), want)
+		}
+	}
+
+	if _, err := parseFields("url,size"); err == nil {
+		t.Error("parsed unknown field")
+	}
+}
+
+func TestAtomicFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.txt")
+	os.WriteFile(path, []byte("old\n"), 0644)
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	io.WriteString(f, "new\n")
+	if data, _ := os.ReadFile(path); string(data) != "old\n" {
+		t.Fatalf("replaced before commit: %q", data)
+	} else if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	} else if data, _ := os.ReadFile(path); string(data) != "new\n" {
+		t.Fatalf("got %q after commit", data)
+	}
+
+	f, err = createAtomic(filepath.Join(dir, "aborted.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	io.WriteString(f, "partial\n")
+	f.Abort()
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "urls.txt" {
+		t.Fatalf("left %v files", len(entries))
+	}
+}
+
+func TestAtomicFileSuspended(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "urls.txt")
+	f, err := createAtomic(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// committed while suspended, as shard files are
+	io.WriteString(f, "a\n")
+	if err := f.suspend(); err != nil {
+		t.Fatal(err)
+	} else if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	} else if data, _ := os.ReadFile(path); string(data) != "a\n" {
+		t.Fatalf("got %q after commit", data)
+	}
+}
+
+func TestRemoveTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		f, err := createAtomic(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.WriteString(f, "partial\n")
+		if name == "b.txt" {
+			if err := f.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	removeTempFiles()
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "b.txt" {
+		t.Fatalf("left %v files", len(entries))
+	}
+}
+
+func TestCompressedOutput(t *testing.T) {
+	defer func(c string) { *compress = c }(*compress)
+	for _, c := range []string{"gzip", "zstd"} {
+		*compress = c
+		var out bytes.Buffer
+		w, err := openOutput(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.Write(&entry{URL: "http://a/"})
+		w.Write(&entry{URL: "http://b/"})
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var r io.Reader
+		if c == "gzip" {
+			r, err = gzip.NewReader(&out)
+		} else {
+			r, err = zstd.NewReader(&out)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data, err := io.ReadAll(r); err != nil {
+			t.Fatal(err)
+		} else if string(data) != "http://a/\nhttp://b/\n" {
+			t.Errorf("%v: got %q", c, data)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		s    string
+		unit int64
+		want int64
+		ok   bool
+	}{
+		{"", 1024, 0, true},
+		{"512", 1024, 512, true},
+		{"1G", 1024, 1 << 30, true},
+		{"10M", 1000, 10000000, true},
+		{"2k", 1000, 2000, true},
+		{"1.5G", 1024, 0, false},
+		{"-1M", 1000, 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseQuantity(test.s, test.unit)
+		if ok := err == nil; ok != test.ok || ok && got != test.want {
+			t.Errorf("%q: got %v %v", test.s, got, err)
+		}
+	}
+}
+
+func TestPartName(t *testing.T) {
+	for path, want := range map[string]string{
+		"urls.txt":          "urls-0002.txt",
+		"out/urls.txt.gz":   "out/urls-0002.txt.gz",
+		"urls":              "urls-0002",
+		"/tmp/x.y/urls.csv": "/tmp/x.y/urls-0002.csv",
+	} {
+		if got := partName(path, 2); got != want {
+			t.Errorf("%q: got %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRotatingWriter(t *testing.T) {
+	defer func(format string, fields []string) {
+		outputFormat, outputFields = format, fields
+	}(outputFormat, outputFields)
+	outputFormat, outputFields = "csv", []string{"url"}
+
+	readParts := func(dir string) []string {
+		var parts []string
+		entries, _ := os.ReadDir(dir)
+		for _, de := range entries {
+			if !strings.HasSuffix(de.Name(), ".csv") {
+				// the temporary file of the current part
+				continue
+			}
+
+			data, _ := os.ReadFile(filepath.Join(dir, de.Name()))
+			parts = append(parts, de.Name()+":"+string(data))
+		}
+
+		return parts
+	}
+
+	for _, test := range []struct {
+		size, count int64
+		want        []string
+	}{
+		{0, 2, []string{"urls-0001.csv:url\na\nb\n", "urls-0002.csv:url\nc\nd\n", "urls-0003.csv:url\ne\n"}},
+		{8, 0, []string{"urls-0001.csv:url\na\nb\n", "urls-0002.csv:url\nc\nd\n", "urls-0003.csv:url\ne\n"}},
+		{0, 100, []string{"urls-0001.csv:url\na\nb\nc\nd\ne\n"}},
+	} {
+		dir := t.TempDir()
+		rw := &rotatingWriter{path: filepath.Join(dir, "urls.csv"), maxSize: test.size, maxCount: test.count}
+		w := newResultWriter(rw)
+		for i, url := range []string{"a", "b", "a", "c", "d", "b", "e"} {
+			w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{{URL: url}}})
+		}
+
+		if got := readParts(dir); len(got) != len(test.want)-1 {
+			t.Errorf("size=%v count=%v: %v parts before Close", test.size, test.count, len(got))
+		}
+
+		if err := rw.Close(); err != nil {
+			t.Fatal(err)
+		} else if got := readParts(dir); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("size=%v count=%v: got %q, want %q", test.size, test.count, got, test.want)
+		}
+	}
+
+	// an empty output still has a part
+	dir := t.TempDir()
+	rw := &rotatingWriter{path: filepath.Join(dir, "urls.csv"), maxCount: 2}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	} else if got := readParts(dir); len(got) != 1 || got[0] != "urls-0001.csv:url\n" {
+		t.Errorf("got %q without entries", got)
+	}
+}
+
+func TestPrint0(t *testing.T) {
+	defer func(p bool) { *print0 = p }(*print0)
+	*print0 = true
+	var out bytes.Buffer
+	w := newEntryWriter("text", &out)
+	w.Write(&entry{URL: "http://a/\nb"})
+	w.Write(&entry{URL: "http://c/"})
+	if want := "http://a/\nb\x00http://c/\x00"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestRecordEntry(t *testing.T) {
+	tests := []struct {
+		fields, block string
+		status        int
+		mime          string
+	}{
+		{
+			"WARC-Type: response\r\nContent-Type: application/http; msgtype=response\r\n",
+			"HTTP/1.1 404 Not Found\r\ncontent-type: Text/HTML; charset=utf-8\r\n\r\n<html>",
+			404, "text/html",
+		},
+		{
+			"WARC-Type: response\r\nContent-Type: application/http;msgtype=response\r\n",
+			"HTTP/1.0 301\nLocation: /x\n\n",
+			301, "",
+		},
+		{
+			"WARC-Type: request\r\nContent-Type: application/http; msgtype=request\r\n",
+			"GET / HTTP/1.1\r\nContent-Type: text/plain\r\n\r\n",
+			0, "",
+		},
+		{
+			"WARC-Type: resource\r\nContent-Type: image/png\r\n",
+			"\x89PNG",
+			0, "image/png",
+		},
+	}
+
+	for _, test := range tests {
+		res := newResult(&rawRecord{data: []byte(warcRecord(test.fields+"WARC-Target-URI: http://Example.COM:80/\r\n", test.block))})
+		if len(res.entries) != 1 {
+			t.Fatalf("%q: %v entries", test.fields, len(res.entries))
+		}
+
+		e := res.entries[0]
+		if e.Status != test.status || e.MIME != test.mime || e.host() != "example.com" {
+			t.Errorf("%q: got status %v, mime %q, host %q", test.fields, e.Status, e.MIME, e.host())
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	defer func(tmpl *template.Template) { outputTemplate = tmpl }(outputTemplate)
+	var err error
+	if outputTemplate, err = parseTemplate(`{{.URL}}\t{{.Date}}\t{{.Digest}}\\t{{if .Status}} {{.Status}}{{end}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	w := newEntryWriter("text", &out)
+	w.Write(&entry{URL: "http://a/?x&y", Date: "2024-01-02T03:04:05Z", Digest: "sha1:X", Status: 200})
+	w.Write(&entry{URL: "http://b/"})
+	if want := "http://a/?x&y\t2024-01-02T03:04:05Z\tsha1:X\\t 200\nhttp://b/\t\t\\t\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+
+	if _, err := parseTemplate("{{.URL"); err == nil {
+		t.Error("parsed unterminated action")
+	}
+}
+
+func TestOffsetsOutput(t *testing.T) {
+	defer func(o bool, fields []string) { *recOffsets, outputFields = o, fields }(*recOffsets, outputFields)
+	*recOffsets = true
+	outputFields = offsetFields
+	entries := []*entry{
+		{URL: "http://a/", File: "a.warc.gz", Offset: 0, CompressedLength: 312},
+		{URL: "http://b/", File: "a.warc.gz", Offset: 312, CompressedLength: -1},
+		{URL: "http://c/", Offset: -1, CompressedLength: -1},
+	}
+
+	for _, test := range []struct{ format, want string }{
+		{"text", "http://a/\ta.warc.gz\t0\t312\nhttp://b/\ta.warc.gz\t312\t-\nhttp://c/\t-\t-\t-\n"},
+		{"jsonl", `{"url":"http://a/","length":0,"file":"a.warc.gz","offset":0,"compressed_length":312}` + "\n" +
+			`{"url":"http://b/","length":0,"file":"a.warc.gz","offset":312}` + "\n" +
+			`{"url":"http://c/","length":0}` + "\n"},
+		{"csv", "file,offset,compressed_length\na.warc.gz,0,312\na.warc.gz,312,\n,,\n"},
+	} {
+		var out bytes.Buffer
+		w := newEntryWriter(test.format, &out)
+		for _, e := range entries {
+			w.Write(e)
+		}
+
+		w.Close()
+		if out.String() != test.want {
+			t.Errorf("%v: got %q, want %q", test.format, out.String(), test.want)
+		}
+	}
+}
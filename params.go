@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+var (
+	stripParams   = flag.String("strip-params", "", "remove the query parameters of these names from the URLs before they're deduplicated and output, comma separated and case insensitive, with * for any characters, e.g. 'utm_*,fbclid,gclid'")
+	stripTracking = flag.Bool("strip-tracking", false, "remove the query parameters of common trackers, e.g. utm_*, fbclid and gclid, as with -strip-params")
+	stripFragment = flag.Bool("strip-fragment", false, "remove the #fragments of the URLs before they're deduplicated and output")
+	sortQuery     = flag.Bool("sort-query", false, "sort the query parameters of the URLs by name before they're deduplicated and output")
+)
+
+// the query parameters removed by -strip-tracking
+var trackingParams = []string{
+	"utm_*", "fbclid", "gclid", "gclsrc", "dclid", "gbraid", "wbraid",
+	"msclkid", "yclid", "twclid", "ttclid", "igshid", "li_fat_id",
+	"mc_cid", "mc_eid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+	"oly_anon_id", "oly_enc_id", "vero_id", "wickedid", "rb_clickid",
+	"s_cid", "srsltid",
+}
+
+// paramStripper returns the rewrite removing the query parameters of
+// the names matching patterns
+func paramStripper(patterns []string) (urlRewrite, error) {
+	for i, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid -strip-params pattern %q: %v", p, err)
+		}
+
+		patterns[i] = strings.ToLower(p)
+	}
+
+	return func(s string) string {
+		return removeParams(s, patterns)
+	}, nil
+}
+
+// removeParams returns s without the query parameters of names matching
+// patterns, and without the ? if none is left
+func removeParams(s string, patterns []string) string {
+	base, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return s
+	}
+
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	var args []string
+	var removed bool
+	for _, arg := range strings.Split(query, "&") {
+		name, _, _ := strings.Cut(arg, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+
+		if len(arg) > 0 && matchAny(patterns, strings.ToLower(name)) {
+			removed = true
+		} else {
+			args = append(args, arg)
+		}
+	}
+
+	if !removed {
+		return s
+	}
+
+	s = base
+	if len(args) > 0 {
+		s += "?" + strings.Join(args, "&")
+	}
+
+	if hasFragment {
+		s += "#" + fragment
+	}
+
+	return s
+}
+
+func removeFragment(s string) string {
+	s, _, _ = strings.Cut(s, "#")
+	return s
+}
+
+// sortParams returns s with its query parameters sorted by name, those of
+// the same name in the order they were
+func sortParams(s string) string {
+	base, query, ok := strings.Cut(s, "?")
+	if !ok {
+		return s
+	}
+
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	args := strings.Split(query, "&")
+	sort.SliceStable(args, func(i, j int) bool {
+		a, _, _ := strings.Cut(args[i], "=")
+		b, _, _ := strings.Cut(args[j], "=")
+		return a < b
+	})
+
+	s = base + "?" + strings.Join(args, "&")
+	if hasFragment {
+		s += "#" + fragment
+	}
+
+	return s
+}
@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestRemoveParams(t *testing.T) {
+	patterns := []string{"utm_*", "fbclid"}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://example.com/a", "http://example.com/a"},
+		{"http://example.com/a?b=1", "http://example.com/a?b=1"},
+		{"http://example.com/a?utm_source=x&b=1&UTM_Medium=y", "http://example.com/a?b=1"},
+		{"http://example.com/a?fbclid=x", "http://example.com/a"},
+		{"http://example.com/a?fbclid=x#top", "http://example.com/a#top"},
+		{"http://example.com/a?b=1&fbclid&c=2", "http://example.com/a?b=1&c=2"},
+		{"http://example.com/a?utm%5Fsource=x&b", "http://example.com/a?b"},
+		{"http://example.com/a?b=utm_source", "http://example.com/a?b=utm_source"},
+		{"http://example.com/a?#utm_source=x", "http://example.com/a?#utm_source=x"},
+	}
+
+	for _, tt := range tests {
+		if got := removeParams(tt.in, patterns); got != tt.want {
+			t.Errorf("removeParams(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetupRewritesStripParams(t *testing.T) {
+	oldParams, oldTracking := *stripParams, *stripTracking
+	defer func() {
+		*stripParams, *stripTracking = oldParams, oldTracking
+		setupRewrites()
+	}()
+
+	*stripParams, *stripTracking = "sid", true
+	if err := setupRewrites(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := rewriteURL("http://example.com/?gclid=1&sid=2&q=3"), "http://example.com/?q=3"; got != want {
+		t.Errorf("rewriteURL = %q, want %q", got, want)
+	}
+
+	*stripParams = "["
+	if err := setupRewrites(); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+}
+
+func TestSortParams(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"http://example.com/a", "http://example.com/a"},
+		{"http://example.com/a?b=2&a=1", "http://example.com/a?a=1&b=2"},
+		{"http://example.com/a?b=2&a=3&a=1", "http://example.com/a?a=3&a=1&b=2"},
+		{"http://example.com/a?b&a=1#x=1&y", "http://example.com/a?a=1&b#x=1&y"},
+		{"http://example.com/a?", "http://example.com/a?"},
+	}
+
+	for _, tt := range tests {
+		if got := sortParams(tt.in); got != tt.want {
+			t.Errorf("sortParams(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRemoveFragment(t *testing.T) {
+	for in, want := range map[string]string{
+		"http://example.com/a":          "http://example.com/a",
+		"http://example.com/a#top":      "http://example.com/a",
+		"http://example.com/a?b=1#top#": "http://example.com/a?b=1",
+		"http://example.com/a#":         "http://example.com/a",
+	} {
+		if got := removeFragment(in); got != want {
+			t.Errorf("removeFragment(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRow is a row of -format parquet. Dates that aren't valid are
+// null; status is 0 for records that aren't responses.
+type parquetRow struct {
+	URL    string     `parquet:"url"`
+	Host   string     `parquet:"host"`
+	Date   *time.Time `parquet:"date,optional,timestamp(millisecond)"`
+	MIME   string     `parquet:"mime"`
+	Status int32      `parquet:"status"`
+}
+
+// parquetWriter writes rows in batches, which parquet-go buffers into row
+// groups. The file is only complete once closed.
+type parquetWriter struct {
+	w    *parquet.GenericWriter[parquetRow]
+	rows []parquetRow
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{
+		w: parquet.NewGenericWriter[parquetRow](w, parquet.Compression(&parquet.Snappy)),
+	}
+}
+
+func (w *parquetWriter) Write(e *entry) error {
+	var date *time.Time
+	if d, err := time.Parse(time.RFC3339Nano, e.Date); err == nil {
+		date = &d
+	}
+
+	w.rows = append(w.rows, parquetRow{
+		URL:    e.URL,
+		Host:   e.host(),
+		Date:   date,
+		MIME:   e.MIME,
+		Status: int32(e.Status),
+	})
+
+	if len(w.rows) >= 1024 {
+		return w.flush()
+	}
+
+	return nil
+}
+
+func (w *parquetWriter) flush() error {
+	_, err := w.w.Write(w.rows)
+	w.rows = w.rows[:0]
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	return w.w.Close()
+}
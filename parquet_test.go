@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetOutput(t *testing.T) {
+	var buf bytes.Buffer
+	w := newEntryWriter("parquet", &buf)
+	var want []parquetRow
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 3000; i++ {
+		e := &entry{URL: "http://Host.example/" + string(rune('a'+i%26)), Date: "2024-01-02T03:04:05Z",
+			MIME: "text/html", Status: 200}
+		if i == 5 {
+			e.Date, e.Status, e.MIME = "yesterday", 0, ""
+		}
+
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+
+		row := parquetRow{URL: e.URL, Host: "host.example", Date: &date, MIME: e.MIME, Status: int32(e.Status)}
+		if i == 5 {
+			row.Date = nil
+		}
+
+		want = append(want, row)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := parquet.Read[parquetRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(got) != len(want) {
+		t.Fatalf("got %v rows, want %v", len(got), len(want))
+	}
+
+	for i := range got {
+		if (got[i].Date == nil) != (want[i].Date == nil) || got[i].Date != nil && !got[i].Date.Equal(*want[i].Date) {
+			t.Fatalf("row %v: got date %v, want %v", i, got[i].Date, want[i].Date)
+		}
+
+		got[i].Date = want[i].Date
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Fatalf("row %v: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
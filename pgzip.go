@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+const pgzipBlockSize = 4 << 20
+
+// ID1, ID2 and CM (deflate) of a gzip member header
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+type gzipChunk struct {
+	data []byte
+	out  []byte
+	err  error
+	done chan struct{}
+}
+
+// parallelGzip decompresses multi-member gzip streams, such as WARC files
+// with one member per record, using several goroutines. The compressed
+// stream is split where a member header seems to start, and the chunks
+// are inflated concurrently and reassembled in order. A split that turns
+// out to be inside a member (the magic bytes occurring in deflate data)
+// makes that chunk come out truncated, in which case inflation continues
+// sequentially across the following chunks until a member ends exactly
+// at a chunk boundary.
+type parallelGzip struct {
+	jobs    chan *gzipChunk
+	ordered chan *gzipChunk
+	quit    chan struct{}
+	pr      *io.PipeReader
+}
+
+func newParallelGzip(r io.Reader, nworkers int) *parallelGzip {
+	pr, pw := io.Pipe()
+	p := &parallelGzip{
+		jobs:    make(chan *gzipChunk, nworkers*2),
+		ordered: make(chan *gzipChunk, nworkers*2),
+		quit:    make(chan struct{}),
+		pr:      pr,
+	}
+
+	for i := 0; i < nworkers; i++ {
+		go p.inflate()
+	}
+
+	go p.split(r)
+	go p.consume(pw)
+	return p
+}
+
+func (p *parallelGzip) Read(b []byte) (int, error) {
+	return p.pr.Read(b)
+}
+
+func (p *parallelGzip) Close() error {
+	return p.pr.Close()
+}
+
+// send queues c for the consumer and, unless it is already done, for the
+// workers. done must exist before c is visible to the consumer.
+func (p *parallelGzip) send(c *gzipChunk) bool {
+	inflate := c.done == nil
+	if inflate {
+		c.done = make(chan struct{})
+	}
+
+	select {
+	case p.ordered <- c:
+	case <-p.quit:
+		return false
+	}
+
+	if inflate {
+		p.jobs <- c
+	}
+
+	return true
+}
+
+func (p *parallelGzip) split(r io.Reader) {
+	defer close(p.jobs)
+	defer close(p.ordered)
+
+	var buf []byte
+	from := 1
+	for {
+		for from < len(buf) {
+			i := bytes.Index(buf[from:], gzipMagic)
+			if i < 0 {
+				if from = len(buf) - len(gzipMagic) + 1; from < 1 {
+					from = 1
+				}
+
+				break
+			}
+
+			at := from + i
+			if at+3 >= len(buf) {
+				// need the FLG byte to tell
+				from = at
+				break
+			} else if buf[at+3]&0xe0 != 0 {
+				// reserved flag bits set, not a header
+				from = at + 1
+				continue
+			}
+
+			if !p.send(&gzipChunk{data: buf[:at:at]}) {
+				return
+			}
+
+			buf = buf[at:]
+			from = 1
+		}
+
+		if cap(buf)-len(buf) < pgzipBlockSize/4 {
+			nb := make([]byte, len(buf), len(buf)+pgzipBlockSize)
+			copy(nb, buf)
+			buf = nb
+		}
+
+		n, err := r.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err == io.EOF {
+			if len(buf) > 0 {
+				p.send(&gzipChunk{data: buf})
+			}
+
+			return
+		} else if err != nil {
+			done := make(chan struct{})
+			close(done)
+			p.send(&gzipChunk{err: err, done: done})
+			return
+		}
+	}
+}
+
+func (p *parallelGzip) inflate() {
+	for c := range p.jobs {
+		zr, err := gzip.NewReader(bytes.NewReader(c.data))
+		if err == nil {
+			c.out, err = io.ReadAll(zr)
+		}
+
+		c.err = err
+		close(c.done)
+	}
+}
+
+func (p *parallelGzip) consume(w *io.PipeWriter) {
+	defer func() {
+		close(p.quit)
+		for range p.ordered {
+		}
+	}()
+
+	for c := range p.ordered {
+		<-c.done
+		var err error
+		if c.err == nil {
+			_, err = w.Write(c.out)
+		} else if c.err == io.ErrUnexpectedEOF {
+			err = p.stream(c, w)
+		} else {
+			err = c.err
+		}
+
+		if err != nil {
+			w.CloseWithError(err)
+			return
+		}
+	}
+
+	w.Close()
+}
+
+// stream inflates sequentially from the start of chunk c until a member
+// ends at a chunk boundary, where parallel results can be used again
+func (p *parallelGzip) stream(c *gzipChunk, w io.Writer) error {
+	cs := &chunkStream{cur: c.data, next: p.ordered}
+	var zr gzip.Reader
+	for {
+		if err := zr.Reset(cs); err != nil {
+			return err
+		}
+
+		zr.Multistream(false)
+		if _, err := io.Copy(w, &zr); err != nil {
+			return err
+		} else if cs.pos == len(cs.cur) {
+			return nil
+		}
+	}
+}
+
+// chunkStream reads the data of consecutive chunks. It implements
+// io.ByteReader so that the gzip reader doesn't read past the end of
+// a member.
+type chunkStream struct {
+	cur  []byte
+	pos  int
+	next chan *gzipChunk
+}
+
+func (cs *chunkStream) fill() error {
+	for cs.pos == len(cs.cur) {
+		c, ok := <-cs.next
+		if !ok {
+			return io.EOF
+		} else if c.data == nil {
+			<-c.done
+			return c.err
+		}
+
+		cs.cur, cs.pos = c.data, 0
+	}
+
+	return nil
+}
+
+func (cs *chunkStream) ReadByte() (byte, error) {
+	if err := cs.fill(); err != nil {
+		return 0, err
+	}
+
+	b := cs.cur[cs.pos]
+	cs.pos++
+	return b, nil
+}
+
+func (cs *chunkStream) Read(b []byte) (int, error) {
+	if err := cs.fill(); err != nil {
+		return 0, err
+	}
+
+	n := copy(b, cs.cur[cs.pos:])
+	cs.pos += n
+	return n, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// gzipMembers returns the concatenation of the given blobs as separate
+// gzip members, and the concatenated plain data
+func gzipMembers(t *testing.T, blobs [][]byte, level int) ([]byte, []byte) {
+	var comp, plain bytes.Buffer
+	for _, blob := range blobs {
+		zw, err := gzip.NewWriterLevel(&comp, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		zw.Write(blob)
+		zw.Close()
+		plain.Write(blob)
+	}
+
+	return comp.Bytes(), plain.Bytes()
+}
+
+func testBlobs(n int, fake bool) [][]byte {
+	rng := rand.New(rand.NewSource(1))
+	blobs := make([][]byte, n)
+	for i := range blobs {
+		if fake && i%3 == 0 {
+			// looks like a member header when stored uncompressed
+			blobs[i] = bytes.Repeat([]byte("abc\x1f\x8b\x08\x00"), rng.Intn(2000)+1)
+		} else {
+			blobs[i] = []byte("WARC/1.0\r\nWARC-Type: response\r\n\r\n")
+			blobs[i] = append(blobs[i], bytes.Repeat([]byte("x"), rng.Intn(5000))...)
+		}
+	}
+
+	return blobs
+}
+
+func TestParallelGzip(t *testing.T) {
+	tests := []struct {
+		name  string
+		level int
+		fake  bool
+	}{
+		{"default", gzip.DefaultCompression, false},
+		{"stored", gzip.NoCompression, false},
+		{"stored with false headers", gzip.NoCompression, true},
+	}
+
+	for _, tt := range tests {
+		comp, plain := gzipMembers(t, testBlobs(500, tt.fake), tt.level)
+		for _, nworkers := range []int{1, 2, 4, 8} {
+			out, err := io.ReadAll(newParallelGzip(bytes.NewReader(comp), nworkers))
+			if err != nil {
+				t.Errorf("%v/%v: %v", tt.name, nworkers, err)
+			} else if !bytes.Equal(out, plain) {
+				t.Errorf("%v/%v: got %v bytes, want %v", tt.name, nworkers, len(out), len(plain))
+			}
+		}
+	}
+}
+
+func TestParallelGzipSingleMember(t *testing.T) {
+	// one large stored member with many false headers in it
+	blob := bytes.Repeat([]byte("\x1f\x8b\x08\x00abcdefgh"), 1<<16)
+	comp, plain := gzipMembers(t, [][]byte{blob}, gzip.NoCompression)
+	out, err := io.ReadAll(newParallelGzip(bytes.NewReader(comp), 4))
+	if err != nil || !bytes.Equal(out, plain) {
+		t.Fatalf("got %v bytes, %v, want %v bytes", len(out), err, len(plain))
+	}
+}
+
+func TestParallelGzipTruncated(t *testing.T) {
+	for _, fake := range []bool{false, true} {
+		comp, _ := gzipMembers(t, testBlobs(100, fake), gzip.NoCompression)
+		_, err := io.ReadAll(newParallelGzip(bytes.NewReader(comp[:len(comp)-10]), 4))
+		if err == nil {
+			t.Errorf("fake=%v: truncated input read without error", fake)
+		}
+	}
+}
+
+func TestParallelGzipClose(t *testing.T) {
+	comp, _ := gzipMembers(t, testBlobs(500, true), gzip.NoCompression)
+	p := newParallelGzip(bytes.NewReader(comp), 4)
+	buf := make([]byte, 10)
+	if _, err := io.ReadFull(p, buf); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Close()
+	if _, err := p.Read(buf); err != io.ErrClosedPipe {
+		t.Fatalf("read after close: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	pgDSN    = flag.String("pg-dsn", "", "COPY the URLs into a PostgreSQL table, connecting with this DSN, instead of writing them out")
+	pgTable  = flag.String("pg-table", "urls", "[schema.]table for -pg-dsn, created if missing")
+	pgBatch  = flag.Int("pg-batch", 10000, "URLs copied per -pg-dsn transaction")
+	pgUpsert = flag.Bool("pg-upsert", false, "update the rows of URLs already in the -pg-table, instead of failing on them")
+)
+
+var pgColumns = []string{"url", "from_url", "warc_type", "warc_date", "record_id", "digest", "length", "mime", "status"}
+
+// pgTarget is a -pg-table, as its schema and table name
+type pgTarget struct {
+	schema, table string
+}
+
+func parsePGTable(s string) (pgTarget, error) {
+	var t pgTarget
+	parts := strings.Split(s, ".")
+	switch len(parts) {
+	case 1:
+		t.table = parts[0]
+	case 2:
+		t.schema, t.table = parts[0], parts[1]
+	default:
+		return t, fmt.Errorf("%q: expected [schema.]table", s)
+	}
+
+	if len(t.table) == 0 || len(parts) == 2 && len(t.schema) == 0 {
+		return t, fmt.Errorf("%q: expected [schema.]table", s)
+	}
+
+	return t, nil
+}
+
+func (t pgTarget) String() string {
+	if len(t.schema) == 0 {
+		return pq.QuoteIdentifier(t.table)
+	}
+
+	return pq.QuoteIdentifier(t.schema) + "." + pq.QuoteIdentifier(t.table)
+}
+
+func (t pgTarget) createTable() string {
+	return `CREATE TABLE IF NOT EXISTS ` + t.String() + ` (
+	url       TEXT PRIMARY KEY,
+	from_url  TEXT,
+	warc_type TEXT,
+	warc_date TIMESTAMPTZ,
+	record_id TEXT,
+	digest    TEXT,
+	length    BIGINT,
+	mime      TEXT,
+	status    INTEGER
+)`
+}
+
+// pgLoadTable is where the rows of a batch are copied to with -pg-upsert,
+// for them to be upserted from, as COPY itself can't
+const pgLoadTable = "warc_urls_load"
+
+func (t pgTarget) createLoadTable() string {
+	return "CREATE TEMPORARY TABLE " + pgLoadTable + " (LIKE " + t.String() + " INCLUDING DEFAULTS) ON COMMIT DROP"
+}
+
+func (t pgTarget) upsert() string {
+	cols := strings.Join(pgColumns, ", ")
+	var set []string
+	for _, c := range pgColumns[1:] {
+		set = append(set, c+" = EXCLUDED."+c)
+	}
+
+	return "INSERT INTO " + t.String() + " (" + cols + ") SELECT " + cols + " FROM " + pgLoadTable +
+		" ON CONFLICT (url) DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// pgWriter copies entries in transactions of batch entries
+type pgWriter struct {
+	db     *sql.DB
+	t      pgTarget
+	upsert bool
+	tx     *sql.Tx
+	stmt   *sql.Stmt
+	batch  int
+	n      int // entries in tx
+}
+
+func newPGWriter(dsn, table string, batch int, upsert bool) (*pgWriter, error) {
+	t, err := parsePGTable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single connection, for the temporary tables of -pg-upsert
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(t.createTable()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &pgWriter{db: db, t: t, upsert: upsert, batch: batch}, nil
+}
+
+func (w *pgWriter) begin() error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	copyIn := pq.CopyInSchema(w.t.schema, w.t.table, pgColumns...)
+	if w.upsert {
+		if _, err := tx.Exec(w.t.createLoadTable()); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		copyIn = pq.CopyIn(pgLoadTable, pgColumns...)
+	}
+
+	stmt, err := tx.Prepare(copyIn)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	w.tx, w.stmt = tx, stmt
+	return nil
+}
+
+func (w *pgWriter) Write(e *entry) error {
+	if w.tx == nil {
+		if err := w.begin(); err != nil {
+			return err
+		}
+	}
+
+	var date interface{}
+	if len(e.Date) > 0 {
+		date = e.Date
+	}
+
+	if _, err := w.stmt.Exec(e.URL, e.From, e.Type, date, e.RecordID, e.Digest, e.Length, e.MIME, e.Status); err != nil {
+		return err
+	}
+
+	w.n++
+	if w.n >= w.batch {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush ends the COPY, and commits the current transaction
+func (w *pgWriter) Flush() error {
+	if w.tx == nil {
+		return nil
+	}
+
+	tx, stmt := w.tx, w.stmt
+	w.tx, w.stmt, w.n = nil, nil, 0
+	_, err := stmt.Exec()
+	if cerr := stmt.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil && w.upsert {
+		_, err = tx.Exec(w.t.upsert())
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (w *pgWriter) Close() error {
+	err := w.Flush()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePGTable(t *testing.T) {
+	for s, want := range map[string]string{
+		"urls":            `"urls"`,
+		"warehouse.urls":  `"warehouse"."urls"`,
+		`we"ird.Captures`: `"we""ird"."Captures"`,
+	} {
+		if got, err := parsePGTable(s); err != nil || got.String() != want {
+			t.Errorf("%q: got %v %v, want %v", s, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", ".urls", "urls.", "a.b.c"} {
+		if _, err := parsePGTable(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+func TestPGUpsert(t *testing.T) {
+	target, _ := parsePGTable("warehouse.urls")
+	got := target.upsert()
+	for _, want := range []string{
+		`INSERT INTO "warehouse"."urls" (url, from_url, `,
+		" FROM " + pgLoadTable + " ON CONFLICT (url) DO UPDATE SET from_url = EXCLUDED.from_url, ",
+		", status = EXCLUDED.status",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not in %q", want, got)
+		}
+	}
+
+	if strings.Contains(got, "url = EXCLUDED.url") {
+		t.Errorf("updates the url: %q", got)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sebcat/warc"
+	"github.com/ulikunitz/xz"
+)
+
+// recordReader returns raw WARC records, one per call, and io.EOF at the
+// end of input.
+type recordReader interface {
+	NextRaw() ([]byte, error)
+}
+
+// offsetReader is a recordReader that knows the compressed offset and
+// length of the last record returned, or -1 if it doesn't
+type offsetReader interface {
+	Offset() int64
+	Length() int64
+}
+
+// trackOffsets makes gzipped inputs be read member by member, so that
+// record offsets are known
+var trackOffsets bool
+
+// trackLengths also finds the compressed length of records that are alone
+// in their gzip member, by reading on to the end of the member
+var trackLengths bool
+
+var inputFormats = []string{"auto", "gzip", "bzip2", "xz", "zstd", "plain"}
+
+// detectFormat guesses the compression of an input from its magic bytes
+func detectFormat(br *bufio.Reader) string {
+	magic, _ := br.Peek(4)
+	switch {
+	case len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b:
+		return "gzip"
+	case len(magic) == 4 && isZstdMagic(binary.LittleEndian.Uint32(magic)):
+		return "zstd"
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return "bzip2"
+	case bytes.HasPrefix(magic, []byte("\xfd7zX")):
+		return "xz"
+	}
+
+	return "plain"
+}
+
+// newRecordReader returns a record reader for an input in the given
+// format, or in the format detected from its first bytes for "auto".
+// ARC files are recognized by their content in all formats. A stream,
+// such as a pipe, is read without waiting for data beyond the record
+// being returned, so records are passed on as soon as they are written.
+func newRecordReader(r io.Reader, format string, stream bool) (recordReader, error) {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if format == "auto" {
+		format = detectFormat(br)
+	}
+
+	switch format {
+	case "gzip":
+		if *gzipWorkers > 0 && !stream {
+			// a member is only passed on once the next one starts
+			return newDecompressedReader(newParallelGzip(br, *gzipWorkers)), nil
+		}
+
+		n := 4096
+		if stream {
+			n = br.Buffered()
+		}
+
+		peek, _ := br.Peek(n)
+		if zr, err := gzip.NewReader(bytes.NewReader(peek)); err == nil {
+			var head [11]byte
+			n, _ := io.ReadFull(zr, head[:])
+			if bytes.Equal(head[:n], []byte("filedesc://")) {
+				zr, err := gzip.NewReader(br)
+				if err != nil {
+					return nil, err
+				}
+
+				return newARCReader(zr), nil
+			}
+		}
+
+		if trackOffsets || stream {
+			return newMemberReader(br), nil
+		}
+
+		return warc.NewGZIPReader(br)
+	case "bzip2":
+		return newDecompressedReader(bzip2.NewReader(br)), nil
+	case "xz":
+		zr, err := xz.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+
+		return newDecompressedReader(zr), nil
+	case "zstd":
+		zr, err := newZstdReader(br, stream)
+		if err != nil {
+			return nil, err
+		}
+
+		return newDecompressedReader(zr), nil
+	case "plain":
+		r := newDecompressedReader(br)
+		if pr, ok := r.(*plainReader); ok {
+			// positions in br are positions in the input
+			pr.offsets = true
+		}
+
+		return r, nil
+	}
+
+	return nil, fmt.Errorf("unknown input format %v", format)
+}
+
+func newDecompressedReader(r io.Reader) recordReader {
+	br := bufio.NewReaderSize(r, 64*1024)
+	if head, _ := br.Peek(11); bytes.Equal(head, []byte("filedesc://")) {
+		return &arcReader{r: r, br: br, nfields: 5}
+	}
+
+	return &plainReader{r: r, br: br}
+}
+
+// recordBlock returns the block of a raw WARC record, i.e. what follows
+// the first empty line. Bare LF line endings are tolerated.
+func recordBlock(rec []byte) []byte {
+	for i := bytes.IndexByte(rec, '\n'); i >= 0 && i+1 < len(rec); {
+		rest := rec[i+1:]
+		if rest[0] == '\n' {
+			return rest[1:]
+		} else if len(rest) > 1 && rest[0] == '\r' && rest[1] == '\n' {
+			return rest[2:]
+		}
+
+		j := bytes.IndexByte(rest, '\n')
+		if j < 0 {
+			break
+		}
+
+		i += j + 1
+	}
+
+	return nil
+}
+
+// plainReader reads records from an uncompressed WARC stream
+type plainReader struct {
+	r  io.Reader
+	br *bufio.Reader
+
+	// the position in br, and where the last record starts and ends
+	pos, start, end int64
+	offsets         bool // whether Offset and Length are known
+}
+
+func (r *plainReader) NextRaw() ([]byte, error) {
+	var rec bytes.Buffer
+	length := int64(-1)
+	for {
+		line, err := r.br.ReadBytes('\n')
+		r.pos += int64(len(line))
+		if err == io.EOF && rec.Len() == 0 && len(bytes.TrimSpace(line)) == 0 {
+			return nil, io.EOF
+		} else if err == io.EOF {
+			return nil, warc.ErrMalformedRecord
+		} else if err != nil {
+			return nil, err
+		}
+
+		if rec.Len() == 0 {
+			if len(bytes.TrimSpace(line)) == 0 {
+				// separator between records
+				continue
+			} else if !bytes.HasPrefix(line, []byte("WARC/")) {
+				r.resync()
+				return nil, warc.ErrMalformedRecord
+			}
+
+			r.start = r.pos - int64(len(line))
+		}
+
+		rec.Write(line)
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			break
+		}
+
+		name, value, ok := bytes.Cut(line, []byte(":"))
+		if ok && bytes.EqualFold(bytes.TrimSpace(name), []byte("Content-Length")) {
+			length, err = strconv.ParseInt(string(bytes.TrimSpace(value)), 10, 64)
+			if err != nil {
+				length = -1
+			}
+		}
+	}
+
+	if length < 0 {
+		// skip the block too, so that it's only reported once
+		r.resync()
+		return nil, warc.ErrMalformedRecord
+	}
+
+	n, err := io.CopyN(&rec, r.br, length)
+	r.pos += n
+	if err != nil {
+		return nil, warc.ErrMalformedRecord
+	}
+
+	if r.offsets && trackLengths {
+		// the separator is part of the record's length
+		r.atEOF()
+	}
+
+	r.end = r.pos
+	return rec.Bytes(), nil
+}
+
+// atEOF skips empty lines, and tells whether that's all there is left
+func (r *plainReader) atEOF() bool {
+	for {
+		b, err := r.br.ReadByte()
+		if err != nil {
+			return err == io.EOF
+		} else if b != '\r' && b != '\n' {
+			r.br.UnreadByte()
+			return false
+		}
+
+		r.pos++
+	}
+}
+
+func (r *plainReader) Offset() int64 {
+	if !r.offsets {
+		return -1
+	}
+
+	return r.start
+}
+
+func (r *plainReader) Length() int64 {
+	if !r.offsets {
+		return -1
+	}
+
+	return r.end - r.start
+}
+
+// resync skips ahead to the next line that looks like the start of a record
+func (r *plainReader) resync() {
+	for {
+		if peek, err := r.br.Peek(5); err != nil || bytes.Equal(peek, []byte("WARC/")) {
+			return
+		} else if line, err := r.br.ReadSlice('\n'); err != nil && err != bufio.ErrBufferFull {
+			r.pos += int64(len(line))
+			return
+		} else {
+			r.pos += int64(len(line))
+		}
+	}
+}
+
+func (r *plainReader) Close() error {
+	if c, ok := r.r.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// countingReader counts the bytes consumed from a bufio.Reader. It's an
+// io.ByteReader, so gzip doesn't read past the end of a member.
+type countingReader struct {
+	br *bufio.Reader
+	n  int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.br.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.br.ReadByte()
+	if err == nil {
+		c.n++
+	}
+
+	return b, err
+}
+
+// memberReader reads records from gzip members one at a time, keeping
+// track of where each member starts. A record starting a member has the
+// offset of the member, others have an unknown offset.
+type memberReader struct {
+	cr     *countingReader
+	zr     *gzip.Reader
+	pr     *plainReader
+	start  int64
+	first  bool
+	offset int64
+	length int64
+}
+
+func newMemberReader(br *bufio.Reader) *memberReader {
+	return &memberReader{cr: &countingReader{br: br}, offset: -1, length: -1}
+}
+
+func (r *memberReader) NextRaw() ([]byte, error) {
+	for {
+		if r.pr == nil {
+			r.start = r.cr.n
+			var err error
+			if r.zr == nil {
+				r.zr, err = gzip.NewReader(r.cr)
+			} else {
+				err = r.zr.Reset(r.cr)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			r.zr.Multistream(false)
+			r.pr = &plainReader{r: r.zr, br: bufio.NewReader(r.zr)}
+			r.first = true
+		}
+
+		rec, err := r.pr.NextRaw()
+		if err == io.EOF {
+			r.pr = nil
+			continue
+		}
+
+		r.offset, r.length = -1, -1
+		if r.first {
+			r.offset = r.start
+			r.first = false
+			if err == nil && trackLengths && r.pr.atEOF() {
+				r.length = r.cr.n - r.start
+				r.pr = nil
+			}
+		}
+
+		return rec, err
+	}
+}
+
+func (r *memberReader) Offset() int64 {
+	return r.offset
+}
+
+func (r *memberReader) Length() int64 {
+	return r.length
+}
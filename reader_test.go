@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sebcat/warc"
+)
+
+func warcRecord(fields, block string) string {
+	return fmt.Sprintf("WARC/1.0\r\n%vContent-Length: %v\r\n\r\n%v\r\n\r\n", fields, len(block), block)
+}
+
+// readAll returns the blocks of the records read, with "malformed" for
+// each malformed record
+func readAll(t *testing.T, r recordReader) []string {
+	var got []string
+	for i := 0; i < 100; i++ {
+		rec, err := r.NextRaw()
+		if err == io.EOF {
+			return got
+		} else if err == warc.ErrMalformedRecord {
+			got = append(got, "malformed")
+		} else if err != nil {
+			t.Fatal(err)
+		} else {
+			got = append(got, string(recordBlock(rec)))
+		}
+	}
+
+	t.Fatal("no EOF")
+	return nil
+}
+
+func TestPlainReader(t *testing.T) {
+	rec1 := warcRecord("WARC-Type: request\r\n", "first")
+	rec2 := warcRecord("WARC-Type: response\r\n", "second\r\n\r\nwith blank lines")
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"two records", rec1 + rec2, []string{"first", "second\r\n\r\nwith blank lines"}},
+		{"extra blank lines", "\r\n" + rec1 + "\r\n\n\r\n" + rec2 + "\r\n", []string{"first", "second\r\n\r\nwith blank lines"}},
+		{"LF line endings", "WARC/1.0\nContent-Length: 3\n\nabc\n\n", []string{"abc"}},
+		{"empty block", warcRecord("", "") + rec1, []string{"", "first"}},
+		{
+			"missing Content-Length",
+			"WARC/1.0\r\nWARC-Type: request\r\n\r\nblock\r\n\r\n" + rec1,
+			[]string{"malformed", "first"},
+		},
+		{
+			"garbled Content-Length",
+			"WARC/1.0\r\nContent-Length: 12abc\r\n\r\nblock\r\n\r\n" + rec1,
+			[]string{"malformed", "first"},
+		},
+		{"garbage between records", rec1 + "garbage\r\nmore garbage\r\n" + rec2, []string{"first", "malformed", "second\r\n\r\nwith blank lines"}},
+		{"garbage at start", "junk\r\n" + rec1, []string{"malformed", "first"}},
+		{"truncated header", rec1 + "WARC/1.0\r\nWARC-Type: resp", []string{"first", "malformed"}},
+		{"truncated block", rec1 + warcRecord("", "0123456789")[:40], []string{"first", "malformed"}},
+		{"empty input", "", nil},
+	}
+
+	for _, tt := range tests {
+		r := &plainReader{br: bufio.NewReader(strings.NewReader(tt.in))}
+		if got := readAll(t, r); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"\x1f\x8b\x08\x00\x00\x00", "gzip"},
+		{"\x28\xb5\x2f\xfd\x00\x00", "zstd"},
+		{"\x5d\x2a\x4d\x18\x00\x00", "zstd"}, // WARC-zstd dictionary frame
+		{"\x50\x2a\x4d\x18\x00\x00", "zstd"}, // any skippable frame
+		{"BZh91AY&SY", "bzip2"},
+		{"\xfd7zXZ\x00\x00", "xz"},
+		{"WARC/1.0\r\n", "plain"},
+		{"filedesc://x.arc", "plain"},
+		{"\x1f", "plain"},
+		{"", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := detectFormat(bufio.NewReader(strings.NewReader(tt.in))); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStreamLatency(t *testing.T) {
+	defer func(n int) { *gzipWorkers = n }(*gzipWorkers)
+	for _, tt := range []struct {
+		name    string
+		workers int
+		gz      bool
+	}{{"gzip", 0, true}, {"gzip-workers", 4, true}, {"plain", 0, false}} {
+		*gzipWorkers = tt.workers
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		recs := make(chan *rawRecord)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- readInput(&inputFile{path: "p"}, pr, recs, nil)
+			close(recs)
+		}()
+
+		// a small first record, and the writer pausing after each one
+		for _, url := range []string{"http://a/", "http://b/"} {
+			rec := []byte(targetRecord(url))
+			if tt.gz {
+				rec = gzipped(t, string(rec))
+			}
+
+			pw.Write(rec)
+			select {
+			case rec := <-recs:
+				if got := entryURLs(newResult(rec).entries); len(got) != 1 || got[0] != url {
+					t.Errorf("%v: got %q, want %v", tt.name, got, url)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%v: %v not read before more input", tt.name, url)
+			}
+		}
+
+		pw.Close()
+		if rec, ok := <-recs; ok {
+			t.Errorf("%v: unexpected record %q", tt.name, rec.data)
+		}
+
+		if err := <-errc; err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		}
+
+		pr.Close()
+	}
+}
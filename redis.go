@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func isRedisURL(s string) bool {
+	return strings.HasPrefix(s, "redis://")
+}
+
+// redisTarget is where -out redis://[[user]:password@]host[:port][/db]
+// writes: ?key= names the set (SADD) or, with ?type=stream, the stream
+// (XADD) to add to, trimmed to about ?maxlen= entries if given
+type redisTarget struct {
+	addr, user, password string
+	db                   int
+	key                  string
+	stream               bool
+	maxlen               int64
+}
+
+func parseRedisURL(s string) (*redisTarget, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	} else if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("%v: missing host", s)
+	}
+
+	t := &redisTarget{addr: u.Host, key: "urls"}
+	if len(u.Port()) == 0 {
+		t.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	if u.User != nil {
+		t.user = u.User.Username()
+		t.password, _ = u.User.Password()
+	}
+
+	if db := strings.Trim(u.Path, "/"); len(db) > 0 {
+		if t.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("%v: invalid database %q", s, db)
+		}
+	}
+
+	q := u.Query()
+	if key := q.Get("key"); len(key) > 0 {
+		t.key = key
+	}
+
+	switch q.Get("type") {
+	case "", "set":
+	case "stream":
+		t.stream = true
+	default:
+		return nil, fmt.Errorf("%v: type is set or stream", s)
+	}
+
+	if maxlen := q.Get("maxlen"); len(maxlen) > 0 {
+		if t.maxlen, err = strconv.ParseInt(maxlen, 10, 64); err != nil || t.maxlen <= 0 {
+			return nil, fmt.Errorf("%v: invalid maxlen", s)
+		}
+	}
+
+	return t, nil
+}
+
+// redisWriter adds entries with pipelined commands: up to redisBatch are
+// sent before their replies are read
+type redisWriter struct {
+	t       *redisTarget
+	conn    net.Conn
+	w       *bufio.Writer
+	r       *bufio.Reader
+	pending int
+}
+
+const redisBatch = 1000
+
+func newRedisWriter(s string) (*redisWriter, error) {
+	t, err := parseRedisURL(s)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", t.addr, 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &redisWriter{t: t, conn: conn, w: bufio.NewWriter(conn), r: bufio.NewReader(conn)}
+	if len(t.password) > 0 {
+		if len(t.user) > 0 {
+			w.command("AUTH", t.user, t.password)
+		} else {
+			w.command("AUTH", t.password)
+		}
+	}
+
+	if t.db != 0 {
+		w.command("SELECT", strconv.Itoa(t.db))
+	}
+
+	if err := w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// command queues a command, as an array of bulk strings
+func (w *redisWriter) command(args ...string) {
+	fmt.Fprintf(w.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	w.pending++
+}
+
+func (w *redisWriter) Write(e *entry) error {
+	if !w.t.stream {
+		w.command("SADD", w.t.key, e.URL)
+	} else {
+		args := []string{"XADD", w.t.key}
+		if w.t.maxlen > 0 {
+			args = append(args, "MAXLEN", "~", strconv.FormatInt(w.t.maxlen, 10))
+		}
+
+		args = append(args, "*", "url", e.URL)
+		for _, f := range []string{"from", "type", "date", "record_id", "digest", "mime"} {
+			if v := entryFields[f](e); len(v) > 0 {
+				args = append(args, f, v)
+			}
+		}
+
+		if e.Status > 0 {
+			args = append(args, "status", strconv.Itoa(e.Status))
+		}
+
+		w.command(args...)
+	}
+
+	if w.pending >= redisBatch {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush sends the queued commands and reads their replies, returning the
+// first error reply
+func (w *redisWriter) Flush() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+
+	var firstErr error
+	for ; w.pending > 0; w.pending-- {
+		if err := w.readReply(); err != nil {
+			var rerr redisError
+			if !errors.As(err, &rerr) {
+				return err
+			} else if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply reads a reply, which is an error for error replies
+func (w *redisWriter) readReply() error {
+	line, err := w.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		} else if n >= 0 {
+			_, err = w.r.Discard(n + 2)
+		}
+
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return err
+		}
+
+		var first error
+		for i := 0; i < n; i++ {
+			err := w.readReply()
+			var rerr redisError
+			if err != nil && !errors.As(err, &rerr) {
+				return err
+			} else if first == nil {
+				first = err
+			}
+		}
+
+		return first
+	}
+
+	return fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (w *redisWriter) Close() error {
+	err := w.Flush()
+	if cerr := w.conn.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseRedisURL(t *testing.T) {
+	tests := []struct {
+		s    string
+		want redisTarget
+	}{
+		{"redis://localhost", redisTarget{addr: "localhost:6379", key: "urls"}},
+		{"redis://:secret@r1:6380/2?key=seen", redisTarget{addr: "r1:6380", password: "secret", db: 2, key: "seen"}},
+		{"redis://u:p@r1/?type=stream&maxlen=1000", redisTarget{addr: "r1:6379", user: "u", password: "p", key: "urls", stream: true, maxlen: 1000}},
+	}
+
+	for _, test := range tests {
+		got, err := parseRedisURL(test.s)
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+		} else if *got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.s, *got, test.want)
+		}
+	}
+
+	for _, bad := range []string{"redis://", "redis://r1/x", "redis://r1?type=list", "redis://r1?maxlen=0"} {
+		if _, err := parseRedisURL(bad); err == nil {
+			t.Errorf("%q parsed", bad)
+		}
+	}
+}
+
+// fakeRedis records the commands it gets, and replies with an error to
+// commands with an argument of "fail"
+type fakeRedis struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeRedis{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.serve(conn)
+		}
+	}()
+
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		cmd, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+		s.mu.Unlock()
+		switch {
+		case hasString(cmd, "fail"):
+			fmt.Fprintf(conn, "-ERR failed\r\n")
+		case cmd[0] == "XADD":
+			fmt.Fprintf(conn, "$3\r\n1-0\r\n")
+		case cmd[0] == "SADD":
+			fmt.Fprintf(conn, ":1\r\n")
+		default:
+			fmt.Fprintf(conn, "+OK\r\n")
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+
+	cmd := make([]string, n)
+	for i := range cmd {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+
+		cmd[i] = string(data[:size])
+	}
+
+	return cmd, nil
+}
+
+func (s *fakeRedis) take() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cmds := s.commands
+	s.commands = nil
+	return cmds
+}
+
+func TestRedisWriter(t *testing.T) {
+	s := newFakeRedis(t)
+	addr := s.ln.Addr().String()
+	w, err := newRedisWriter("redis://u:p@" + addr + "/3?key=seen")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write(&entry{URL: "http://a/"})
+	w.Write(&entry{URL: "http://b/"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"AUTH", "u", "p"},
+		{"SELECT", "3"},
+		{"SADD", "seen", "http://a/"},
+		{"SADD", "seen", "http://b/"},
+	}
+
+	if got := s.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	w, err = newRedisWriter("redis://" + addr + "?type=stream&maxlen=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write(&entry{URL: "http://a/", Type: "response", Status: 200})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want = [][]string{{"XADD", "urls", "MAXLEN", "~", "100", "*", "url", "http://a/", "type", "response", "status", "200"}}
+	if got := s.take(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRedisWriterBatches(t *testing.T) {
+	s := newFakeRedis(t)
+	w, err := newRedisWriter("redis://" + s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer w.Close()
+	for i := 0; i < redisBatch+1; i++ {
+		url := fmt.Sprintf("http://a/%d", i)
+		if i == 10 {
+			url = "fail"
+		}
+
+		err := w.Write(&entry{URL: url})
+		if i < redisBatch-1 && err != nil || i == redisBatch-1 && err == nil {
+			t.Fatalf("write %v: %v", i, err)
+		}
+	}
+
+	// the replies of the first batch have all been read
+	if w.pending != 1 {
+		t.Errorf("%v pending", w.pending)
+	} else if err := w.Flush(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/sebcat/warc"
+)
+
+var (
+	robotsMode  = flag.String("robots", "", "exclude, or flag with \"robots_disallowed\": true in jsonl, the URLs disallowed by the robots.txt captures of the inputs, which are read twice")
+	robotsAgent = flag.String("robots-agent", "*", "the user-agent of -robots")
+)
+
+// the most of a robots.txt that's parsed, as by RFC 9309
+const robotsMaxSize = 500 << 10
+
+// the robots.txt of the inputs, for -robots, nil until collected
+var robots *robotsIndex
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsIndex holds the rules for an agent of the robots.txt captured of
+// each origin, the latest capture if there are several
+type robotsIndex struct {
+	agent string
+	mu    sync.Mutex
+	rules map[string][]robotsRule // by origin
+	dates map[string]string       // WARC-Date of the rules
+}
+
+func newRobotsIndex(agent string) *robotsIndex {
+	return &robotsIndex{
+		agent: agent,
+		rules: make(map[string][]robotsRule),
+		dates: make(map[string]string),
+	}
+}
+
+// collectRobots reads the inputs for their robots.txt captures
+func collectRobots(inputs []*inputFile, agent string, nconcurrent int) *robotsIndex {
+	idx := newRobotsIndex(agent)
+	recs := make(chan *rawRecord, nconcurrent)
+	go readRecords(inputs, recs, nil, nil)
+
+	var wg sync.WaitGroup
+	wg.Add(nconcurrent)
+	for i := 0; i < nconcurrent; i++ {
+		go func() {
+			defer wg.Done()
+			for rec := range recs {
+				if rec.data != nil {
+					idx.addRecord(rec.data)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	return idx
+}
+
+// addRecord adds the rules of rec if it's a robots.txt response. Those
+// that couldn't be fetched allow all, and those that failed with a server
+// error disallow all.
+func (idx *robotsIndex) addRecord(rec []byte) {
+	var r warc.Record
+	if err := r.FromBytes(rec); err != nil || !strings.EqualFold(strings.TrimSpace(r.Fields.Value("WARC-Type")), "response") {
+		return
+	}
+
+	u, err := url.Parse(strings.TrimSpace(r.Fields.Value("WARC-Target-URI")))
+	if err != nil || u.EscapedPath() != "/robots.txt" {
+		return
+	}
+
+	origin := robotsOrigin(u)
+	if len(origin) == 0 {
+		return
+	}
+
+	status, body, err := httpBody(recordBlock(rec), robotsMaxSize)
+	if err != nil {
+		slog.Warn("robots", "url", u.String(), "err", err)
+		return
+	}
+
+	var rules []robotsRule
+	switch {
+	case status/100 == 2:
+		rules = parseRobots(body, idx.agent)
+	case status/100 == 5:
+		rules = []robotsRule{{pattern: "/"}}
+	case status/100 != 4:
+		// e.g. a redirect, which isn't followed
+		return
+	}
+
+	date := strings.TrimSpace(r.Fields.Value("WARC-Date"))
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if last, ok := idx.dates[origin]; !ok || date >= last {
+		idx.rules[origin], idx.dates[origin] = rules, date
+	}
+}
+
+// allowed tells whether the agent may fetch rawURL, which it may if its
+// origin has no robots.txt captured
+func (idx *robotsIndex) allowed(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+
+	origin := robotsOrigin(u)
+	idx.mu.Lock()
+	rules, ok := idx.rules[origin]
+	idx.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	p := u.EscapedPath()
+	if len(p) == 0 {
+		p = "/"
+	} else if p == "/robots.txt" {
+		return true
+	}
+
+	if len(u.RawQuery) > 0 {
+		p += "?" + u.RawQuery
+	}
+
+	return robotsAllowed(rules, p)
+}
+
+// robotsOrigin returns the scheme://host[:port] of a http(s) URL, without
+// the default port, or an empty string for other URLs
+func robotsOrigin(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" || len(u.Host) == 0 {
+		return ""
+	}
+
+	host := strings.ToLower(u.Host)
+	if port := u.Port(); scheme == "http" && port == "80" || scheme == "https" && port == "443" {
+		host = strings.TrimSuffix(host, ":"+port)
+	}
+
+	return scheme + "://" + host
+}
+
+// parseRobots returns the rules of the groups of a robots.txt matching
+// agent, or of the * groups if none does
+func parseRobots(data []byte, agent string) []robotsRule {
+	token := strings.ToLower(agent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var specific, star []robotsRule
+	var hasSpecific, inAgents, isSpecific, isStar bool
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				isSpecific, isStar = false, false
+			}
+
+			inAgents = true
+			if v := strings.ToLower(value); v == "*" {
+				isStar = true
+			} else if len(v) > 0 && token != "*" && (v == token || strings.HasPrefix(v, token+"/")) {
+				isSpecific, hasSpecific = true, true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if len(value) == 0 {
+				// an empty disallow allows all, as does no rule
+				continue
+			}
+
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			if isSpecific {
+				specific = append(specific, rule)
+			}
+
+			if isStar {
+				star = append(star, rule)
+			}
+		}
+	}
+
+	if hasSpecific {
+		return specific
+	}
+
+	return star
+}
+
+// robotsAllowed tells whether the rules allow path, by the longest
+// matching rule, allow if they're as long
+func robotsAllowed(rules []robotsRule, path string) bool {
+	allow, longest := true, -1
+	for _, r := range rules {
+		if n := len(r.pattern); robotsMatch(r.pattern, path) && (n > longest || n == longest && r.allow) {
+			allow, longest = r.allow, n
+		}
+	}
+
+	return allow
+}
+
+// robotsMatch tells whether pattern, with * for any characters and a
+// trailing $ for the end of the path, is a prefix of path
+func robotsMatch(pattern, path string) bool {
+	if strings.HasSuffix(pattern, "$") {
+		pattern = pattern[:len(pattern)-1]
+	} else {
+		pattern += "*"
+	}
+
+	// the pattern from star matches the path from next, if any star
+	star, next := -1, 0
+	for p, s := 0, 0; s < len(path) || p < len(pattern); {
+		if p < len(pattern) && pattern[p] == '*' {
+			star, next = p, s
+			p++
+		} else if p < len(pattern) && s < len(path) && pattern[p] == path[s] {
+			p++
+			s++
+		} else if star >= 0 && next < len(path) {
+			next++
+			p, s = star+1, next
+		} else {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRobotsMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"/", "/a", true},
+		{"/fish", "/fish.html", true},
+		{"/fish", "/Fish", false},
+		{"/fish/", "/fish", false},
+		{"/*.php", "/a/b.php?x=1", true},
+		{"/*.php$", "/a/b.php?x=1", false},
+		{"/*.php$", "/a/b.php", true},
+		{"/a*b*c", "/aXbYc/d", true},
+		{"/a*b*c", "/aXcYb", false},
+		{"/$", "/", true},
+		{"/$", "/a", false},
+	} {
+		if got := robotsMatch(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("robotsMatch(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	data := []byte(`# comment
+User-agent: *
+Disallow: /private
+Allow: /private/ok
+
+User-agent: OtherBot
+User-agent: MyBot
+Disallow: /  # all
+Allow: /public$
+Disallow:
+
+Sitemap: http://a/sitemap.xml
+`)
+
+	for _, tt := range []struct {
+		agent, path string
+		want        bool
+	}{
+		{"*", "/", true},
+		{"*", "/private/x", false},
+		{"*", "/private/ok/x", true},
+		{"SomeBot", "/private", false},
+		{"mybot/2.0", "/private/ok", false},
+		{"MyBot", "/public", true},
+		{"MyBot", "/public/x", false},
+	} {
+		if got := robotsAllowed(parseRobots(data, tt.agent), tt.path); got != tt.want {
+			t.Errorf("%v %v: got %v, want %v", tt.agent, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsIndex(t *testing.T) {
+	response := func(url, date, http string) []byte {
+		return []byte(warcRecord("WARC-Type: response\r\nWARC-Target-URI: "+url+"\r\nWARC-Date: "+date+"\r\n"+
+			"Content-Type: application/http; msgtype=response\r\n", http))
+	}
+
+	idx := newRobotsIndex("*")
+	idx.addRecord(response("http://a/robots.txt", "2024-01-02T00:00:00Z",
+		"HTTP/1.1 200 OK\r\nContent-Length: 26\r\n\r\nUser-agent: *\nDisallow: /x"))
+	idx.addRecord(response("http://a/robots.txt", "2024-01-01T00:00:00Z",
+		"HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n"))
+	idx.addRecord(response("https://b:443/robots.txt", "2024-01-01T00:00:00Z",
+		"HTTP/1.1 503 Service Unavailable\r\nContent-Length: 0\r\n\r\n"))
+	idx.addRecord(response("http://c/robots.txt", "2024-01-01T00:00:00Z",
+		"HTTP/1.1 404 Not Found\r\nContent-Length: 0\r\n\r\n"))
+	idx.addRecord(response("http://d/robots.txt", "2024-01-01T00:00:00Z",
+		"HTTP/1.1 301 Moved Permanently\r\nLocation: http://e/robots.txt\r\nContent-Length: 0\r\n\r\n"))
+
+	for url, want := range map[string]bool{
+		"http://a/":            true,
+		"http://A:80/x?y":      false,
+		"http://a/robots.txt":  true,
+		"https://a/x":          true,
+		"https://b/":           false,
+		"https://b/robots.txt": true,
+		"http://c/x":           true,
+		"http://d/x":           true,
+		"http://e/x":           true,
+	} {
+		if got := idx.allowed(url); got != want {
+			t.Errorf("%v: got %v, want %v", url, got, want)
+		}
+	}
+
+	if _, ok := idx.rules["http://d"]; ok {
+		t.Error("rules of a redirect")
+	}
+}
+
+func TestRobotsFilter(t *testing.T) {
+	defer func() { robots = nil }()
+	robots = newRobotsIndex("*")
+	robots.rules["http://a"] = []robotsRule{{pattern: "/x"}}
+	entries := func() []*entry { return []*entry{{URL: "http://a/x"}, {URL: "http://a/y"}} }
+
+	withFilters(t, func() { *robotsMode = "exclude" })
+	if got := entryURLs(filterEntries(entries())); len(got) != 1 || got[0] != "http://a/y" {
+		t.Errorf("exclude: got %v", got)
+	}
+
+	withFilters(t, func() { *robotsMode = "flag" })
+	if got := filterEntries(entries()); len(got) != 2 || !got[0].RobotsDisallowed || got[1].RobotsDisallowed {
+		t.Errorf("flag: got %+v %+v", got[0], got[1])
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+var (
+	s3Region   = flag.String("s3-region", "", "S3 region (default from the AWS config, or us-east-1)")
+	s3Endpoint = flag.String("s3-endpoint", "", "S3-compatible endpoint URL, uses path-style requests")
+)
+
+var (
+	s3ClientOnce sync.Once
+	s3Client     *s3.Client
+	s3ClientErr  error
+)
+
+// getS3Client returns a client of the default AWS config, with the
+// credentials and region of the environment, the shared config and
+// credentials files, SSO, web identity or the ECS or EC2 role. Without
+// credentials, requests are sent unsigned (works for public buckets).
+func getS3Client() (*s3.Client, error) {
+	s3ClientOnce.Do(func() {
+		ctx := context.Background()
+		opts := []func(*config.LoadOptions) error{
+			// the timeouts of getHTTPClient, on a client the SDK can add
+			// the AWS_CA_BUNDLE to
+			config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+				tr.Proxy = http.ProxyFromEnvironment
+				tr.DialContext = (&net.Dialer{Timeout: *httpTimeout}).DialContext
+				tr.TLSHandshakeTimeout = *httpTimeout
+				tr.ResponseHeaderTimeout = *httpTimeout
+				tr.DisableCompression = true
+			})),
+			config.WithRetryMaxAttempts(*httpRetries + 1),
+		}
+
+		if len(*s3Region) > 0 {
+			opts = append(opts, config.WithRegion(*s3Region))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			s3ClientErr = err
+			return
+		} else if len(cfg.Region) == 0 {
+			cfg.Region = "us-east-1"
+		}
+
+		if cfg.Credentials == nil {
+			cfg.Credentials = aws.AnonymousCredentials{}
+		} else if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			slog.Debug("no AWS credentials, S3 requests are unsigned", "err", err)
+			cfg.Credentials = aws.AnonymousCredentials{}
+		}
+
+		s3Client = s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if len(*s3Endpoint) > 0 {
+				o.BaseEndpoint = aws.String(*s3Endpoint)
+				o.UsePathStyle = true
+				// S3-compatible stores may not support the newer checksums
+				o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+				o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+			}
+		})
+	})
+
+	return s3Client, s3ClientErr
+}
+
+// s3Bucket addresses buckets with dots in their names by path, since
+// their virtual hosts don't match the *.s3.amazonaws.com certificate
+func s3Bucket(bucket string) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if strings.Contains(bucket, ".") {
+			o.UsePathStyle = true
+		}
+	}
+}
+
+func isS3(name string) bool {
+	return strings.HasPrefix(name, "s3://")
+}
+
+func parseS3(name string) (bucket, key string, err error) {
+	rest := strings.TrimPrefix(name, "s3://")
+	bucket, key, _ = strings.Cut(rest, "/")
+	if len(bucket) == 0 {
+		return "", "", fmt.Errorf("%v: missing bucket", name)
+	}
+
+	return bucket, key, nil
+}
+
+// a key that is empty or ends in / is a prefix to list
+func isS3Prefix(name string) bool {
+	_, key, err := parseS3(name)
+	return err == nil && (len(key) == 0 || strings.HasSuffix(key, "/"))
+}
+
+// s3Error returns the HTTP status errors of S3 as httpStatusErrors, so
+// that those that are permanent aren't retried
+func s3Error(err error) error {
+	var resp *awshttp.ResponseError
+	if errors.As(err, &resp) {
+		code := resp.HTTPStatusCode()
+		return &httpStatusError{status: strconv.Itoa(code) + " " + http.StatusText(code), code: code}
+	}
+
+	return err
+}
+
+// openS3 streams an object like an httpReader, reissuing the GET from the
+// current offset when it fails mid-stream
+func openS3(name string, offset, length int64) (io.ReadCloser, error) {
+	bucket, key, err := parseS3(name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := getS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &httpReader{url: name}
+	r.fetch = func(ctx context.Context, offset, end int64) (io.ReadCloser, int64, error) {
+		in := &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}
+		if end >= 0 {
+			in.Range = aws.String(fmt.Sprintf("bytes=%d-%d", offset, end-1))
+		} else if offset > 0 {
+			in.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		out, err := client.GetObject(ctx, in, s3Bucket(bucket), func(o *s3.Options) {
+			// retried by the httpReader
+			o.RetryMaxAttempts = 1
+		})
+		if err != nil {
+			return nil, -1, s3Error(err)
+		}
+
+		size := int64(-1)
+		if out.ContentRange != nil {
+			// bytes first-last/size
+			if _, total, ok := strings.Cut(*out.ContentRange, "/"); ok {
+				if n, err := strconv.ParseInt(total, 10, 64); err == nil {
+					size = n
+				}
+			}
+		} else if out.ContentLength != nil {
+			size = offset + *out.ContentLength
+		}
+
+		return out.Body, size, nil
+	}
+
+	if err := r.open(offset, length); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// listS3 returns the WARC objects under an s3://bucket/prefix/ in key order
+func listS3(name string) ([]string, error) {
+	bucket, prefix, err := parseS3(name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := getS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.Background(), s3Bucket(bucket))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, obj := range page.Contents {
+			if key := aws.ToString(obj.Key); hasWARCExt(key) {
+				names = append(names, "s3://"+bucket+"/"+key)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+func s3ModTime(name string) (time.Time, error) {
+	bucket, key, err := parseS3(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	client, err := getS3Client()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *httpTimeout)
+	defer cancel()
+	out, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3Bucket(bucket))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return aws.ToTime(out.LastModified), nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestOpenS3(t *testing.T) {
+	fake := withFakeS3(t)
+	data := strings.Repeat("0123456789", 100)
+	fake.objects["/bucket/a b+c/å.warc.gz"] = data
+
+	for _, tt := range []struct {
+		offset, length int64
+		cut            int
+		want           string
+	}{
+		{0, -1, 0, data},
+		{10, 5, 0, data[10:15]},
+		{990, -1, 0, data[990:]},
+		// reissued from where it was dropped
+		{0, -1, 300, data},
+		{100, 500, 50, data[100:600]},
+	} {
+		fake.cut = tt.cut
+		r, err := openS3("s3://bucket/a b+c/å.warc.gz", tt.offset, tt.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Errorf("%v+%v: %v", tt.offset, tt.length, err)
+		} else if string(got) != tt.want {
+			t.Errorf("%v+%v: got %v bytes, want %v", tt.offset, tt.length, len(got), len(tt.want))
+		}
+	}
+
+	if _, err := openS3("s3://bucket/missing.warc.gz", 0, -1); err == nil {
+		t.Error("opened a missing object")
+	} else if serr, ok := err.(*httpStatusError); !ok || serr.code != 404 {
+		t.Errorf("got %v, want a 404", err)
+	}
+}
+
+func TestListS3(t *testing.T) {
+	fake := withFakeS3(t)
+	for _, key := range []string{"crawl/a.warc.gz", "crawl/b.txt", "crawl/c.warc", "crawl/d/e.arc.gz", "other/f.warc.gz"} {
+		fake.objects["/bucket/"+key] = "x"
+	}
+
+	names, err := listS3("s3://bucket/crawl/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"s3://bucket/crawl/a.warc.gz", "s3://bucket/crawl/c.warc", "s3://bucket/crawl/d/e.arc.gz"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+func TestS3ModTime(t *testing.T) {
+	fake := withFakeS3(t)
+	fake.objects["/bucket/a.warc.gz"] = "x"
+	got, err := s3ModTime("s3://bucket/a.warc.gz")
+	if err != nil {
+		t.Fatal(err)
+	} else if want := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestS3Bucket(t *testing.T) {
+	for bucket, want := range map[string]bool{"bucket": false, "my.bucket.example": true} {
+		var o s3.Options
+		s3Bucket(bucket)(&o)
+		if o.UsePathStyle != want {
+			t.Errorf("%v: path style %v, want %v", bucket, o.UsePathStyle, want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var s3PartSize = flag.String("s3-part-size", "16M", "size of the parts of multipart uploads to an -o s3://bucket/key, at least 5M, kept in memory")
+
+// set from -s3-part-size
+var s3PartBytes int64 = 16 << 20
+
+const s3MinPartSize = 5 << 20
+
+// s3Upload writes an object with a multipart upload, a part at a time, so
+// that nothing is kept on disk. Like an atomicFile, the object is only
+// created once committed.
+type s3Upload struct {
+	client      *s3.Client
+	bucket, key string
+	id          string // of the upload, once started
+	buf         bytes.Buffer
+	parts       []types.CompletedPart // uploaded
+}
+
+func createS3Upload(name string) (*s3Upload, error) {
+	bucket, key, err := parseS3(name)
+	if err != nil {
+		return nil, err
+	} else if isS3Prefix(name) {
+		return nil, fmt.Errorf("%v: missing key", name)
+	}
+
+	client, err := getS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Upload{client: client, bucket: bucket, key: key}, nil
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	u.buf.Write(p)
+	for int64(u.buf.Len()) >= s3PartBytes {
+		if err := u.uploadPart(u.buf.Next(int(s3PartBytes))); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+func (u *s3Upload) uploadPart(data []byte) error {
+	ctx := context.Background()
+	if len(u.id) == 0 {
+		out, err := u.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+			Bucket: aws.String(u.bucket),
+			Key:    aws.String(u.key),
+		}, s3Bucket(u.bucket))
+		if err != nil {
+			return err
+		} else if len(aws.ToString(out.UploadId)) == 0 {
+			return fmt.Errorf("s3://%v/%v: no upload ID", u.bucket, u.key)
+		}
+
+		u.id = *out.UploadId
+	}
+
+	n := int32(len(u.parts) + 1)
+	out, err := u.client.UploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(u.bucket),
+		Key:        aws.String(u.key),
+		UploadId:   aws.String(u.id),
+		PartNumber: aws.Int32(n),
+		Body:       bytes.NewReader(data),
+	}, s3Bucket(u.bucket))
+	if err != nil {
+		return err
+	}
+
+	u.parts = append(u.parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(n)})
+	return nil
+}
+
+// Commit uploads the rest of the object. An object smaller than a part is
+// uploaded as is.
+func (u *s3Upload) Commit() error {
+	ctx := context.Background()
+	if len(u.id) == 0 {
+		_, err := u.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket: aws.String(u.bucket),
+			Key:    aws.String(u.key),
+			Body:   bytes.NewReader(u.buf.Bytes()),
+		}, s3Bucket(u.bucket))
+		return err
+	}
+
+	if u.buf.Len() > 0 {
+		if err := u.uploadPart(u.buf.Bytes()); err != nil {
+			u.Abort()
+			return err
+		}
+	}
+
+	// the SDK also fails the completions that fail after their 200 OK
+	_, err := u.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(u.bucket),
+		Key:             aws.String(u.key),
+		UploadId:        aws.String(u.id),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: u.parts},
+	}, s3Bucket(u.bucket))
+	if err != nil {
+		u.Abort()
+	}
+
+	return err
+}
+
+// Abort aborts the upload, so that its parts aren't kept (and billed)
+func (u *s3Upload) Abort() {
+	if len(u.id) == 0 {
+		return
+	}
+
+	if _, err := u.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(u.bucket),
+		Key:      aws.String(u.key),
+		UploadId: aws.String(u.id),
+	}, s3Bucket(u.bucket)); err != nil {
+		slog.Error("s3Upload abort", "bucket", u.bucket, "key", u.key, "err", err)
+	}
+
+	u.id = ""
+}
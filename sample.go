@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+)
+
+var (
+	sampleRate = flag.Float64("sample", 0, "only output the URLs of a random sample of the records, each kept with this probability, e.g. 0.01")
+	sampleN    = flag.Int("sample-n", 0, "only output the URLs of a uniform random sample of this many records, written once all have been read")
+	sampleSeed = flag.Uint64("sample-seed", 0, "seed of -sample and -sample-n, for another sample of the same inputs")
+)
+
+// sampled tells whether the record of e is in the -sample of rate, by a
+// hash of its WARC-Record-ID, so that all its entries are, whatever the
+// order the records are processed in
+func sampled(e *entry, rate float64, seed uint64) bool {
+	key := e.RecordID
+	if len(key) == 0 {
+		key = e.File + "\x00" + strconv.FormatInt(e.Offset, 10) + "\x00" + e.URL
+	}
+
+	h := fnv.New64a()
+	binary.Write(h, binary.LittleEndian, seed)
+	io.WriteString(h, key)
+	return float64(h.Sum64()>>11)/(1<<53) < rate
+}
+
+// reservoir is a uniform random sample of n results, by Algorithm R
+type reservoir struct {
+	n       int
+	seen    int
+	rng     *rand.Rand
+	results []*result
+	order   []int // of the results, by when they were seen
+}
+
+func newReservoir(n int, seed uint64) *reservoir {
+	return &reservoir{n: n, rng: rand.New(rand.NewPCG(seed, math.MaxUint64-seed))}
+}
+
+func (r *reservoir) add(res *result) {
+	r.seen++
+	if len(r.results) < r.n {
+		r.results = append(r.results, res)
+		r.order = append(r.order, r.seen)
+	} else if i := r.rng.IntN(r.seen); i < r.n {
+		r.results[i], r.order[i] = res, r.seen
+	}
+}
+
+// sample returns the results of the sample, in the order they were seen
+func (r *reservoir) sample() []*result {
+	sort.Sort(r)
+	return r.results
+}
+
+func (r *reservoir) Len() int           { return len(r.results) }
+func (r *reservoir) Less(i, j int) bool { return r.order[i] < r.order[j] }
+func (r *reservoir) Swap(i, j int) {
+	r.results[i], r.results[j] = r.results[j], r.results[i]
+	r.order[i], r.order[j] = r.order[j], r.order[i]
+}
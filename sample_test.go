@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSampled(t *testing.T) {
+	n := 0
+	for i := 0; i < 10000; i++ {
+		e := &entry{RecordID: fmt.Sprintf("<urn:uuid:%v>", i)}
+		if sampled(e, 0.1, 0) {
+			n++
+		}
+
+		var again = &entry{RecordID: e.RecordID, URL: "http://other/"}
+		if sampled(e, 0.1, 0) != sampled(again, 0.1, 0) {
+			t.Fatalf("%v: not sampled alike", e.RecordID)
+		}
+	}
+
+	if n < 900 || n > 1100 {
+		t.Errorf("got %v of 10000 at 0.1", n)
+	}
+
+	differ := false
+	for i := 0; i < 100 && !differ; i++ {
+		e := &entry{RecordID: fmt.Sprint(i)}
+		differ = sampled(e, 0.5, 1) != sampled(e, 0.5, 2)
+	}
+
+	if !differ {
+		t.Error("same sample of different seeds")
+	}
+}
+
+func TestReservoir(t *testing.T) {
+	counts := make([]int, 10)
+	for seed := uint64(0); seed < 1000; seed++ {
+		r := newReservoir(3, seed)
+		for i := range counts {
+			r.add(&result{rec: &rawRecord{seq: i}})
+		}
+
+		sample := r.sample()
+		if len(sample) != 3 {
+			t.Fatalf("got %v results, want 3", len(sample))
+		}
+
+		for i, res := range sample {
+			if i > 0 && res.rec.seq <= sample[i-1].rec.seq {
+				t.Fatalf("seed %v: sample out of order", seed)
+			}
+
+			counts[res.rec.seq]++
+		}
+	}
+
+	// each is sampled 300 times of 1000, give or take
+	for i, n := range counts {
+		if n < 220 || n > 380 {
+			t.Errorf("result %v sampled %v times of 1000", i, n)
+		}
+	}
+}
+
+func TestResultWriterSampleN(t *testing.T) {
+	var out bytes.Buffer
+	w := newResultWriter(newEntryWriter("text", &out))
+	w.sample = newReservoir(2, 0)
+	for i, urls := range [][]string{{"http://a/"}, {}, {"http://b/", "http://c/"}, {"http://a/"}, {"http://d/"}} {
+		r := &result{rec: &rawRecord{in: &inputFile{}, seq: i, data: []byte("r"), offset: -1}}
+		r.add(&entry{}, "", urls)
+		w.add(r)
+	}
+
+	if out.Len() > 0 {
+		t.Fatalf("written before flushSample: %q", out.String())
+	}
+
+	w.flushSample()
+	urls := strings.Fields(out.String())
+	if len(urls) == 0 || len(urls) > 3 {
+		t.Errorf("got %q of two records", urls)
+	}
+}
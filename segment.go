@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sebcat/warc"
+)
+
+// segment records whether r is part of a segmented record. The first
+// segment has a WARC-Segment-Number of 1 and the others are continuation
+// records referring to it by WARC-Segment-Origin-ID. The last one has a
+// WARC-Segment-Total-Length.
+func (res *result) segment(r *warc.Record) {
+	if r.Fields.Value("WARC-Type") == "continuation" {
+		res.origin = strings.TrimSpace(r.Fields.Value("WARC-Segment-Origin-ID"))
+		res.continuation = len(res.origin) > 0
+		res.lastSegment = len(r.Fields.Value("WARC-Segment-Total-Length")) > 0
+	} else if strings.TrimSpace(r.Fields.Value("WARC-Segment-Number")) == "1" {
+		res.origin = strings.TrimSpace(r.Fields.Value("WARC-Record-ID"))
+	}
+}
+
+// addSegment tells whether the URLs of res should be written. Those of a
+// continuation record are the origin's, so they are only written if the
+// origin wasn't seen, e.g. because it's in an input that wasn't given.
+// Results are added in input order, so segments in later inputs are
+// matched against origins in earlier ones.
+func (w *resultWriter) addSegment(res *result) bool {
+	if len(res.origin) == 0 {
+		return true
+	} else if !res.continuation {
+		var x struct{}
+		w.segments[res.origin] = x
+		return true
+	}
+
+	_, seen := w.segments[res.origin]
+	if res.lastSegment {
+		delete(w.segments, res.origin)
+	}
+
+	return !seen
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	origin := warcRecord("WARC-Type: response\r\nWARC-Record-ID: <urn:uuid:1>\r\n"+
+		"WARC-Segment-Number: 1\r\nWARC-Target-URI: http://a/\r\n", "first")
+	cont := func(id, n, last string) string {
+		fields := "WARC-Type: continuation\r\nWARC-Segment-Origin-ID: " + id + "\r\n" +
+			"WARC-Segment-Number: " + n + "\r\nWARC-Target-URI: http://a/\r\n"
+		if len(last) > 0 {
+			fields += "WARC-Segment-Total-Length: " + last + "\r\n"
+		}
+
+		return warcRecord(fields, "more")
+	}
+
+	// two files of a crawl, with a record split between them
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a-00000.warc")
+	b := filepath.Join(dir, "a-00001.warc")
+	os.WriteFile(a, []byte(targetRecord("http://x/")+origin+cont("<urn:uuid:1>", "2", "")), 0644)
+	os.WriteFile(b, []byte(cont("<urn:uuid:1>", "3", "13")+targetRecord("http://y/")), 0644)
+
+	w := newResultWriter(nil)
+	var urls []string
+	runSegments := func(paths ...string) {
+		var inputs []*inputFile
+		for i, path := range paths {
+			inputs = append(inputs, &inputFile{index: i, path: path})
+		}
+
+		recs := make(chan *rawRecord)
+		go readRecords(inputs, recs, nil, nil)
+		for rec := range recs {
+			res := newResult(rec)
+			if rec.data != nil && w.addSegment(res) {
+				urls = append(urls, entryURLs(res.entries)...)
+			}
+		}
+	}
+
+	runSegments(a, b)
+	if want := []string{"http://x/", "http://a/", "http://y/"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %q, want %q", urls, want)
+	} else if len(w.segments) != 0 {
+		t.Errorf("%v segmented records left", len(w.segments))
+	}
+
+	// the continuation alone still reports the URL
+	urls = nil
+	runSegments(b)
+	if want := []string{"http://a/", "http://y/"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %q, want %q", urls, want)
+	}
+}
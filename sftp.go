@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	sftpMu      sync.Mutex
+	sftpClients = make(map[string]*sftp.Client)
+)
+
+func isSFTP(name string) bool {
+	return strings.HasPrefix(name, "sftp://")
+}
+
+// parseSFTP splits sftp://[user@]host[:port]/path. The user defaults to
+// the current user.
+func parseSFTP(name string) (string, string, string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return "", "", "", err
+	} else if len(u.Host) == 0 {
+		return "", "", "", fmt.Errorf("%v: missing host", name)
+	}
+
+	username := u.User.Username()
+	if len(username) == 0 {
+		cur, err := user.Current()
+		if err != nil {
+			return "", "", "", err
+		}
+
+		username = cur.Username
+	}
+
+	addr := u.Host
+	if len(u.Port()) == 0 {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	return username, addr, u.Path, nil
+}
+
+// sftpClient returns a connection to addr, reusing an earlier one if
+// there is one
+func sftpClient(username, addr string) (*sftp.Client, error) {
+	sftpMu.Lock()
+	defer sftpMu.Unlock()
+	key := username + "@" + addr
+	if c, ok := sftpClients[key]; ok {
+		return c, nil
+	}
+
+	hostKeys, err := knownhosts.New(filepath.Join(sshDir(), "known_hosts"))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            sshAuth(),
+		HostKeyCallback: hostKeys,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// forget the connection when it goes away
+	go func() {
+		conn.Wait()
+		sftpMu.Lock()
+		delete(sftpClients, key)
+		sftpMu.Unlock()
+	}()
+
+	sftpClients[key] = c
+	return c, nil
+}
+
+func sshDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
+
+// sshAuth authenticates with the keys of a running ssh-agent, and with
+// the unencrypted default keys in ~/.ssh
+func sshAuth() []ssh.AuthMethod {
+	var methods []ssh.AuthMethod
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+		pem, err := os.ReadFile(filepath.Join(sshDir(), name))
+		if err != nil {
+			continue
+		}
+
+		if signer, err := ssh.ParsePrivateKey(pem); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	return methods
+}
+
+func openSFTP(name string, offset, length int64) (io.ReadCloser, error) {
+	username, addr, p, err := parseSFTP(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := sftpClient(username, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if length >= 0 {
+		return limitedReadCloser{io.LimitReader(f, length), f}, nil
+	}
+
+	return f, nil
+}
+
+func isSFTPDir(name string) bool {
+	return isSFTP(name) && strings.HasSuffix(name, "/")
+}
+
+// listSFTP returns the WARC files in an sftp:// directory in name order
+func listSFTP(name string) ([]string, error) {
+	username, addr, dir, err := parseSFTP(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := sftpClient(username, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := c.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, fi := range entries {
+		if fi.Mode().IsRegular() && hasWARCExt(fi.Name()) {
+			names = append(names, name+path.Base(fi.Name()))
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no WARC files found")
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/user"
+	"testing"
+)
+
+func TestParseSFTP(t *testing.T) {
+	cur, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name, user, addr, path string
+	}{
+		{"sftp://bob@store1/warcs/a.warc.gz", "bob", "store1:22", "/warcs/a.warc.gz"},
+		{"sftp://bob@store1:2222/a.warc.gz", "bob", "store1:2222", "/a.warc.gz"},
+		{"sftp://store1/warcs/", cur.Username, "store1:22", "/warcs/"},
+		{"sftp://[::1]/a.warc", cur.Username, "[::1]:22", "/a.warc"},
+	}
+
+	for _, tt := range tests {
+		u, addr, p, err := parseSFTP(tt.name)
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		} else if u != tt.user || addr != tt.addr || p != tt.path {
+			t.Errorf("%v: got %q %q %q", tt.name, u, addr, p)
+		}
+	}
+
+	if _, _, _, err := parseSFTP("sftp:///a.warc"); err == nil {
+		t.Error("no error without host")
+	}
+}
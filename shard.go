@@ -0,0 +1,175 @@
+package main
+
+import (
+	"container/list"
+	"flag"
+	"net"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	shardBy    = flag.String("shard-by", "", "write the URLs of each registered domain to its own -o file (host)")
+	shardFiles = flag.Int("shard-files", 256, "maximum number of -shard-by files open at once")
+)
+
+var shardings = []string{"", "host"}
+
+// shardKey returns the registered domain of the URL of e (eTLD+1 by the
+// public suffix list), or its host if it has none, made safe for a file
+// name
+func shardKey(e *entry) string {
+	host := e.host()
+	if len(host) == 0 {
+		return "_"
+	}
+
+	key := host
+	if d := registeredDomain(host); len(d) > 0 {
+		key = d
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+
+		return '_'
+	}, key)
+}
+
+// registeredDomain returns the eTLD+1 of host by the public suffix list,
+// or an empty string for IP addresses and public suffixes
+func registeredDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	d, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return ""
+	}
+
+	return d
+}
+
+// shard is a file of a shardWriter, whose writer is nil while it's
+// suspended
+type shard struct {
+	af   *atomicFile
+	w    entryWriter
+	elem *list.Element
+}
+
+// shardWriter writes entries to a file per shardKey, named after path,
+// e.g. urls-example.com.txt. At most max of them are open at a time, so
+// the least recently used is suspended to open another. All files are
+// committed on Close.
+type shardWriter struct {
+	path   string
+	max    int
+	shards map[string]*shard
+	open   *list.List // of *shard, most recently used first
+}
+
+func newShardWriter(path string, max int) *shardWriter {
+	return &shardWriter{path: path, max: max, shards: make(map[string]*shard), open: list.New()}
+}
+
+func (w *shardWriter) Write(e *entry) error {
+	s, err := w.get(shardKey(e))
+	if err != nil {
+		return err
+	}
+
+	return s.w.Write(e)
+}
+
+// get returns the open shard of key
+func (w *shardWriter) get(key string) (*shard, error) {
+	s, ok := w.shards[key]
+	if ok && s.w != nil {
+		w.open.MoveToFront(s.elem)
+		return s, nil
+	}
+
+	if w.open.Len() >= w.max {
+		if err := w.suspend(w.open.Back().Value.(*shard)); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ok {
+		af, err := createAtomic(suffixName(w.path, key))
+		if err != nil {
+			return nil, err
+		}
+
+		s = &shard{af: af}
+		w.shards[key] = s
+	} else if err := s.af.resume(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if s.w, err = openOutput(s.af); err != nil {
+		return nil, err
+	}
+
+	if ok {
+		if h, isHeader := s.w.(headerWriter); isHeader {
+			h.skipHeader()
+		}
+	}
+
+	s.elem = w.open.PushFront(s)
+	return s, nil
+}
+
+// suspend finishes the output of s and closes its file
+func (w *shardWriter) suspend(s *shard) error {
+	w.open.Remove(s.elem)
+	err := s.w.Close()
+	s.w = nil
+	if serr := s.af.suspend(); err == nil {
+		err = serr
+	}
+
+	return err
+}
+
+// Close finishes the output of every shard, and only then commits them,
+// so that they're all aborted if any fails to be written. A commit that
+// fails aborts those not yet committed.
+func (w *shardWriter) Close() error {
+	var err error
+	for _, s := range w.shards {
+		if s.w != nil {
+			if serr := w.suspend(s); err == nil {
+				err = serr
+			}
+		}
+	}
+
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	for key, s := range w.shards {
+		delete(w.shards, key)
+		if err := s.af.Commit(); err != nil {
+			w.Abort()
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *shardWriter) Abort() {
+	for _, s := range w.shards {
+		s.af.Abort()
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShardKey(t *testing.T) {
+	tests := map[string]string{
+		"http://www.example.com/":      "example.com",
+		"https://a.b.example.co.uk/x":  "example.co.uk",
+		"http://user.github.io/":       "user.github.io",
+		"http://Example.COM:8080/":     "example.com",
+		"http://192.168.0.1/":          "192.168.0.1",
+		"http://[2001:db8::1]:80/":     "2001_db8__1",
+		"http://localhost/":            "localhost",
+		"mailto:someone@example.com":   "_",
+		"http://com/":                  "com",
+		"http://ex%2fample.com/a/../b": "_",
+	}
+
+	for url, want := range tests {
+		if got := shardKey(&entry{URL: url}); got != want {
+			t.Errorf("%q: got %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestShardWriter(t *testing.T) {
+	defer func(format string, fields []string, c string) {
+		outputFormat, outputFields, *compress = format, fields, c
+	}(outputFormat, outputFields, *compress)
+	outputFormat, outputFields, *compress = "csv", []string{"url"}, "gzip"
+
+	dir := t.TempDir()
+	// at most two files open, for three domains
+	w := newShardWriter(filepath.Join(dir, "urls.csv.gz"), 2)
+	urls := []string{"http://a.com/1", "http://b.com/1", "http://www.c.com/1", "http://a.com/2",
+		"http://x.b.com/2", "http://c.com/2", "http://a.com/3"}
+	for _, url := range urls {
+		if err := w.Write(&entry{URL: url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.open.Len() != 2 {
+		t.Errorf("%v files open", w.open.Len())
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"urls-a.com.csv.gz": "url\nhttp://a.com/1\nhttp://a.com/2\nhttp://a.com/3\n",
+		"urls-b.com.csv.gz": "url\nhttp://b.com/1\nhttp://x.b.com/2\n",
+		"urls-c.com.csv.gz": "url\nhttp://www.c.com/1\nhttp://c.com/2\n",
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(want) {
+		t.Errorf("%v files", len(entries))
+	}
+
+	for name, data := range want {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the members written each time the file was open
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := io.ReadAll(zr)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if string(got) != data {
+			t.Errorf("%v: got %q, want %q", name, got, data)
+		}
+	}
+}
+
+// failingWriter fails to finish its output
+type failingWriter struct {
+	entryWriter
+}
+
+func (failingWriter) Close() error {
+	return errors.New("failed")
+}
+
+func TestShardWriterFailure(t *testing.T) {
+	defer func(format string) { outputFormat = format }(outputFormat)
+	outputFormat = "text"
+	dir := t.TempDir()
+	w := newShardWriter(filepath.Join(dir, "urls.txt"), 10)
+	for _, url := range []string{"http://a.com/", "http://b.com/", "http://c.com/"} {
+		if err := w.Write(&entry{URL: url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// none is committed if one fails
+	s := w.shards["b.com"]
+	s.w = failingWriter{s.w}
+	if err := w.Close(); err == nil {
+		t.Fatal("closed")
+	} else if entries, _ := os.ReadDir(dir); len(entries) > 0 {
+		t.Errorf("left %v files", len(entries))
+	}
+}
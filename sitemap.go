@@ -0,0 +1,131 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var sitemapURL = flag.String("sitemap-url", "", "URL the -format sitemap files are served from, for the locations in their index (required)")
+
+// the limits of a sitemap file, leaving room for its last URL, which is
+// at most sitemapMaxURL bytes, and the end of the file. The size is that
+// of the file uncompressed, also when gzipped.
+const (
+	sitemapMaxCount = 50000
+	sitemapMaxSize  = 50<<20 - 16<<10
+	sitemapMaxURL   = 2048
+)
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+// sitemapWriter writes -o parts of up to 50,000 URLs with -format sitemap,
+// and an index of them to -o itself on Close
+type sitemapWriter struct {
+	*rotatingWriter
+	baseURL string
+}
+
+func newSitemapWriter(path, baseURL string) *sitemapWriter {
+	rw := &rotatingWriter{path: path, maxSize: sitemapMaxSize, maxCount: sitemapMaxCount, uncompressed: true}
+	return &sitemapWriter{rw, baseURL}
+}
+
+func (w *sitemapWriter) Close() error {
+	if err := w.rotatingWriter.Close(); err != nil {
+		return err
+	}
+
+	f, err := createOutputFile(w.path)
+	if err != nil {
+		return err
+	}
+
+	base := w.baseURL
+	if len(base) > 0 && !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	// compressed like the parts
+	var out io.Writer = f
+	var zw *gzip.Writer
+	if *compress == "gzip" {
+		zw = gzip.NewWriter(f)
+		out = zw
+	}
+
+	fmt.Fprintf(out, "%v<sitemapindex xmlns=\"%v\">\n", xml.Header, sitemapNS)
+	for part := 1; part <= w.part; part++ {
+		io.WriteString(out, "  <sitemap><loc>")
+		xml.EscapeText(out, []byte(base+path.Base(partName(w.path, part))))
+		io.WriteString(out, "</loc></sitemap>\n")
+	}
+
+	_, err = io.WriteString(out, "</sitemapindex>\n")
+	if err == nil && zw != nil {
+		err = zw.Close()
+	}
+
+	if err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// sitemapPartWriter writes the urlset of a sitemap file. Only http and
+// https URLs can be in one.
+type sitemapPartWriter struct {
+	w      io.Writer
+	header bool
+}
+
+func isSitemapURL(url string) bool {
+	lower := strings.ToLower(url)
+	return len(url) <= sitemapMaxURL &&
+		(strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"))
+}
+
+func (w *sitemapPartWriter) writeHeader() error {
+	if w.header {
+		return nil
+	}
+
+	w.header = true
+	_, err := fmt.Fprintf(w.w, "%v<urlset xmlns=\"%v\">\n", xml.Header, sitemapNS)
+	return err
+}
+
+func (w *sitemapPartWriter) Write(e *entry) error {
+	if !isSitemapURL(e.URL) {
+		return nil
+	} else if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	io.WriteString(w.w, "  <url><loc>")
+	xml.EscapeText(w.w, []byte(e.URL))
+	io.WriteString(w.w, "</loc>")
+	if len(e.Date) > 0 {
+		io.WriteString(w.w, "<lastmod>")
+		xml.EscapeText(w.w, []byte(e.Date))
+		io.WriteString(w.w, "</lastmod>")
+	}
+
+	_, err := io.WriteString(w.w, "</url>\n")
+	return err
+}
+
+func (w *sitemapPartWriter) Close() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w.w, "</urlset>\n")
+	return err
+}
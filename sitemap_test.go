@@ -0,0 +1,94 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSitemapWriter(t *testing.T) {
+	defer func(format string) { outputFormat = format }(outputFormat)
+	outputFormat = "sitemap"
+	dir := t.TempDir()
+	w := newSitemapWriter(filepath.Join(dir, "sitemap.xml"), "https://example.org/maps")
+	w.maxCount = 2
+	for _, e := range []*entry{
+		{URL: "http://a/?x=1&y=2", Date: "2024-01-02T03:04:05Z"},
+		{URL: "https://b/"},
+		{URL: "dns:example.com"},
+		{URL: "http://c/"},
+	} {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return strings.TrimPrefix(string(data), `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	}
+
+	urlset := `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	for name, want := range map[string]string{
+		"sitemap.xml": `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n" +
+			"  <sitemap><loc>https://example.org/maps/sitemap-0001.xml</loc></sitemap>\n" +
+			"  <sitemap><loc>https://example.org/maps/sitemap-0002.xml</loc></sitemap>\n" +
+			"</sitemapindex>\n",
+		"sitemap-0001.xml": urlset +
+			"  <url><loc>http://a/?x=1&amp;y=2</loc><lastmod>2024-01-02T03:04:05Z</lastmod></url>\n" +
+			"  <url><loc>https://b/</loc></url>\n" +
+			"</urlset>\n",
+		"sitemap-0002.xml": urlset + "  <url><loc>http://c/</loc></url>\n</urlset>\n",
+	} {
+		if got := read(name); got != want {
+			t.Errorf("%v: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSitemapGzipSize(t *testing.T) {
+	defer func(format, c string) { outputFormat, *compress = format, c }(outputFormat, *compress)
+	outputFormat, *compress = "sitemap", "gzip"
+	dir := t.TempDir()
+	w := newSitemapWriter(filepath.Join(dir, "sitemap.xml.gz"), "https://example.org/")
+	// a part is full once its uncompressed size is, although its URLs
+	// compress well
+	w.maxSize = 200
+	for i := 0; i < 5; i++ {
+		if err := w.Write(&entry{URL: fmt.Sprintf("http://a/%v/%v", strings.Repeat("x", 100), i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "sitemap.xml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index, _ := io.ReadAll(zr)
+	if n := strings.Count(string(index), "<sitemap>"); n != 5 {
+		t.Errorf("got %v parts, want 5", n)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+)
+
+var soft404 = flag.String("soft-404", "", "flag, with \"soft_404\": true in jsonl, or skip the URLs of 200 responses whose HTML or text looks like an error page")
+
+// the most of a body that's looked at
+const soft404MaxSize = 64 << 10
+
+// bodies of fewer words are looked at as a whole, as those of the title
+// and first heading otherwise
+const soft404MaxWords = 100
+
+var soft404Phrases = []string{"404", "not found", "cannot be found", "can't be found", "could not be found",
+	"does not exist", "doesn't exist", "no longer available", "no longer exists", "page unavailable"}
+
+// isSoft404 tells whether the HTTP response of block, of type mime, looks
+// like an error page: if its title or first heading reads like one, or
+// it's empty or tiny and reads like one anywhere
+func isSoft404(block []byte, mime string) bool {
+	if mime != "text/html" && mime != "application/xhtml+xml" && mime != "text/plain" {
+		return false
+	}
+
+	_, body, err := httpBody(block, soft404MaxSize)
+	if err != nil {
+		return false
+	}
+
+	body = bytes.ToLower(body)
+	if soft404Phrase(tagText(body, "title") + " " + tagText(body, "h1")) {
+		return true
+	}
+
+	words := strings.Fields(stripTags(body))
+	return len(words) == 0 || len(words) < soft404MaxWords && soft404Phrase(strings.Join(words, " "))
+}
+
+func soft404Phrase(s string) bool {
+	for _, p := range soft404Phrases {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tagText returns the text of the first lowercase tag element of html
+func tagText(html []byte, tag string) string {
+	i := bytes.Index(html, []byte("<"+tag))
+	if i < 0 {
+		return ""
+	}
+
+	rest := html[i+1+len(tag):]
+	if len(rest) == 0 || rest[0] != '>' && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' {
+		// e.g. <h1x>, or <titles>
+		return ""
+	}
+
+	text, _, _ := bytes.Cut(rest, []byte("</"+tag))
+	return stripTags(text)
+}
+
+// stripTags returns html without its tags, nor the contents of its
+// script and style elements
+func stripTags(html []byte) string {
+	var b strings.Builder
+	for len(html) > 0 {
+		i := bytes.IndexByte(html, '<')
+		if i < 0 {
+			b.Write(html)
+			break
+		}
+
+		b.Write(html[:i])
+		b.WriteByte(' ')
+		html = html[i:]
+		for _, tag := range []string{"script", "style"} {
+			if bytes.HasPrefix(html, []byte("<"+tag)) {
+				if j := bytes.Index(html, []byte("</"+tag)); j >= 0 {
+					html = html[j:]
+				}
+			}
+		}
+
+		j := bytes.IndexByte(html, '>')
+		if j < 0 {
+			break
+		}
+
+		html = html[j+1:]
+	}
+
+	return b.String()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsSoft404(t *testing.T) {
+	long := strings.Repeat("Lots of words about the thing not found elsewhere. ", 30)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("<html><title>Page Not Found</title></html>"))
+	zw.Close()
+
+	for _, tt := range []struct {
+		name, header, body, mime string
+		want                     bool
+	}{
+		{"title", "", "<html><head><title>404 - Page not found</title></head><body>" + long + "</body></html>", "text/html", true},
+		{"heading", "", "<body><h1 class=x>Sorry, this page doesn't exist</h1>" + long + "</body>", "text/html", true},
+		{"tiny", "", "<body><div><p>The page you requested could not be found.</p></div></body>", "text/html", true},
+		{"empty", "", "<html><body>\n</body></html>", "text/html", true},
+		{"script", "", "<body><script>var x = 'not found';</script><p>Hello there</p></body>", "text/html", false},
+		{"content", "", "<title>Lost and found</title><body>" + long + "</body>", "text/html", false},
+		{"tiny content", "", "<title>Home</title><p>Welcome to the home page.</p>", "text/html", false},
+		{"plain", "", "Not Found", "text/plain", true},
+		{"image", "", "", "image/png", false},
+		{"chunked", "Transfer-Encoding: chunked\r\n", "9\r\nNot Found\r\n0\r\n\r\n", "text/plain", true},
+		{"gzip", fmt.Sprintf("Content-Encoding: gzip\r\nContent-Length: %v\r\n", gz.Len()), gz.String(), "text/html", true},
+	} {
+		block := "HTTP/1.1 200 OK\r\n" + tt.header + "\r\n" + tt.body
+		if got := isSoft404([]byte(block), tt.mime); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSoft404Filter(t *testing.T) {
+	withFilters(t, func() { *soft404 = "skip" })
+	var entries []*entry
+	for i, body := range []string{"<title>Not Found</title>", "<title>Home</title><p>Welcome to the home page.</p>"} {
+		rec := warcRecord(fmt.Sprintf("WARC-Type: response\r\nWARC-Target-URI: http://a/%v\r\n", i)+
+			"Content-Type: application/http; msgtype=response\r\n",
+			"HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"+body)
+		res := newResult(&rawRecord{in: &inputFile{}, data: []byte(rec), offset: -1})
+		entries = append(entries, res.entries...)
+	}
+
+	if got := entryURLs(entries); !reflect.DeepEqual(got, []string{"http://a/1"}) {
+		t.Errorf("got %v", got)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/gob"
+	"flag"
+	"io"
+	"os"
+	"sort"
+)
+
+var (
+	sortOutput = flag.Bool("sort", false, "sort the output by URL (by SURT and timestamp for cdx and cdxj), in byte order, using temporary files in $TMPDIR")
+	sortMem    = flag.String("sort-mem", "256M", "memory to use for -sort before spilling to a temporary file")
+)
+
+// set from -sort-mem
+var sortMemSize int64 = 256 << 20
+
+// runs are merged into one when there are this many, to keep the number
+// of open files down
+var maxSortRuns = 128
+
+// sortWriter sorts entries by key before writing them to w on Close. They
+// are kept in memory up to about max bytes, and then written to a sorted
+// temporary file (a run). Close merges the runs.
+type sortWriter struct {
+	w       entryWriter
+	key     func(e *entry) string
+	max     int64
+	size    int64
+	entries []keyedEntry
+	runs    []*os.File
+}
+
+type keyedEntry struct {
+	key string
+	e   *entry
+}
+
+func newSortWriter(w entryWriter, max int64, key func(e *entry) string) *sortWriter {
+	return &sortWriter{w: w, key: key, max: max}
+}
+
+// entrySize is about how much memory e takes
+func entrySize(e *entry) int64 {
+	return int64(len(e.URL)+len(e.From)+len(e.Type)+len(e.Date)+len(e.RecordID)+len(e.Digest)) + 128
+}
+
+func (w *sortWriter) Write(e *entry) error {
+	k := keyedEntry{w.key(e), e}
+	w.entries = append(w.entries, k)
+	w.size += entrySize(e) + int64(len(k.key))
+	if w.size >= w.max {
+		return w.spill()
+	}
+
+	return nil
+}
+
+func (w *sortWriter) sortEntries() {
+	sort.Slice(w.entries, func(i, j int) bool { return w.entries[i].key < w.entries[j].key })
+}
+
+// spill writes the entries in memory to a new run
+func (w *sortWriter) spill() error {
+	w.sortEntries()
+	err := w.newRun(func(enc *gob.Encoder) error {
+		for _, k := range w.entries {
+			if err := enc.Encode(k.e); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	w.entries, w.size = nil, 0
+	if err == nil && len(w.runs) >= maxSortRuns {
+		runs := w.runs
+		w.runs = nil
+		err = w.newRun(func(enc *gob.Encoder) error {
+			return w.mergeRuns(runs, func(e *entry) error { return enc.Encode(e) })
+		})
+
+		removeRuns(runs)
+	}
+
+	return err
+}
+
+// newRun adds a run written by write, rewound for reading
+func (w *sortWriter) newRun(write func(enc *gob.Encoder) error) error {
+	f, err := os.CreateTemp("", "warc-urls-sort.*")
+	if err != nil {
+		return err
+	}
+
+	w.runs = append(w.runs, f)
+	bw := bufio.NewWriter(f)
+	if err := write(gob.NewEncoder(bw)); err != nil {
+		return err
+	} else if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	return err
+}
+
+// Close writes the entries in order, and closes w
+func (w *sortWriter) Close() error {
+	defer func() {
+		removeRuns(w.runs)
+		w.runs = nil
+	}()
+
+	if len(w.runs) == 0 {
+		w.sortEntries()
+		for _, k := range w.entries {
+			if err := w.w.Write(k.e); err != nil {
+				return err
+			}
+		}
+	} else if len(w.entries) > 0 {
+		if err := w.spill(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.mergeRuns(w.runs, w.w.Write); err != nil {
+		return err
+	}
+
+	return w.w.Close()
+}
+
+// mergeRuns calls write with the entries of runs in order
+func (w *sortWriter) mergeRuns(runs []*os.File, write func(e *entry) error) error {
+	var h runHeap
+	for _, f := range runs {
+		r := &run{dec: gob.NewDecoder(bufio.NewReader(f)), key: w.key}
+		if err := r.next(); err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		h = append(h, r)
+	}
+
+	heap.Init(&h)
+	for len(h) > 0 {
+		r := h[0]
+		if err := write(r.e.e); err != nil {
+			return err
+		}
+
+		if err := r.next(); err == io.EOF {
+			heap.Pop(&h)
+		} else if err != nil {
+			return err
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+
+	return nil
+}
+
+func removeRuns(runs []*os.File) {
+	for _, f := range runs {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+func (w *sortWriter) Abort() {
+	removeRuns(w.runs)
+	w.runs = nil
+	if a, ok := w.w.(aborter); ok {
+		a.Abort()
+	}
+}
+
+// run is a sorted temporary file being merged, and its current entry
+type run struct {
+	dec *gob.Decoder
+	key func(e *entry) string
+	e   keyedEntry
+}
+
+func (r *run) next() error {
+	e := new(entry)
+	if err := r.dec.Decode(e); err != nil {
+		return err
+	}
+
+	r.e = keyedEntry{r.key(e), e}
+	return nil
+}
+
+type runHeap []*run
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].e.key < h[j].e.key }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*run)) }
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSortWriter(t *testing.T) {
+	defer func(dir string, n int) {
+		os.Setenv("TMPDIR", dir)
+		maxSortRuns = n
+	}(os.Getenv("TMPDIR"), maxSortRuns)
+	maxSortRuns = 10
+	tmp := t.TempDir()
+	os.Setenv("TMPDIR", tmp)
+
+	var urls []string
+	for i := 0; i < 1000; i++ {
+		urls = append(urls, fmt.Sprintf("http://example.com/%x", rand.Int63()))
+	}
+
+	want := append([]string(nil), urls...)
+	sort.Strings(want)
+
+	// all in memory, a few runs, and a run per entry, merged every 10
+	for _, max := range []int64{1 << 30, 20000, 1} {
+		var out bytes.Buffer
+		w := newSortWriter(newEntryWriter("jsonl", &out), max, func(e *entry) string { return e.URL })
+		for _, url := range urls {
+			if err := w.Write(&entry{URL: url, Type: "response"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		if len(lines) != len(want) {
+			t.Fatalf("max=%v: %v entries, want %v", max, len(lines), len(want))
+		}
+
+		for i, line := range lines {
+			if wl := `{"url":"` + want[i] + `","warc_type":"response","length":0}`; line != wl {
+				t.Fatalf("max=%v: entry %v is %v, want %v", max, i, line, wl)
+			}
+		}
+
+		if left, _ := filepath.Glob(filepath.Join(tmp, "*")); len(left) > 0 {
+			t.Fatalf("max=%v: left %v", max, left)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+
+	_ "modernc.org/sqlite"
+)
+
+var (
+	sqliteFile  = flag.String("sqlite", "", "insert the URLs into the urls table of this SQLite database instead of writing them out")
+	sqliteBatch = flag.Int("sqlite-batch", 10000, "URLs inserted per -sqlite transaction")
+)
+
+var sqliteSchema = []string{`CREATE TABLE IF NOT EXISTS urls (
+	url       TEXT PRIMARY KEY,
+	from_url  TEXT,
+	warc_type TEXT,
+	warc_date TEXT,
+	record_id TEXT,
+	digest    TEXT,
+	length    INTEGER
+)`,
+	`CREATE INDEX IF NOT EXISTS urls_warc_date ON urls (warc_date)`,
+}
+
+const sqliteInsert = `INSERT OR IGNORE INTO urls
+	(url, from_url, warc_type, warc_date, record_id, digest, length)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// sqliteWriter inserts entries in transactions of batch entries. URLs
+// already in the table are ignored, so it dedups on its own.
+type sqliteWriter struct {
+	db    *sql.DB
+	tx    *sql.Tx
+	stmt  *sql.Stmt
+	batch int
+	n     int // entries in tx
+}
+
+func newSQLiteWriter(path string, batch int) (*sqliteWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// a single connection, so that the pragmas apply to the transactions
+	db.SetMaxOpenConns(1)
+	pragmas := []string{"PRAGMA journal_mode = WAL", "PRAGMA synchronous = NORMAL"}
+	for _, q := range append(pragmas, sqliteSchema...) {
+		if _, err := db.Exec(q); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	return &sqliteWriter{db: db, batch: batch}, nil
+}
+
+func (w *sqliteWriter) Write(e *entry) error {
+	if w.tx == nil {
+		tx, err := w.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.Prepare(sqliteInsert)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		w.tx, w.stmt = tx, stmt
+	}
+
+	if _, err := w.stmt.Exec(e.URL, e.From, e.Type, e.Date, e.RecordID, e.Digest, e.Length); err != nil {
+		return err
+	}
+
+	w.n++
+	if w.n >= w.batch {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+// Flush commits the current transaction
+func (w *sqliteWriter) Flush() error {
+	if w.tx == nil {
+		return nil
+	}
+
+	w.stmt.Close()
+	err := w.tx.Commit()
+	w.tx, w.stmt, w.n = nil, nil, 0
+	return err
+}
+
+func (w *sqliteWriter) Close() error {
+	err := w.Flush()
+	if cerr := w.db.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// sqliteURLs returns the URLs and types in the urls table of path
+func sqliteURLs(t *testing.T, path string) []string {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+	rows, err := db.Query("SELECT * FROM urls ORDER BY url")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var e entry
+		if err := rows.Scan(&e.URL, &e.From, &e.Type, &e.Date, &e.RecordID, &e.Digest, &e.Length); err != nil {
+			t.Fatal(err)
+		}
+
+		got = append(got, e.URL+" "+e.Type)
+	}
+
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return got
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+	write := func(typ string, urls ...string) *resultWriter {
+		sw, err := newSQLiteWriter(path, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := newResultWriter(sw)
+		w.existing = nil
+		for i, url := range urls {
+			w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1},
+				entries: []*entry{{URL: url, Type: typ}}})
+		}
+
+		return w
+	}
+
+	w := write("response", "http://b/", "http://a/", "http://d/")
+	// a batch of two is committed, the third URL isn't yet
+	if got := sqliteURLs(t, path); len(got) != 2 {
+		t.Errorf("%q before the checkpoint", got)
+	}
+
+	// but is before a checkpoint is saved
+	(&checkpointer{path: filepath.Join(t.TempDir(), "ckpt")}).save(w)
+	if got := sqliteURLs(t, path); len(got) != 3 {
+		t.Errorf("%q after the checkpoint", got)
+	}
+
+	if err := w.out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// URLs already in the table are ignored
+	if err := write("request", "http://c/", "http://a/").out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"http://a/ response", "http://b/ response", "http://c/ request", "http://d/ response"}
+	if got := sqliteURLs(t, path); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
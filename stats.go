@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	countOnly   = flag.Bool("count", false, "instead of writing the URLs, print totals: records by WARC-Type, unique URLs and hosts, bytes and records/s")
+	summaryFile = flag.String("summary", "", "write a JSON summary of the run, with histograms of the URLs by scheme, TLD, MIME type and status, to this file, or - for standard error")
+)
+
+// runStats are the totals of the records and URLs written in a run
+type runStats struct {
+	records int
+	types   map[string]int // records by WARC-Type
+	bytes   int64          // of the (uncompressed) records
+	urls    int
+	hosts   map[string]struct{}
+
+	// URLs by scheme, TLD, MIME type and status, if they have one
+	schemes, tlds, mimes, statuses map[string]int
+}
+
+func newRunStats() *runStats {
+	return &runStats{
+		types:    make(map[string]int),
+		hosts:    make(map[string]struct{}),
+		schemes:  make(map[string]int),
+		tlds:     make(map[string]int),
+		mimes:    make(map[string]int),
+		statuses: make(map[string]int),
+	}
+}
+
+func (s *runStats) addRecord(res *result) {
+	s.records++
+	s.types[res.typ]++
+	s.bytes += int64(len(res.rec.data))
+}
+
+// addEntry counts an entry that's written, i.e. with a URL not seen before
+func (s *runStats) addEntry(e *entry) {
+	s.urls++
+	var x struct{}
+	host := e.host()
+	s.hosts[host] = x
+	if i := strings.Index(e.URL, ":"); i > 0 {
+		s.schemes[strings.ToLower(e.URL[:i])]++
+	}
+
+	if tld := hostTLD(host); len(tld) > 0 {
+		s.tlds[tld]++
+	}
+
+	if len(e.MIME) > 0 {
+		s.mimes[e.MIME]++
+	}
+
+	if e.Status > 0 {
+		s.statuses[strconv.Itoa(e.Status)]++
+	}
+}
+
+// hostTLD is the last label of a host name, and empty for IP addresses
+func hostTLD(host string) string {
+	host = strings.TrimSuffix(host, ".")
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+
+	return host[strings.LastIndex(host, ".")+1:]
+}
+
+func (s *runStats) report(w io.Writer, elapsed time.Duration) {
+	var types []string
+	for t := range s.types {
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+	fmt.Fprintf(w, "records: %v\n", s.records)
+	for _, t := range types {
+		name := t
+		if len(name) == 0 {
+			name = "(none)"
+		}
+
+		fmt.Fprintf(w, "  %v: %v\n", name, s.types[t])
+	}
+
+	fmt.Fprintf(w, "unique urls: %v\n", s.urls)
+	fmt.Fprintf(w, "unique hosts: %v\n", len(s.hosts))
+	fmt.Fprintf(w, "bytes: %v\n", s.bytes)
+	if secs := elapsed.Seconds(); secs > 0 {
+		fmt.Fprintf(w, "records/s: %.1f\n", float64(s.records)/secs)
+	}
+}
+
+type runSummary struct {
+	Records        int            `json:"records"`
+	RecordsByType  map[string]int `json:"records_by_type"`
+	Bytes          int64          `json:"bytes"`
+	URLs           int            `json:"urls"`
+	Hosts          int            `json:"hosts"`
+	ElapsedSeconds float64        `json:"elapsed_seconds"`
+	Schemes        map[string]int `json:"schemes"`
+	TLDs           map[string]int `json:"tlds"`
+	MIMEs          map[string]int `json:"mimes"`
+	Statuses       map[string]int `json:"statuses"`
+}
+
+func (s *runStats) summary(elapsed time.Duration) *runSummary {
+	return &runSummary{
+		Records:        s.records,
+		RecordsByType:  s.types,
+		Bytes:          s.bytes,
+		URLs:           s.urls,
+		Hosts:          len(s.hosts),
+		ElapsedSeconds: elapsed.Seconds(),
+		Schemes:        s.schemes,
+		TLDs:           s.tlds,
+		MIMEs:          s.mimes,
+		Statuses:       s.statuses,
+	}
+}
+
+// writeSummary writes the -summary to path, or standard error for -
+func (s *runStats) writeSummary(path string, elapsed time.Duration) error {
+	data, err := json.MarshalIndent(s.summary(elapsed), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stderr.Write(data)
+		return err
+	}
+
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	} else if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// discardWriter is the output of -count
+type discardWriter struct{}
+
+func (discardWriter) Write(e *entry) error { return nil }
+func (discardWriter) Close() error         { return nil }
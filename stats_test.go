@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStats(t *testing.T) {
+	recs := []string{
+		warcRecord("WARC-Type: warcinfo\r\n", "software: x"),
+		targetRecord("http://a.example/"),
+		targetRecord("http://b.example/x"),
+		targetRecord("http://A.example/y"),
+		targetRecord("http://a.example/"),
+		warcRecord("WARC-Type: request\r\nWARC-Target-URI: http://b.example/x\r\n", "GET"),
+	}
+
+	w := newResultWriter(discardWriter{})
+	w.stats = newRunStats()
+	var size int
+	for i, r := range recs {
+		size += len(r)
+		w.add(newResult(&rawRecord{in: &inputFile{}, seq: i, data: []byte(r), offset: -1}))
+	}
+
+	var out bytes.Buffer
+	w.stats.report(&out, 2*time.Second)
+	want := strings.Join([]string{
+		"records: 6",
+		"  request: 1",
+		"  response: 4",
+		"  warcinfo: 1",
+		"unique urls: 3",
+		"unique hosts: 2",
+		"bytes: " + strconv.Itoa(size),
+		"records/s: 3.0",
+	}, "\n") + "\n"
+
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	s := newRunStats()
+	for _, e := range []*entry{
+		{URL: "http://a.example.com/", MIME: "text/html", Status: 200},
+		{URL: "HTTPS://b.example.co.uk/", MIME: "text/html", Status: 404},
+		{URL: "https://192.0.2.1/", Status: 200},
+		{URL: "dns:example.org"},
+	} {
+		s.addEntry(e)
+	}
+
+	got := s.summary(time.Second)
+	want := []map[string]int{
+		{"http": 1, "https": 2, "dns": 1},
+		{"com": 1, "uk": 1},
+		{"text/html": 2},
+		{"200": 2, "404": 1},
+	}
+
+	for i, m := range []map[string]int{got.Schemes, got.TLDs, got.MIMEs, got.Statuses} {
+		if !reflect.DeepEqual(m, want[i]) {
+			t.Errorf("got %v, want %v", m, want[i])
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := s.writeSummary(path, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	var read runSummary
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	} else if read.URLs != 4 || read.Hosts != 4 || read.Statuses["200"] != 2 {
+		t.Errorf("read %+v", read)
+	}
+}
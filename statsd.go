@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	statsdAddr     = flag.String("statsd-addr", "", "send the metrics of -metrics-addr to this StatsD host:port over UDP")
+	statsdPrefix   = flag.String("statsd-prefix", "warc_urls.", "prefix of the StatsD metric names")
+	statsdInterval = flag.Duration("statsd-interval", 10*time.Second, "interval between StatsD flushes")
+)
+
+// the largest packet sent, to stay below common MTUs
+const statsdMaxPacket = 1432
+
+// statsdEmitter sends the metrics to StatsD every interval: the counters
+// as counts since the last flush, the stage latencies as their mean
+// timing since then, and the queue depths as gauges
+type statsdEmitter struct {
+	w      io.Writer
+	prefix string
+	last   map[string]int64 // counters as of the last flush
+	stages map[string][2]uint64
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func newStatsdEmitter(w io.Writer, prefix string) *statsdEmitter {
+	return &statsdEmitter{
+		w:      w,
+		prefix: prefix,
+		last:   make(map[string]int64),
+		stages: make(map[string][2]uint64),
+	}
+}
+
+func startStatsd(addr, prefix string, interval time.Duration) (*statsdEmitter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newStatsdEmitter(conn, prefix)
+	e.stop, e.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.flush(metrics)
+			case <-e.stop:
+				e.flush(metrics)
+				conn.Close()
+				close(e.done)
+				return
+			}
+		}
+	}()
+
+	return e, nil
+}
+
+// Close sends what's counted since the last flush
+func (e *statsdEmitter) Close() {
+	close(e.stop)
+	<-e.done
+}
+
+// lines are the StatsD lines of m since the last call
+func (e *statsdEmitter) lines(m *runMetrics) []string {
+	var lines []string
+	for _, c := range []struct {
+		name string
+		v    int64
+	}{
+		{"records_read", m.recordsRead.Load()},
+		{"parse_errors", m.parseErrors.Load()},
+		{"urls_emitted", m.urlsEmitted.Load()},
+	} {
+		lines = append(lines, fmt.Sprintf("%v%v:%v|c", e.prefix, c.name, c.v-e.last[c.name]))
+		e.last[c.name] = c.v
+	}
+
+	var stages []string
+	for stage := range m.stages {
+		stages = append(stages, stage)
+	}
+
+	sort.Strings(stages)
+	for _, stage := range stages {
+		h := m.stages[stage]
+		var n uint64
+		for i := range h.counts {
+			n += h.counts[i].Load()
+		}
+
+		nanos := h.nanos.Load()
+		last := e.stages[stage]
+		e.stages[stage] = [2]uint64{n, nanos}
+		if n > last[0] {
+			ms := float64(nanos-last[1]) / float64(n-last[0]) / float64(time.Millisecond)
+			lines = append(lines, fmt.Sprintf("%vstage_duration.%v:%v|ms", e.prefix, stage,
+				strconv.FormatFloat(ms, 'f', -1, 64)))
+		}
+	}
+
+	depths := m.queueDepths()
+	var queues []string
+	for q := range depths {
+		queues = append(queues, q)
+	}
+
+	sort.Strings(queues)
+	for _, q := range queues {
+		lines = append(lines, fmt.Sprintf("%vqueue_depth.%v:%v|g", e.prefix, q, depths[q]))
+	}
+
+	return lines
+}
+
+// flush sends the lines of m in as few packets as fit them
+func (e *statsdEmitter) flush(m *runMetrics) {
+	var packet bytes.Buffer
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		} else if _, err := e.w.Write(packet.Bytes()); err != nil {
+			slog.Warn("statsd", "err", err)
+		}
+
+		packet.Reset()
+	}
+
+	for _, line := range e.lines(m) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > statsdMaxPacket {
+			send()
+		}
+
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+
+		packet.WriteString(line)
+	}
+
+	send()
+}
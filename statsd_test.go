@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdLines(t *testing.T) {
+	m := newRunMetrics()
+	e := newStatsdEmitter(nil, "w.")
+	m.recordsRead.Add(5)
+	m.urlsEmitted.Add(2)
+	m.stages["parse"].observe(2 * time.Millisecond)
+	m.stages["parse"].observe(4 * time.Millisecond)
+	m.addQueue("records", func() int { return 3 })
+	want := []string{
+		"w.records_read:5|c",
+		"w.parse_errors:0|c",
+		"w.urls_emitted:2|c",
+		"w.stage_duration.parse:3|ms",
+		"w.queue_depth.pending:0|g",
+		"w.queue_depth.records:3|g",
+	}
+
+	if got := e.lines(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// deltas since the last flush, and no timings without records
+	m.recordsRead.Add(1)
+	want = []string{
+		"w.records_read:1|c",
+		"w.parse_errors:0|c",
+		"w.urls_emitted:0|c",
+		"w.queue_depth.pending:0|g",
+		"w.queue_depth.records:3|g",
+	}
+
+	if got := e.lines(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+type packetRecorder struct{ packets []string }
+
+func (r *packetRecorder) Write(p []byte) (int, error) {
+	r.packets = append(r.packets, string(p))
+	return len(p), nil
+}
+
+func TestStatsdFlushPackets(t *testing.T) {
+	m := newRunMetrics()
+	for i := 0; i < 100; i++ {
+		m.addQueue(strings.Repeat("q", 20)+string(rune('a'+i%26))+string(rune('a'+i/26)), func() int { return 0 })
+	}
+
+	var r packetRecorder
+	newStatsdEmitter(&r, "warc_urls.").flush(m)
+	if len(r.packets) < 2 {
+		t.Fatalf("got %v packets", len(r.packets))
+	}
+
+	var nlines int
+	for _, p := range r.packets {
+		if len(p) > statsdMaxPacket {
+			t.Errorf("packet of %v bytes", len(p))
+		}
+
+		nlines += strings.Count(p, "\n") + 1
+	}
+
+	if nlines != 3+101 {
+		t.Errorf("got %v lines", nlines)
+	}
+}
+
+func TestStatsdUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer conn.Close()
+	e, err := startStatsd(conn.LocalAddr().String(), "test.", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, statsdMaxPacket)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if !bytes.HasPrefix(buf[:n], []byte("test.records_read:")) {
+		t.Errorf("got %q", buf[:n])
+	}
+}
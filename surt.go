@@ -0,0 +1,147 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+var surtOutput = flag.Bool("surt", false, "write and deduplicate URLs in SURT form, e.g. com,example)/path?query, in text, jsonl, csv and tsv output")
+
+// outputURL is a URL as written, in SURT form with -surt
+func outputURL(url string) string {
+	if *surtOutput {
+		return surt(url)
+	}
+
+	return url
+}
+
+// surt returns the Sort-friendly URI Reordering Transform of a URL, as
+// used by CDX indexes: scheme, user info and fragment dropped, the host
+// lowercased and reversed, without www and default ports, and the query
+// arguments sorted, e.g. com,example)/path?a=1&b=2. URLs that can't be
+// parsed are returned lowercased.
+func surt(s string) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || len(u.Host) == 0 {
+		return strings.ToLower(s)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	port := u.Port()
+	if port == "80" && u.Scheme == "http" || port == "443" && u.Scheme == "https" {
+		port = ""
+	}
+
+	var key strings.Builder
+	if net.ParseIP(host) != nil {
+		key.WriteString(host)
+	} else {
+		labels := strings.Split(host, ".")
+		if len(labels) > 2 && isWWW(labels[0]) {
+			labels = labels[1:]
+		}
+
+		for i := len(labels) - 1; i >= 0; i-- {
+			key.WriteString(labels[i])
+			if i > 0 {
+				key.WriteByte(',')
+			}
+		}
+	}
+
+	if len(port) > 0 {
+		key.WriteString(":" + port)
+	}
+
+	key.WriteByte(')')
+	path := strings.ToLower(u.EscapedPath())
+	if len(path) == 0 {
+		path = "/"
+	}
+
+	key.WriteString(path)
+	if len(u.RawQuery) > 0 {
+		args := strings.Split(strings.ToLower(u.RawQuery), "&")
+		sort.Strings(args)
+		key.WriteString("?" + strings.Join(args, "&"))
+	}
+
+	return key.String()
+}
+
+// isWWW tells whether a host label is www, or www followed by digits
+func isWWW(label string) bool {
+	if !strings.HasPrefix(label, "www") {
+		return false
+	}
+
+	for _, c := range label[3:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// surtScope is a set of SURT prefixes, in and out of scope, of which the
+// longest matching one decides, as in wayback access controls
+type surtScope struct {
+	prefixes map[string]bool // in scope, or out of it if false
+	allows   bool            // whether any prefix is in scope
+}
+
+// newSURTScope returns the scope of the prefixes, those with a leading -
+// out of scope. Prefixes can also be in the form of Heritrix, e.g.
+// http://(com,example,)/blog.
+func newSURTScope(prefixes []string) *surtScope {
+	s := &surtScope{prefixes: make(map[string]bool)}
+	for _, p := range prefixes {
+		in := !strings.HasPrefix(p, "-")
+		p = normalizeSURTPrefix(strings.TrimPrefix(p, "-"))
+		s.prefixes[p] = in
+		if !strings.Contains(p, ")") && strings.HasSuffix(p, ",") {
+			// the domain itself, as well as its subdomains
+			s.prefixes[strings.TrimSuffix(p, ",")+")"] = in
+		}
+
+		s.allows = s.allows || in
+	}
+
+	return s
+}
+
+// normalizeSURTPrefix returns a prefix in the form of surt
+func normalizeSURTPrefix(p string) string {
+	p = strings.ToLower(strings.TrimSpace(p))
+	if i := strings.Index(p, "://("); i >= 0 {
+		p = p[i+4:]
+		p = strings.Replace(p, ",)", ")", 1)
+	}
+
+	// like surt, without www
+	if i := strings.IndexByte(p, ')'); i >= 0 {
+		labels := strings.Split(p[:i], ",")
+		if n := len(labels); n > 2 && isWWW(labels[n-1]) {
+			p = strings.Join(labels[:n-1], ",") + p[i:]
+		}
+	}
+
+	return p
+}
+
+// has tells whether url is in scope
+func (s *surtScope) has(url string) bool {
+	key := surt(url)
+	for n := len(key); n > 0; n-- {
+		if in, ok := s.prefixes[key[:n]]; ok {
+			return in
+		}
+	}
+
+	return !s.allows
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSURT(t *testing.T) {
+	tests := map[string]string{
+		"http://www.Example.com/Path?b=2&a=1#frag": "com,example)/path?a=1&b=2",
+		"https://example.com":                      "com,example)/",
+		"http://example.com:80/":                   "com,example)/",
+		"https://example.com:8443/x":               "com,example:8443)/x",
+		"http://user:pw@a.b.example.co.uk./":       "uk,co,example,b,a)/",
+		"http://www2.example.com/":                 "com,example)/",
+		"http://www.com/":                          "com,www)/",
+		"http://wwwx.example.com/":                 "com,example,wwwx)/",
+		"http://192.168.0.1:8080/a":                "192.168.0.1:8080)/a",
+		"dns:example.com":                          "dns:example.com",
+	}
+
+	for u, want := range tests {
+		if got := surt(u); got != want {
+			t.Errorf("%q: got %q, want %q", u, got, want)
+		}
+	}
+}
+
+func TestSURTOutput(t *testing.T) {
+	defer func(s bool) { *surtOutput = s }(*surtOutput)
+	*surtOutput = true
+	for _, test := range []struct{ format, want string }{
+		{"text", "com,example)/a?x=1&y=2\norg,example)/\n"},
+		{"jsonl", `{"url":"com,example)/a?x=1&y=2","length":0}` + "\n" + `{"url":"org,example)/","length":0}` + "\n"},
+		{"tsv", "url\ncom,example)/a?x=1&y=2\norg,example)/\n"},
+	} {
+		var out bytes.Buffer
+		w := newResultWriter(newEntryWriter(test.format, &out))
+		for i, url := range []string{"http://www.example.com/a?y=2&x=1", "https://example.com/a?x=1&y=2", "http://example.org/"} {
+			w.add(&result{rec: &rawRecord{in: &inputFile{}, seq: i, offset: -1}, entries: []*entry{{URL: url}}})
+		}
+
+		w.out.Close()
+		if out.String() != test.want {
+			t.Errorf("%v: got %q, want %q", test.format, out.String(), test.want)
+		}
+	}
+}
+
+func TestNormalizeSURTPrefix(t *testing.T) {
+	for p, want := range map[string]string{
+		"com,example)/blog":           "com,example)/blog",
+		"COM,Example)/":               "com,example)/",
+		"http://(com,example,)/blog":  "com,example)/blog",
+		"http://(com,example,www,)/":  "com,example)/",
+		"https://(org,example,":       "org,example,",
+		"com,example,www)/a":          "com,example)/a",
+		"  com,example,blog)/2024/  ": "com,example,blog)/2024/",
+	} {
+		if got := normalizeSURTPrefix(p); got != want {
+			t.Errorf("%q: got %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestSURTScope(t *testing.T) {
+	s := newSURTScope([]string{"com,example)/blog", "-com,example)/blog/drafts", "org,example,", "-http://(org,example,private,"})
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"http://www.example.com/blog/post", true},
+		{"https://example.com/blogroll", true},
+		{"http://example.com/blog/drafts/1", false},
+		{"http://example.com/shop", false},
+		{"http://example.org/", true},
+		{"http://a.example.org/x", true},
+		{"http://private.example.org/x", false},
+		{"http://examplefoo.org/", false},
+	}
+
+	for _, tt := range tests {
+		if got := s.has(tt.url); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	// with only prefixes out of scope, the rest is in it
+	s = newSURTScope([]string{"-com,example)/"})
+	if s.has("http://example.com/a") || !s.has("http://example.net/a") {
+		t.Error("expected only example.com out of scope")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	validateURLs = flag.Bool("validate-urls", false, "drop the URLs that net/url can't parse, or that aren't valid UTF-8, and log them, or write them to -rejects-file")
+	rejectsFile  = flag.String("rejects-file", "", "with -validate-urls, write the URLs dropped to this file instead of logging them, as lines of the quoted URL, a tab and the error")
+)
+
+// the -rejects-file, nil unless set
+var rejects *rejectLog
+
+type rejectLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	w   *bufio.Writer
+	err error
+}
+
+func createRejectLog(path string) (*rejectLog, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rejectLog{f: f, w: bufio.NewWriter(f)}, nil
+}
+
+func (l *rejectLog) write(s string, reason error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		_, l.err = fmt.Fprintf(l.w, "%v\t%v\n", strconv.Quote(s), reason)
+	}
+}
+
+func (l *rejectLog) Close() error {
+	err := l.err
+	if err == nil {
+		err = l.w.Flush()
+	}
+
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// validURL returns why s isn't a valid URL, or nil if it is
+func validURL(s string) error {
+	if !utf8.ValidString(s) {
+		return errors.New("invalid UTF-8")
+	}
+
+	_, err := url.Parse(s)
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		// without the URL, which is reported with it
+		return uerr.Err
+	}
+
+	return err
+}
+
+// rejectURL tells whether -validate-urls drops s, and reports it if so
+func rejectURL(s string) bool {
+	if !*validateURLs {
+		return false
+	}
+
+	err := validURL(s)
+	if err == nil {
+		return false
+	} else if rejects != nil {
+		rejects.write(s, err)
+	} else {
+		slog.Warn("invalid url", "url", s, "err", err)
+	}
+
+	return true
+}
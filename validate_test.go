@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidURL(t *testing.T) {
+	tests := []struct {
+		in    string
+		valid bool
+	}{
+		{"http://example.com/a?b=1#c", true},
+		{"http://example.com/a%20b", true},
+		{"dns:example.com", true},
+		{"http://example.com/a%zzb", false},
+		{"http://exa mple.com/", false},
+		{"http://example.com/\x7f", false},
+		{"http://example.com/\xff", false},
+		{"http://[::1/", false},
+	}
+
+	for _, tt := range tests {
+		if err := validURL(tt.in); (err == nil) != tt.valid {
+			t.Errorf("validURL(%q) = %v, want valid %v", tt.in, err, tt.valid)
+		}
+	}
+}
+
+func TestRejectURL(t *testing.T) {
+	old := *validateURLs
+	defer func() { *validateURLs, rejects = old, nil }()
+
+	path := filepath.Join(t.TempDir(), "rejects.tsv")
+	var err error
+	if rejects, err = createRejectLog(path); err != nil {
+		t.Fatal(err)
+	}
+
+	*validateURLs = true
+	var res result
+	res.add(&entry{}, "", []string{"http://example.com/", "http://example.com/%zz\n"})
+	if len(res.entries) != 1 || res.entries[0].URL != "http://example.com/" {
+		t.Errorf("entries: %+v", res.entries)
+	}
+
+	if err := rejects.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if got, want := string(data), "\"http://example.com/%zz\\n\"\tnet/url: invalid control character in URL\n"; got != want {
+		t.Errorf("rejects: %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"compress/gzip"
+	"flag"
+	"strings"
+)
+
+var warcOut = flag.String("warc-out", "", "also write the records with URLs to this WARC file, as a gzip member per record if it ends with .gz, which is only created once the run completes")
+
+var recordEnd = []byte("\r\n\r\n")
+
+// warcWriter writes records to a new WARC file, each compressed on its own
+// for a .gz file, as crawlers write them, so that they can be read at
+// their offsets
+type warcWriter struct {
+	f  *atomicFile
+	zw *gzip.Writer // nil if not compressed
+}
+
+func createWARC(path string) (*warcWriter, error) {
+	f, err := createAtomic(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &warcWriter{f: f}
+	if strings.HasSuffix(path, ".gz") {
+		w.zw = gzip.NewWriter(f)
+	}
+
+	return w, nil
+}
+
+func (w *warcWriter) writeRecord(data []byte) error {
+	if w.zw == nil {
+		if _, err := w.f.Write(data); err != nil {
+			return err
+		}
+
+		_, err := w.f.Write(recordEnd)
+		return err
+	}
+
+	w.zw.Reset(w.f)
+	if _, err := w.zw.Write(data); err != nil {
+		return err
+	} else if _, err := w.zw.Write(recordEnd); err != nil {
+		return err
+	}
+
+	return w.zw.Close()
+}
+
+func (w *warcWriter) Close() error {
+	return w.f.Commit()
+}
+
+func (w *warcWriter) Abort() {
+	w.f.Abort()
+}
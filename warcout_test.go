@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWARCOut(t *testing.T) {
+	recs := []string{
+		warcRecord("WARC-Type: warcinfo\r\n", "software: x"),
+		targetRecord("http://a/"),
+		targetRecord("http://b/"),
+		targetRecord("http://a/"),
+	}
+
+	for _, name := range []string{"out.warc", "out.warc.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		var out bytes.Buffer
+		w := newResultWriter(newEntryWriter("text", &out))
+		var err error
+		if w.warc, err = createWARC(path); err != nil {
+			t.Fatal(err)
+		}
+
+		for i, r := range recs {
+			w.add(newResult(&rawRecord{in: &inputFile{}, seq: i, data: []byte(r), offset: -1}))
+		}
+
+		if err := w.warc.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if name == "out.warc.gz" {
+			// a member per record
+			var members int
+			zr, err := gzip.NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			br := bufio.NewReader(bytes.NewReader(data))
+			zr.Reset(br)
+			for ; err == nil; err = zr.Reset(br) {
+				zr.Multistream(false)
+				io.Copy(io.Discard, zr)
+				members++
+			}
+
+			if members != 3 {
+				t.Errorf("%v members", members)
+			}
+		}
+
+		r, err := newRecordReader(bytes.NewReader(data), "auto", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for {
+			rec, err := r.NextRaw()
+			if err != nil {
+				break
+			}
+
+			got = append(got, string(rec)+"\r\n\r\n")
+		}
+
+		if !reflect.DeepEqual(got, recs[1:]) {
+			t.Errorf("%v: got %q", name, got)
+		} else if out.String() != "http://a/\nhttp://b/\n" {
+			t.Errorf("%v: wrote %q", name, out.String())
+		}
+	}
+}
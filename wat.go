@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+type watEnvelope struct {
+	Envelope struct {
+		PayloadMetadata struct {
+			HTTPResponseMetadata struct {
+				HTMLMetadata struct {
+					Head struct {
+						Base string
+					}
+					Links []struct {
+						URL string `json:"url"`
+					}
+				} `json:"HTML-Metadata"`
+			} `json:"HTTP-Response-Metadata"`
+		} `json:"Payload-Metadata"`
+	}
+}
+
+func isWATMetadata(typ, contentType string) bool {
+	return typ == "metadata" && strings.HasPrefix(contentType, "application/json")
+}
+
+// watLinks returns the http(s) outlinks of a WAT metadata record,
+// resolved against the page's base URL
+func watLinks(target string, block []byte) ([]string, error) {
+	var wat watEnvelope
+	if err := json.Unmarshal(bytes.TrimSpace(block), &wat); err != nil {
+		return nil, err
+	}
+
+	html := wat.Envelope.PayloadMetadata.HTTPResponseMetadata.HTMLMetadata
+	base, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(html.Head.Base) > 0 {
+		if u, err := base.Parse(html.Head.Base); err == nil {
+			base = u
+		}
+	}
+
+	var links []string
+	for _, link := range html.Links {
+		href := strings.TrimSpace(link.URL)
+		if len(href) == 0 || strings.HasPrefix(href, "#") {
+			continue
+		}
+
+		// mailto:, javascript: and the like are not outlinks
+		if u, err := base.Parse(href); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			links = append(links, u.String())
+		}
+	}
+
+	return links, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWATLinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		json   string
+		want   []string
+	}{
+		{
+			"relative to target",
+			"http://example.com/dir/page",
+			`{"Envelope":{"Payload-Metadata":{"HTTP-Response-Metadata":{"HTML-Metadata":
+				{"Links":[{"url":"other"},{"url":"/root"},{"url":"https://x.org/"}]}}}}}`,
+			[]string{"http://example.com/dir/other", "http://example.com/root", "https://x.org/"},
+		},
+		{
+			"relative to base",
+			"http://example.com/dir/page",
+			`{"Envelope":{"Payload-Metadata":{"HTTP-Response-Metadata":{"HTML-Metadata":
+				{"Head":{"Base":"http://cdn.example.com/b/"},"Links":[{"url":"x.html"}]}}}}}`,
+			[]string{"http://cdn.example.com/b/x.html"},
+		},
+		{
+			"fragments and other schemes",
+			"http://example.com/",
+			`{"Envelope":{"Payload-Metadata":{"HTTP-Response-Metadata":{"HTML-Metadata":
+				{"Links":[{"url":"#top"},{"url":""},{"url":"mailto:a@b"},
+				{"url":"javascript:void(0)"},{"url":"ftp://example.com/f"},{"url":"a#b"}]}}}}}`,
+			[]string{"http://example.com/a#b"},
+		},
+		{
+			"no HTML metadata",
+			"http://example.com/",
+			`{"Envelope":{"Payload-Metadata":{}}}`,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := watLinks(tt.target, []byte(tt.json))
+		if err != nil {
+			t.Errorf("%v: %v", tt.name, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := watLinks("http://example.com/", []byte("{not json")); err == nil {
+		t.Error("invalid JSON gave no error")
+	}
+}
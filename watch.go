@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type watchState struct {
+	size    int64
+	modTime time.Time
+	done    bool
+}
+
+// newDirWatcher returns an fsnotify watcher for dir and all directories
+// below it, or nil if one can't be set up.
+func newDirWatcher(dir string) *fsnotify.Watcher {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("watchRecords", "err", err)
+		return nil
+	}
+
+	if err := watchTree(w, dir); err != nil {
+		slog.Warn("watchRecords", "dir", dir, "err", err)
+		w.Close()
+		return nil
+	}
+
+	return w
+}
+
+// fsnotify doesn't watch recursively, so add every directory
+func watchTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			return w.Add(path)
+		}
+
+		return nil
+	})
+}
+
+// watchRecords processes WARC files under dir as they are completed. New
+// and changed files are picked up through fsnotify. If fsnotify can't be
+// used (e.g. on some network file systems, or when out of inotify
+// watches), the tree is polled every interval instead. Neither tells
+// when the writer has closed a file, so a file is taken to be complete
+// when its size and modification time have stayed the same for at least
+// settle. It only returns once stopped by a -limit, closing recs.
+func watchRecords(dir string, interval, settle time.Duration, recs chan *rawRecord,
+	nrecords *int) {
+	var events <-chan fsnotify.Event
+	var errors <-chan error
+	w := newDirWatcher(dir)
+	if w != nil {
+		events, errors = w.Events, w.Errors
+	} else {
+		slog.Info("watchRecords polling", "dir", dir, "interval", interval)
+	}
+
+	var ninputs int
+	files := make(map[string]*watchState)
+	rescan := true
+	ticker := time.NewTicker(interval)
+	for {
+		if rescan || w == nil {
+			paths, _, err := walkDir(dir)
+			if err != nil {
+				slog.Error("watchRecords", "dir", dir, "err", err)
+			}
+
+			for _, path := range paths {
+				if _, ok := files[path]; !ok {
+					files[path] = &watchState{}
+				}
+			}
+
+			rescan = false
+		}
+
+		watchCheck(files, settle, &ninputs, recs, nrecords)
+
+		select {
+		case ev := <-events:
+			if ev.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					// files may have been created before the watch was added
+					watchTree(w, ev.Name)
+					rescan = true
+				}
+			}
+
+			if hasWARCExt(ev.Name) && ev.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 {
+				if _, ok := files[ev.Name]; !ok {
+					files[ev.Name] = &watchState{}
+				}
+			}
+		case err := <-errors:
+			// e.g. an event queue overflow, so events may have been lost
+			slog.Warn("watchRecords", "dir", dir, "err", err)
+			rescan = true
+		case <-ticker.C:
+		case <-stopping:
+			close(recs)
+			return
+		}
+	}
+}
+
+// watchCheck processes the files that look complete, in lexical order
+func watchCheck(files map[string]*watchState, settle time.Duration, ninputs *int,
+	recs chan *rawRecord, nrecords *int) {
+	var paths []string
+	for path, st := range files {
+		if !st.done {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		st := files[path]
+		fi, err := os.Stat(path)
+		if err != nil {
+			// renamed or removed before it was complete
+			delete(files, path)
+			continue
+		} else if st.size != fi.Size() || !st.modTime.Equal(fi.ModTime()) {
+			st.size, st.modTime = fi.Size(), fi.ModTime()
+			continue
+		} else if time.Since(fi.ModTime()) < settle {
+			continue
+		}
+
+		st.done = true
+		in := &inputFile{index: *ninputs, path: path}
+		*ninputs++
+		f, err := openInput(path)
+		if err == nil {
+			err = readInput(in, f, recs, nrecords)
+			f.Close()
+		}
+
+		recs <- &rawRecord{in: in, seq: in.nrecs, last: true}
+		if err != nil {
+			slog.Error("watchRecords", "file", path, "err", err)
+		} else {
+			slog.Info("watchRecords done", "file", path)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchRecords(t *testing.T) {
+	defer func() { stopping, stopOnce = make(chan struct{}), sync.Once{} }()
+	dir := t.TempDir()
+	recs := make(chan *rawRecord, 100)
+	var nrecords int
+	go watchRecords(dir, 10*time.Millisecond, 50*time.Millisecond, recs, &nrecords)
+
+	// created once watched, also in a directory created since
+	os.WriteFile(filepath.Join(dir, "a.warc"), []byte(targetRecord("http://a/")+targetRecord("http://b/")), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(targetRecord("http://x/")), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "sub", "c.warc"), []byte(targetRecord("http://c/")), 0644)
+
+	var urls []string
+	timeout := time.After(10 * time.Second)
+	for ndone := 0; ndone < 2; {
+		select {
+		case rec := <-recs:
+			if rec.last {
+				ndone++
+				continue
+			}
+
+			for _, e := range newResult(rec).entries {
+				urls = append(urls, e.URL)
+			}
+		case <-timeout:
+			t.Fatalf("timed out, got %v", urls)
+		}
+	}
+
+	stopInputs()
+	for range recs {
+		// closed once stopped
+	}
+
+	sort.Strings(urls)
+	if want := []string{"http://a/", "http://b/", "http://c/"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("got %v, want %v", urls, want)
+	} else if nrecords != 3 {
+		t.Errorf("%v records read, want 3", nrecords)
+	}
+}
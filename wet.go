@@ -0,0 +1,44 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var textURLRe = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'{}\[\]]+`)
+
+func isWETConversion(typ, contentType string) bool {
+	return typ == "conversion" && strings.HasPrefix(contentType, "text/plain")
+}
+
+// textURLs returns the http(s) URLs appearing in extracted text.
+// Punctuation at the end of a match is assumed to belong to the sentence,
+// as are closing parentheses without an opening one in the URL, e.g. of
+// (see https://example.com/), but not of
+// https://en.wikipedia.org/wiki/Go_(language).
+func textURLs(text []byte) []string {
+	var urls []string
+	for _, match := range textURLRe.FindAll(text, -1) {
+		u := trimTextURL(string(match))
+		if !strings.HasSuffix(u, "://") {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls
+}
+
+func trimTextURL(u string) string {
+	for {
+		trimmed := strings.TrimRight(u, ".,;:!?")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+
+		if trimmed == u {
+			return u
+		}
+
+		u = trimmed
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"no urls here", nil},
+		{"see https://example.com/a.", []string{"https://example.com/a"}},
+		{"at http://example.com/?q=1, or HTTPS://Example.com/b!", []string{"http://example.com/?q=1", "HTTPS://Example.com/b"}},
+		{"is it https://example.com/a?; yes: https://example.com/b...", []string{"https://example.com/a", "https://example.com/b"}},
+		{"https://en.wikipedia.org/wiki/Go_(language)", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"https://en.wikipedia.org/wiki/Go_(language).", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"(see https://en.wikipedia.org/wiki/Go_(language))", []string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"(see https://example.com/a).", []string{"https://example.com/a"}},
+		{"(https://example.com/a_(b)_c), and", []string{"https://example.com/a_(b)_c"}},
+		{"https://example.com/f(x)(y)", []string{"https://example.com/f(x)(y)"}},
+		{"<https://example.com/a> \"http://example.com/b\" [http://example.com/c]",
+			[]string{"https://example.com/a", "http://example.com/b", "http://example.com/c"}},
+		{"just https:// and http://.", nil},
+		{"ftp://example.com/ mailto:a@example.com", nil},
+		{"line\nhttps://example.com/a\nhttps://example.com/b\n", []string{"https://example.com/a", "https://example.com/b"}},
+	}
+
+	for _, tt := range tests {
+		if got := textURLs([]byte(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var whereExpr = flag.String("where", "", "only output the URLs for which this expression is true, e.g. 'status == 200 && host endswith \".gov\" && mime startswith \"text/\"', of the -fields fields, strings, numbers, ==, !=, <, <=, >, >=, contains, startswith, endswith, matches, &&, ||, ! and parentheses")
+
+// whereToken is a token of a -where expression: an identifier or word
+// operator, a string or number literal, or an operator
+type whereToken struct {
+	kind byte // i for identifiers, s for strings, n for numbers, o for operators
+	text string
+	pos  int
+}
+
+func lexWhere(s string) ([]whereToken, error) {
+	var tokens []whereToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+			j := i + 1
+			for j < len(s) && (s[j] == '_' || 'a' <= s[j] && s[j] <= 'z' || 'A' <= s[j] && s[j] <= 'Z' || '0' <= s[j] && s[j] <= '9') {
+				j++
+			}
+
+			tokens = append(tokens, whereToken{'i', strings.ToLower(s[i:j]), i})
+			i = j
+		case '0' <= c && c <= '9' || c == '-' && i+1 < len(s) && '0' <= s[i+1] && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && ('0' <= s[j] && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+
+			tokens = append(tokens, whereToken{'n', s[i:j], i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+
+				j++
+			}
+
+			if j >= len(s) {
+				return nil, fmt.Errorf("at %v: unterminated string", i)
+			}
+
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("at %v: %v", i, err)
+			}
+
+			tokens = append(tokens, whereToken{'s', text, i})
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+
+			if len(op) == 0 {
+				return nil, fmt.Errorf("at %v: unexpected %q", i, c)
+			}
+
+			tokens = append(tokens, whereToken{'o', op, i})
+			i += len(op)
+		}
+	}
+
+	return tokens, nil
+}
+
+// whereParser parses a -where expression by recursive descent:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand op operand
+//	operand    = field | string | number
+type whereParser struct {
+	tokens []whereToken
+	end    int // the length of the expression, for errors at its end
+}
+
+type whereValue func(e *entry) string
+
+func parseWhere(s string) (entryFilter, error) {
+	tokens, err := lexWhere(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whereParser{tokens: tokens, end: len(s)}
+	f, err := p.expr()
+	if err != nil {
+		return nil, err
+	} else if len(p.tokens) > 0 {
+		return nil, p.errorf("unexpected %q", p.tokens[0].text)
+	}
+
+	return f, nil
+}
+
+func (p *whereParser) errorf(format string, args ...interface{}) error {
+	pos := p.end
+	if len(p.tokens) > 0 {
+		pos = p.tokens[0].pos
+	}
+
+	return fmt.Errorf("at %v: %v", pos, fmt.Sprintf(format, args...))
+}
+
+// accept consumes the next token if it's the operator op
+func (p *whereParser) accept(op string) bool {
+	if len(p.tokens) > 0 && p.tokens[0].kind == 'o' && p.tokens[0].text == op {
+		p.tokens = p.tokens[1:]
+		return true
+	}
+
+	return false
+}
+
+func (p *whereParser) expr() (entryFilter, error) {
+	f, err := p.and()
+	for err == nil && p.accept("||") {
+		var g entryFilter
+		if g, err = p.and(); err == nil {
+			a := f
+			f = func(e *entry) bool { return a(e) || g(e) }
+		}
+	}
+
+	return f, err
+}
+
+func (p *whereParser) and() (entryFilter, error) {
+	f, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var g entryFilter
+		if g, err = p.unary(); err == nil {
+			a := f
+			f = func(e *entry) bool { return a(e) && g(e) }
+		}
+	}
+
+	return f, err
+}
+
+func (p *whereParser) unary() (entryFilter, error) {
+	if p.accept("!") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(e *entry) bool { return !f(e) }, nil
+	} else if p.accept("(") {
+		f, err := p.expr()
+		if err != nil {
+			return nil, err
+		} else if !p.accept(")") {
+			return nil, p.errorf("missing )")
+		}
+
+		return f, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *whereParser) comparison() (entryFilter, error) {
+	a, err := p.operand()
+	if err != nil {
+		return nil, err
+	} else if len(p.tokens) == 0 {
+		return nil, p.errorf("missing operator")
+	}
+
+	op := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	if op.kind == 'i' && op.text == "matches" {
+		if len(p.tokens) == 0 || p.tokens[0].kind != 's' {
+			return nil, p.errorf("matches needs a string")
+		}
+
+		re, err := regexp.Compile(p.tokens[0].text)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		p.tokens = p.tokens[1:]
+		return func(e *entry) bool { return re.MatchString(a(e)) }, nil
+	}
+
+	b, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	var cmp func(x, y string) bool
+	switch op.text {
+	case "contains":
+		cmp = strings.Contains
+	case "startswith":
+		cmp = strings.HasPrefix
+	case "endswith":
+		cmp = strings.HasSuffix
+	case "==":
+		cmp = func(x, y string) bool { return compareWhere(x, y) == 0 }
+	case "!=":
+		cmp = func(x, y string) bool { return compareWhere(x, y) != 0 }
+	case "<":
+		cmp = func(x, y string) bool { return compareWhere(x, y) < 0 }
+	case "<=":
+		cmp = func(x, y string) bool { return compareWhere(x, y) <= 0 }
+	case ">":
+		cmp = func(x, y string) bool { return compareWhere(x, y) > 0 }
+	case ">=":
+		cmp = func(x, y string) bool { return compareWhere(x, y) >= 0 }
+	default:
+		return nil, fmt.Errorf("at %v: unknown operator %q", op.pos, op.text)
+	}
+
+	return func(e *entry) bool { return cmp(a(e), b(e)) }, nil
+}
+
+func (p *whereParser) operand() (whereValue, error) {
+	if len(p.tokens) == 0 {
+		return nil, p.errorf("missing operand")
+	}
+
+	t := p.tokens[0]
+	switch t.kind {
+	case 's', 'n':
+		p.tokens = p.tokens[1:]
+		return func(*entry) string { return t.text }, nil
+	case 'i':
+		field, ok := entryFields[t.text]
+		if !ok {
+			return nil, p.errorf("unknown field %q", t.text)
+		}
+
+		p.tokens = p.tokens[1:]
+		return whereValue(field), nil
+	}
+
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+// compareWhere compares x and y as numbers if they both are, e.g. status
+// and 200, or else as strings, by which RFC 3339 dates are in order
+func compareWhere(x, y string) int {
+	if a, err := strconv.ParseFloat(x, 64); err == nil {
+		if b, err := strconv.ParseFloat(y, 64); err == nil {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(x, y)
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestParseWhere(t *testing.T) {
+	e := &entry{URL: "https://www.example.gov/a?b", Status: 200, MIME: "text/html", Length: 1500, Date: "2024-03-01T12:00:00Z"}
+	for _, tt := range []struct {
+		expr string
+		want bool
+	}{
+		{`status == 200 && host endswith ".gov" && mime startswith "text/"`, true},
+		{`status == 200.0`, true},
+		{`status != 200 || mime == "text/plain"`, false},
+		{`!(status >= 300) && length > 999`, true},
+		{`length < 200`, false},
+		{`date >= "2024-03" && date < "2024-04"`, true},
+		{`url contains "?b" && url matches "^https://[^/]+\\.gov/"`, true},
+		{`host == "www.example.gov" && type == ""`, true},
+		{`status == 404 || status == 200 && mime == "image/png"`, false},
+		{`(status == 404 || status == 200) && mime == "text/html"`, true},
+		{`200 == status`, true},
+		{`STATUS == -1`, false},
+	} {
+		f, err := parseWhere(tt.expr)
+		if err != nil {
+			t.Errorf("%v: %v", tt.expr, err)
+		} else if got := f(e); got != tt.want {
+			t.Errorf("%v: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{
+		``,
+		`status`,
+		`status ==`,
+		`size == 1`,
+		`status = 200`,
+		`(status == 200`,
+		`status == 200)`,
+		`url matches host`,
+		`url matches "("`,
+		`mime == "text`,
+		`status == 200 &&`,
+		`status like 200`,
+	} {
+		if _, err := parseWhere(expr); err == nil {
+			t.Errorf("%v: no error", expr)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	zstdFrameMagic     = 0xfd2fb528
+	zstdSkippableMagic = 0x184d2a50 // through 0x184d2a5f
+	// WARC-zstd puts the dictionary in a skippable frame first in the file
+	zstdDictFrameMagic = 0x184d2a5d
+	// real dictionaries are a few hundred KB at most; the frame size is
+	// untrusted input
+	zstdMaxDictSize = 16 << 20
+)
+
+func isZstdMagic(magic uint32) bool {
+	return magic == zstdFrameMagic || magic&0xfffffff0 == zstdSkippableMagic
+}
+
+type zstdReader struct {
+	*zstd.Decoder
+}
+
+func (z zstdReader) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+// newZstdReader decompresses a zstd stream. If the stream starts with a
+// WARC-zstd dictionary frame, the (possibly itself compressed)
+// dictionary is loaded and used for the frames that follow. A stream is
+// decoded synchronously, without reading ahead.
+func newZstdReader(br *bufio.Reader, stream bool) (io.ReadCloser, error) {
+	var opts []zstd.DOption
+	if stream {
+		opts = append(opts, zstd.WithDecoderConcurrency(1))
+	}
+
+	hdr, _ := br.Peek(8)
+	if len(hdr) == 8 && binary.LittleEndian.Uint32(hdr) == zstdDictFrameMagic {
+		size := binary.LittleEndian.Uint32(hdr[4:])
+		if size > zstdMaxDictSize {
+			return nil, fmt.Errorf("zstd dictionary: size %v exceeds %v", size, zstdMaxDictSize)
+		}
+
+		if _, err := br.Discard(8); err != nil {
+			return nil, err
+		}
+
+		dict := make([]byte, size)
+		if _, err := io.ReadFull(br, dict); err != nil {
+			return nil, fmt.Errorf("zstd dictionary: %v", err)
+		}
+
+		if len(dict) >= 4 && binary.LittleEndian.Uint32(dict) == zstdFrameMagic {
+			dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(zstdMaxDictSize))
+			if err != nil {
+				return nil, err
+			}
+
+			dict, err = dec.DecodeAll(dict, nil)
+			dec.Close()
+			if err != nil {
+				return nil, fmt.Errorf("zstd dictionary: %v", err)
+			}
+		}
+
+		opts = append(opts, zstd.WithDecoderDicts(dict))
+	}
+
+	dec, err := zstd.NewReader(br, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return zstdReader{dec}, nil
+}
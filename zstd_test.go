@@ -0,0 +1,19 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestZstdDictSizeLimit(t *testing.T) {
+	hdr := make([]byte, 8)
+	binary.LittleEndian.PutUint32(hdr, zstdDictFrameMagic)
+	binary.LittleEndian.PutUint32(hdr[4:], 0xffffffff)
+	_, err := newZstdReader(bufio.NewReader(bytes.NewReader(hdr)), false)
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("got %v, want size error", err)
+	}
+}